@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+const AsOfLayout = "2006-01-02"
+
+// How much history before the as_of date a shallow clone fetches, the
+// clone is deepened when the branch has no commit in that window.
+var AsOfWindow = 30 * 24 * time.Hour
+
+func (d *Dep) setAsOf(t *toml.TomlTree) {
+	if asOf, ok := t.Get("as_of").(string); ok {
+		d.AsOf = asOf
+	}
+}
+
+func (d *Dep) validateAsOf() error {
+	if d.AsOf == "" {
+		return nil
+	}
+	if _, err := time.Parse(AsOfLayout, d.AsOf); err != nil {
+		return fmt.Errorf("%s - as_of must be a date like 2023-01-15, was %s", d.Import, d.AsOf)
+	}
+	if d.CheckoutFlag != BranchFlag {
+		return fmt.Errorf("%s - as_of needs a branch", d.Import)
+	}
+	if d.Scm != GitTag {
+		return fmt.Errorf("%s - as_of is only supported with git", d.Import)
+	}
+	return nil
+}
+
+func (d *Dep) asOfTime() time.Time {
+	t, _ := time.Parse(AsOfLayout, d.AsOf)
+	return t
+}
+
+// The date the shallow clone of an as_of dep starts from.
+func (d *Dep) shallowSince() string {
+	return d.asOfTime().Add(-AsOfWindow).Format(AsOfLayout)
+}
+
+// Check out the last commit on the branch before the as_of date.
+func (g Git) checkoutAsOf(d *Dep) error {
+	sha, err := g.commitBefore(d)
+	if err == nil && sha == "" {
+		if runScmIn(d.Src(), "git", "fetch", "--unshallow", "origin", d.CheckoutSpec) == nil {
+			sha, err = g.commitBefore(d)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if sha == "" {
+		return fmt.Errorf("%s - no commit on %s before %s", d.Import, d.CheckoutSpec, d.AsOf)
+	}
+	return runScmIn(d.Src(), "git", "checkout", sha)
+}
+
+func (g Git) commitBefore(d *Dep) (string, error) {
+	before := d.asOfTime().Format(time.RFC3339)
+	return scmOutput(d.Src(), "git", "rev-list", "-1", "--before="+before, "origin/"+d.CheckoutSpec)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A git repository with a commit on main at each of the given dates.
+func createDatedHistory(t *testing.T, dates ...string) (string, map[string]string) {
+	dir, _ := ioutil.TempDir("", "gopack-history-")
+	shas := make(map[string]string)
+
+	git := func(env []string, args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=gopack", "-c", "user.email=gopack@example.com"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s\n", strings.Join(args, " "), out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git(nil, "init", "-q", "-b", "main")
+	for _, date := range dates {
+		createSourceFixture(dir, "main.go", "package main // "+date+"\n")
+		stamp := []string{"GIT_AUTHOR_DATE=" + date + "T12:00:00Z", "GIT_COMMITTER_DATE=" + date + "T12:00:00Z"}
+		git(stamp, "add", ".")
+		git(stamp, "commit", "-q", "-m", date)
+		shas[date] = git(nil, "rev-parse", "HEAD")
+	}
+	return dir, shas
+}
+
+func asOfDep(source, asOf string) *Dep {
+	dep := gitDep("github.com/gopack-test/history", "branch", "main")
+	dep.Source = source
+	dep.AsOf = asOf
+	return dep
+}
+
+func TestAsOfClonesShallowSince(t *testing.T) {
+	setupTestPwd()
+
+	var args []string
+	defer stubCommands(commandStubs{"clone": func(cmd *exec.Cmd) ([]byte, error) {
+		args = cmd.Args
+		return cloneCheckout(cmd)
+	}})()
+
+	if err := asOfDep("https://github.com/gopack-test/history.git", "2023-01-15").Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(strings.Join(args, " "), "--shallow-since=2022-12-16 --branch main") {
+		t.Errorf("Expected a shallow clone of main since 30 days before the date, ran %v\n", args)
+	}
+}
+
+func TestAsOfChecksOutLastCommitBeforeDate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	source, shas := createDatedHistory(t, "2023-01-01", "2023-01-10", "2023-01-20")
+
+	// checking out moves to the pwd of the test
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	// with no window the shallow clone has to be deepened to reach the commit
+	for _, window := range []time.Duration{AsOfWindow, 0} {
+		setupTestPwd()
+
+		orig := AsOfWindow
+		AsOfWindow = window
+
+		dep := asOfDep("file://"+source, "2023-01-15")
+		if err := dep.Get(); err != nil {
+			t.Fatal(err)
+		}
+		dep.switchToBranchOrTag()
+		AsOfWindow = orig
+
+		revision, err := dep.CurrentRevision()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revision != shas["2023-01-10"] {
+			t.Errorf("Expected %s to be checked out as of 2023-01-15 with a %s window, was %s\n", shas["2023-01-10"], window, revision)
+		}
+	}
+}
+
+func TestAsOfValidation(t *testing.T) {
+	for asOf, dep := range map[string]*Dep{
+		"invalid date": asOfDep("https://github.com/gopack-test/history.git", "15/01/2023"),
+		"tag":          &Dep{Import: "github.com/gopack-test/history", Scm: GitTag, Source: "https://github.com/gopack-test/history.git", CheckoutFlag: TagFlag, CheckoutSpec: "v1", AsOf: "2023-01-15"},
+		"hg":           &Dep{Import: "github.com/gopack-test/history", Scm: HgTag, Source: "https://github.com/gopack-test/history", CheckoutFlag: BranchFlag, CheckoutSpec: "default", AsOf: "2023-01-15"},
+	} {
+		if dep.Validate() == nil {
+			t.Errorf("Expected as_of with %s to be rejected\n", asOf)
+		}
+	}
+
+	if err := asOfDep("https://github.com/gopack-test/history.git", "2023-01-15").Validate(); err != nil {
+		t.Errorf("Expected as_of on a git branch to be valid, got %s\n", err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// check the vendored deps against the advisories, set with --audit
+	audit = false
+	// and fail when one is affected, set with --audit-fail
+	auditFail = false
+)
+
+// A known issue of some versions or revisions of a dependency.
+type Advisory struct {
+	ID      string `json:"id"`
+	Import  string `json:"import"`
+	Summary string `json:"summary"`
+	// affected tags
+	Versions []string `json:"versions"`
+	// affected commits, abbreviated shas match the full ones they start
+	Revisions []string `json:"revisions"`
+}
+
+// Load the advisories from a json file relative to dir, or from a url.
+// Unlike includes they're never cached, advisories only get added.
+func LoadAdvisories(dir, source string) ([]*Advisory, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = fetchAdvisories(source)
+	} else {
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(dir, source)
+		}
+		data, err = ioutil.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading advisories %s: %s", source, err)
+	}
+
+	advisories := []*Advisory{}
+	if err := json.Unmarshal(data, &advisories); err != nil {
+		return nil, fmt.Errorf("Error parsing advisories %s: %s", source, err)
+	}
+	return advisories, nil
+}
+
+func fetchAdvisories(url string) ([]byte, error) {
+	resp, err := httpGet(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (a *Advisory) Affects(locked *LockedDep) bool {
+	if a.Import != locked.Import {
+		return false
+	}
+	// a range is locked with the tag it resolved to
+	for _, version := range a.Versions {
+		if locked.Checkout == "tag "+version || (locked.Tag != "" && locked.Tag == version) {
+			return true
+		}
+	}
+	for _, revision := range a.Revisions {
+		if revision == "" {
+			continue
+		}
+		// only a full sha is extended by an abbreviated one, an svn
+		// revision has to be the same number
+		if revision == locked.Revision || (isFullSha(locked.Revision) && strings.HasPrefix(locked.Revision, strings.ToLower(revision))) {
+			return true
+		}
+	}
+	return false
+}
+
+func isFullSha(revision string) bool {
+	if len(revision) != 40 && len(revision) != 64 {
+		return false
+	}
+	for _, c := range revision {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Every locked dependency an advisory affects.
+func (l *Lock) Audit(advisories []*Advisory) []*ProjectError {
+	errors := []*ProjectError{}
+	for _, importPath := range l.Imports() {
+		locked := l.Deps[importPath]
+		for _, a := range advisories {
+			if a.Affects(locked) {
+				errors = append(errors, AdvisoryError(locked, a))
+			}
+		}
+	}
+	return errors
+}
+
+// Report the affected deps, returning them to fail the install with
+// --audit-fail.
+func auditDeps(config *Config) ([]*ProjectError, error) {
+	advisories, err := LoadAdvisories(filepath.Dir(config.Path), config.Advisories)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := ReadLock()
+	if err != nil {
+		return nil, err
+	}
+
+	errors := lock.Audit(advisories)
+	if auditFail && len(errors) > 0 {
+		return errors, nil
+	}
+	for _, e := range errors {
+		fmtcolor(Yellow, "      Warning: %s", e)
+	}
+	if len(errors) == 0 {
+		fmtcolor(Green, "      Audited: no advisories affect the %d locked dependencies\n", len(lock.Deps))
+	}
+	return nil, nil
+}
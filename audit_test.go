@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAuditFlagsAffectedDeps(t *testing.T) {
+	setupTestPwd()
+	createSourceFixture(pwd, "advisories.json", `[
+  {
+    "id": "GPSA-2015-0001",
+    "import": "github.com/gopack-test/audit-vulnerable",
+    "summary": "path traversal in the archive reader",
+    "versions": ["v1.0.0", "v1.0.1"]
+  },
+  {
+    "id": "GPSA-2015-0002",
+    "import": "github.com/gopack-test/audit-pinned",
+    "summary": "unbounded allocation",
+    "revisions": ["9fceb02"]
+  },
+  {
+    "id": "GPSA-2015-0003",
+    "import": "github.com/gopack-test/audit-fixed",
+    "summary": "fixed in v2.0.0",
+    "versions": ["v1.9.0"]
+  }
+]`)
+
+	advisories, err := LoadAdvisories(pwd, "advisories.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock := NewLock()
+	for _, locked := range []*LockedDep{
+		{Import: "github.com/gopack-test/audit-vulnerable", Checkout: "tag v1.0.1", Revision: "182cae2ee3926a960223d8db4998aa9d57c89788"},
+		{Import: "github.com/gopack-test/audit-pinned", Checkout: "branch master", Revision: "9fceb02d0ae598e95dc970b74767f19372d61af8"},
+		{Import: "github.com/gopack-test/audit-fixed", Checkout: "tag v2.0.0", Revision: "e83c5163316f89bfbde7d9ab23ca2e25604af290"},
+	} {
+		lock.Deps[locked.Import] = locked
+	}
+
+	errors := lock.Audit(advisories)
+	expected := []string{
+		"github.com/gopack-test/audit-pinned at branch master is affected by GPSA-2015-0002: unbounded allocation\n",
+		"github.com/gopack-test/audit-vulnerable at tag v1.0.1 is affected by GPSA-2015-0001: path traversal in the archive reader\n",
+	}
+	if len(errors) != len(expected) {
+		t.Fatalf("Expected %d affected deps, found %v\n", len(expected), errors)
+	}
+	for i, e := range errors {
+		if e.Kind != KnownIssue || e.Message != expected[i] {
+			t.Errorf("Expected %q, found %q\n", expected[i], e.Message)
+		}
+	}
+}
+
+func TestAdvisoryRevisionsMatchAbbreviatedShasOnly(t *testing.T) {
+	advisory := &Advisory{Import: "github.com/gopack-test/audit-revisions", Revisions: []string{"9fceb02", "12"}}
+	for revision, affected := range map[string]bool{
+		"9fceb02d0ae598e95dc970b74767f19372d61af8": true,
+		"9fceb02": true,
+		"9fce":    false,
+		"12":      true,
+		"123":     false,
+		"1":       false,
+		"e83c5163316f89bfbde7d9ab23ca2e25604af290": false,
+	} {
+		locked := &LockedDep{Import: "github.com/gopack-test/audit-revisions", Revision: revision}
+		if advisory.Affects(locked) != affected {
+			t.Errorf("Expected %s to be affected %v\n", revision, affected)
+		}
+	}
+}
+
+func TestAdvisoryVersionsMatchResolvedRanges(t *testing.T) {
+	setupTestPwd()
+	dep := gitDep("github.com/gopack-test/audit-range", "tag", "^1.2")
+	dep.Tag = "v1.2.3"
+	createSourceFixture(dep.Src(), "main.go", "package main\n")
+	lock := lockDeps(t, dep)
+
+	advisories := []*Advisory{{ID: "GPSA-2015-0004", Import: "github.com/gopack-test/audit-range", Summary: "header injection", Versions: []string{"v1.2.3"}}}
+	errors := lock.Audit(advisories)
+	if len(errors) != 1 || errors[0].Message != "github.com/gopack-test/audit-range at tag ^1.2 is affected by GPSA-2015-0004: header injection\n" {
+		t.Errorf("Expected the tag the range resolved to to be audited, found %v\n", errors)
+	}
+}
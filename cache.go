@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const GopackCache = ".gopack/cache"
+
+var (
+	// where clones are mirrored across projects, empty when there's no cache
+	cacheDir = ""
+	// never fetch from the network, set with --offline
+	offlineMode = false
+)
+
+// Scms that can clone a dep from the mirror in the cache and point it
+// back to its source afterwards.
+type CachedScm interface {
+	SetSource(path, source string) error
+	HasRevision(path, rev string) bool
+}
+
+// ~/.gopack/cache unless GOPACK_CACHE says otherwise.
+func defaultCacheDir() string {
+	if dir := os.Getenv("GOPACK_CACHE"); dir != "" {
+		return dir
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, GopackCache)
+	}
+	return ""
+}
+
+// Mirrors are keyed by scm so a git and an hg clone of the same import
+// don't end up in the same dir.
+func cachePath(d *Dep, scmType string) string {
+	return filepath.Join(cacheDir, strings.TrimPrefix(scmType, "."), d.Import)
+}
+
+// Whether the dep is cloned through the cache. Deps pinned to a date or
+// with a depth clone shallow, which git doesn't do from a local path.
+func usesCache(d *Dep, scm Scm) bool {
+	if _, ok := scm.(CachedScm); !ok {
+		return false
+	}
+	return cacheDir != "" && d.AsOf == "" && d.Depth == 0
+}
+
+// Bring the mirror of the dep up to date and return where it is. A
+// mirror that already has the commit the dep asks for isn't fetched,
+// --offline never fetches and fails when the mirror can't be used.
+func updateCache(d *Dep, scmType string, scm Scm) (string, error) {
+	mirror := cachePath(d, scmType)
+	cached := scm.(CachedScm)
+
+	if stage, err := os.Stat(scmStageDir(mirror, scmType)); err == nil && stage.IsDir() {
+		if d.CheckoutFlag == CommitFlag && cached.HasRevision(mirror, d.CheckoutSpec) {
+			return mirror, nil
+		}
+		if offlineMode {
+			if d.CheckoutFlag == CommitFlag {
+				return "", fmt.Errorf("%s is cached in %s without commit %s, can't fetch it --offline", d.Import, mirror, d.CheckoutSpec)
+			}
+			return mirror, nil
+		}
+		_, err = withRetries(func() error { return scm.Fetch(mirror) })
+		return mirror, err
+	}
+
+	if offlineMode {
+		return "", fmt.Errorf("%s isn't in the cache at %s, can't fetch it --offline", d.Import, mirror)
+	}
+
+	err := fetchWithFallback(d.Import, d.Sources(), func(source string) error {
+		fmtcolor(Gray, "      Caching: `%s` from %s\n", d.Import, maskCredentials(source))
+		if err := os.RemoveAll(mirror); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(mirror, 0755); err != nil {
+			return err
+		}
+		return runScm(scm.DownloadCommand(source, mirror))
+	})
+	return mirror, err
+}
+
+// Clone the dep from its mirror in the cache, leaving it pointed at the
+// source it would have been cloned from.
+func cloneFromCache(d *Dep, depPath, scmType string, scm Scm) error {
+	mirror, err := updateCache(d, scmType, scm)
+	if err != nil {
+		return err
+	}
+
+	fmtcolor(Gray, "  Downloading: `%s` from the cache\n", d.Import)
+	if err := os.RemoveAll(depPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(depPath, 0755); err != nil {
+		return err
+	}
+	if err := runScm(scm.DownloadCommand(mirror, depPath)); err != nil {
+		return err
+	}
+	return scm.(CachedScm).SetSource(depPath, d.Sources()[0])
+}
+
+func (g Git) SetSource(path, source string) error {
+	return runScmIn(path, "git", "remote", "set-url", "origin", source)
+}
+
+func (g Git) HasRevision(path, rev string) bool {
+	_, err := scmOutput(path, "git", "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	return err == nil
+}
+
+func (h Hg) SetSource(path, source string) error {
+	hgrc := fmt.Sprintf("[paths]\ndefault = %s\n", source)
+	return ioutil.WriteFile(filepath.Join(path, HiddenHg, "hgrc"), []byte(hgrc), 0644)
+}
+
+func (h Hg) HasRevision(path, rev string) bool {
+	_, err := scmOutput(path, "hg", "log", "-r", rev, "--template", "{node}")
+	return err == nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Clones create the scm dir in their target, rev-parse --verify only
+// finds the commits listed.
+func stubCachedGit(ran *[]string, commits ...string) func() {
+	return recordCommands(ran, commandStubs{
+		"rev-parse": func(cmd *exec.Cmd) ([]byte, error) {
+			for _, commit := range commits {
+				if cmd.Args[len(cmd.Args)-1] == commit+"^{commit}" {
+					return []byte(commit), nil
+				}
+			}
+			return nil, &exec.ExitError{}
+		},
+	})
+}
+
+func stubCacheDir() func() {
+	orig := cacheDir
+	cacheDir, _ = ioutil.TempDir("", "gopack-cache-")
+	return func() { cacheDir = orig; offlineMode = false }
+}
+
+func TestDepsAreClonedThroughTheCache(t *testing.T) {
+	setupTestPwd()
+	defer stubCacheDir()()
+	ran := []string{}
+	defer stubCachedGit(&ran)()
+
+	dep := gitDep("github.com/gopack-test/cached", "branch", "master")
+	if err := dep.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	mirror := path.Join(cacheDir, "git", "github.com/gopack-test/cached")
+	expected := []string{
+		"git clone https://github.com/gopack-test/cached.git " + mirror,
+		"git clone " + mirror + " " + dep.Src(),
+		"git remote set-url origin https://github.com/gopack-test/cached.git",
+	}
+	if strings.Join(ran, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected\n%s\nbut ran\n%s\n", strings.Join(expected, "\n"), strings.Join(ran, "\n"))
+	}
+
+	// a second project only fetches the mirror
+	setupTestPwd()
+	ran = []string{}
+	if err := gitDep("github.com/gopack-test/cached", "branch", "master").Get(); err != nil {
+		t.Fatal(err)
+	}
+	if ran[0] != "git fetch" || !strings.HasPrefix(ran[1], "git clone "+mirror) {
+		t.Errorf("Expected the mirror to be fetched and cloned but ran %v\n", ran)
+	}
+}
+
+func TestCachedCommitsAreNotFetched(t *testing.T) {
+	setupTestPwd()
+	defer stubCacheDir()()
+	ran := []string{}
+	defer stubCachedGit(&ran, "182cae2")()
+
+	createPath(path.Join(cacheDir, "git", "github.com/gopack-test/cached-commit", HiddenGit))
+	if err := gitDep("github.com/gopack-test/cached-commit", "commit", "182cae2").Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cmd := range ran {
+		if cmd == "git fetch" || strings.Contains(cmd, "https://") && strings.HasPrefix(cmd, "git clone") {
+			t.Errorf("Expected the cached commit not to be fetched but ran %s\n", cmd)
+		}
+	}
+}
+
+func TestOfflineFailsWithoutTheCache(t *testing.T) {
+	setupTestPwd()
+	defer stubCacheDir()()
+	ran := []string{}
+	defer stubCachedGit(&ran, "182cae2")()
+	offlineMode = true
+
+	err := gitDep("github.com/gopack-test/uncached", "branch", "master").Get()
+	if err == nil || !strings.Contains(err.Error(), "isn't in the cache") {
+		t.Errorf("Expected a dep missing from the cache to fail offline but was %v\n", err)
+	}
+
+	createPath(path.Join(cacheDir, "git", "github.com/gopack-test/stale-cache", HiddenGit))
+	err = gitDep("github.com/gopack-test/stale-cache", "commit", "def456").Get()
+	if err == nil || !strings.Contains(err.Error(), "without commit def456") {
+		t.Errorf("Expected a commit missing from the cache to fail offline but was %v\n", err)
+	}
+
+	if err := gitDep("github.com/gopack-test/stale-cache", "commit", "182cae2").Get(); err != nil {
+		t.Errorf("Expected a cached commit to be cloned offline but was %s\n", err)
+	}
+	for _, cmd := range ran {
+		if strings.Contains(cmd, "https://") && !strings.HasPrefix(cmd, "git remote") || cmd == "git fetch" {
+			t.Errorf("Expected nothing to be fetched offline but ran %s\n", cmd)
+		}
+	}
+}
+
+func TestCacheIsKeyedByScm(t *testing.T) {
+	defer stubCacheDir()()
+
+	git := &Dep{Import: "example.com/gopack-test/both"}
+	if cachePath(git, HiddenGit) == cachePath(git, HiddenHg) {
+		t.Errorf("Expected git and hg mirrors of the same import to differ\n")
+	}
+}
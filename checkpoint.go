@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const GopackCheckpoint = ".gopack/checkpoint"
+
+var (
+	// record every dep once it's installed, set with --checkpoint
+	checkpointDeps = false
+	// skip the deps an interrupted install recorded, set with --resume
+	resumeDeps = false
+	checkpoint *Lock
+)
+
+func checkpointPath() string {
+	return filepath.Join(pwd, GopackCheckpoint)
+}
+
+// A checkpoint is a lock of the deps installed so far. Resuming picks up
+// the one an interrupted install left behind, otherwise it starts empty.
+func OpenCheckpoint(resume bool) (*Lock, error) {
+	if !resume {
+		return NewLock(), nil
+	}
+	return readLockFile(checkpointPath())
+}
+
+// Whether an earlier install got the dep to the checkout it asks for, and
+// the vendored copy hasn't changed since.
+func (l *Lock) Done(d *Dep) bool {
+	done := l.Deps[d.Import]
+	if done == nil || done.Checkout != d.Checkout() {
+		return false
+	}
+	hash, err := contentHash(d.Src())
+	return err == nil && hash == done.Hash
+}
+
+// Record the dep as installed, writing the checkpoint straight away so
+// it survives the install being interrupted.
+func (l *Lock) Record(d *Dep) error {
+	if _, err := os.Stat(d.Src()); os.IsNotExist(err) {
+		return nil
+	}
+	hash, err := contentHash(d.Src())
+	if err != nil {
+		return err
+	}
+	l.Deps[d.Import] = &LockedDep{
+		Import:   d.Import,
+		Checkout: d.Checkout(),
+		Revision: d.Revision,
+		Hash:     hash,
+	}
+	return l.writeFile(checkpointPath())
+}
+
+// Once every dep is installed there's nothing left to resume.
+func clearCheckpoint() error {
+	checkpoint = nil
+	if err := os.Remove(checkpointPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
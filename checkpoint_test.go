@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func checkpointedDeps(imports ...string) *Dependencies {
+	deps := &Dependencies{ImportGraph: NewGraph()}
+	for _, importPath := range imports {
+		dep := gitDep(importPath, "branch", "master")
+		deps.DepList = append(deps.DepList, dep)
+		deps.Imports = append(deps.Imports, importPath)
+		deps.ImportGraph.Insert(dep)
+	}
+	return deps
+}
+
+func TestResumeSkipsCheckpointedDeps(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	defer func() { checkpoint = nil }()
+
+	cloned := []string{}
+	defer stubGitWithDeps(&cloned, map[string][]string{
+		"https://github.com/gopack-test/resume-b.git": {"github.com/gopack-test/resume-b-child"},
+	})()
+
+	// the install is interrupted after the first two deps
+	checkpoint, _ = OpenCheckpoint(false)
+	if err := loadTransitiveDependencies(checkpointedDeps("github.com/gopack-test/resume-a", "github.com/gopack-test/resume-b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(checkpointPath()); err != nil {
+		t.Fatalf("Expected a checkpoint to be written: %s\n", err)
+	}
+
+	cloned = cloned[:0]
+	checkpoint, _ = OpenCheckpoint(true)
+	if len(checkpoint.Deps) != 3 {
+		t.Errorf("Expected the 2 deps and the transitive one to be checkpointed, found %d\n", len(checkpoint.Deps))
+	}
+
+	deps := checkpointedDeps("github.com/gopack-test/resume-a", "github.com/gopack-test/resume-b", "github.com/gopack-test/resume-c")
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "https://github.com/gopack-test/resume-c.git checkout master"
+	if strings.Join(cloned, " ") != expected {
+		t.Errorf("Expected only %s to be fetched, ran %v\n", expected, cloned)
+	}
+	if deps.ImportGraph.Search("github.com/gopack-test/resume-b-child") == nil {
+		t.Errorf("Expected the transitive deps of resumed deps to still be loaded\n")
+	}
+
+	if err := clearCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(checkpointPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint to be cleared\n")
+	}
+}
+
+func TestResumeFetchesChangedDeps(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	defer func() { checkpoint = nil }()
+
+	cloned := []string{}
+	defer stubGitWithDeps(&cloned, map[string][]string{})()
+
+	checkpoint, _ = OpenCheckpoint(false)
+	if err := loadTransitiveDependencies(checkpointedDeps("github.com/gopack-test/resume-changed")); err != nil {
+		t.Fatal(err)
+	}
+
+	cloned = cloned[:0]
+	checkpoint, _ = OpenCheckpoint(true)
+	deps := checkpointedDeps("github.com/gopack-test/resume-changed")
+	deps.DepList[0].CheckoutSpec = "develop"
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cloned) == 0 || cloned[len(cloned)-1] != "checkout develop" {
+		t.Errorf("Expected a dep whose checkout changed to be installed again, ran %v\n", cloned)
+	}
+}
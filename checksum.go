@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// Bumped whenever what goes into the checksum changes, so a checksum
+// written by an older gopack reads as modified and everything is
+// resolved again once.
+const ChecksumVersion = "2"
+
+// Keys summed through what they were parsed into rather than as written.
+var parsedKeys = map[string]bool{"deps": true, "dev-deps": true, "override": true, "include": true}
+
+// Write the config in a canonical form: keys sorted, deps keyed by their
+// import whatever their name, includes merged and vars replaced, except
+// those from the environment in sources. Configs that only differ in order, names or
+// formatting write the same, whatever the environment.
+func (c *Config) writeCanonical(w io.Writer) {
+	fmt.Fprintf(w, "checksum %s\n", ChecksumVersion)
+	if c.tree != nil {
+		keys := c.tree.Keys()
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !parsedKeys[key] {
+				writeCanonicalValue(w, key, c.tree.Get(key))
+			}
+		}
+	}
+	c.writeCanonicalDeps(w, "deps", c.DepsTree)
+	c.writeCanonicalDeps(w, "dev-deps", c.DevDepsTree)
+	c.writeCanonicalDeps(w, "override", c.OverridesTree)
+}
+
+func (c *Config) writeCanonicalDeps(w io.Writer, section string, deps *toml.TomlTree) {
+	if deps == nil {
+		return
+	}
+	byImport := make(map[string]*toml.TomlTree)
+	imports := []string{}
+	for _, key := range deps.Keys() {
+		dep, ok := deps.Get(key).(*toml.TomlTree)
+		if !ok {
+			continue
+		}
+		importPath, _ := dep.Get("import").(string)
+		if importPath == "" {
+			importPath = "name " + key
+		}
+		byImport[importPath] = dep
+		imports = append(imports, importPath)
+	}
+	sort.Strings(imports)
+	for _, importPath := range imports {
+		dep := byImport[importPath]
+		keys := dep.Keys()
+		sort.Strings(keys)
+		for _, k := range keys {
+			value := dep.Get(k)
+			if credentialKeys[k] {
+				value = asWritten(value, c.envSources[dep])
+			}
+			writeCanonicalValue(w, fmt.Sprintf("%s[%q].%s", section, importPath, k), value)
+		}
+	}
+}
+
+// A source as written when it took a var from the environment.
+func asWritten(value interface{}, written map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if w, ok := written[v]; ok {
+			return w
+		}
+	case []interface{}:
+		sources := make([]interface{}, len(v))
+		for i, e := range v {
+			sources[i] = asWritten(e, written)
+		}
+		return sources
+	}
+	return value
+}
+
+// Tables are written key by key in order, arrays keep theirs since the
+// order of mirrors or rewrites matters.
+func writeCanonicalValue(w io.Writer, key string, value interface{}) {
+	switch v := value.(type) {
+	case *toml.TomlTree:
+		keys := v.Keys()
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeCanonicalValue(w, key+"."+k, v.Get(k))
+		}
+	case []*toml.TomlTree:
+		for i, t := range v {
+			writeCanonicalValue(w, fmt.Sprintf("%s[%d]", key, i), t)
+		}
+	case []interface{}:
+		for i, e := range v {
+			writeCanonicalValue(w, fmt.Sprintf("%s[%d]", key, i), e)
+		}
+	default:
+		fmt.Fprintf(w, "%s=%#v\n", key, v)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// What gp clean removes, refusing a vendor dir that isn't inside the
+// project, a GOPATH shared with other projects for instance.
+func cleanPaths() ([]string, error) {
+	vendor := filepath.Join(pwd, VendorDir)
+	rel, err := filepath.Rel(pwd, vendor)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("Refusing to clean %s, it's not inside the project %s", vendor, pwd)
+	}
+	return []string{vendor, filepath.Join(pwd, GopackChecksum)}, nil
+}
+
+// Remove the vendored deps and the config checksum, so the next
+// installdeps fetches everything again and links the project back in.
+func clean() error {
+	paths, err := cleanPaths()
+	if err != nil {
+		return err
+	}
+	// take the project out of the vendor tree first, a link is removed
+	// without touching the project and a copy along with its files
+	for _, link := range projectLinks(filepath.Join(paths[0], "src")) {
+		kind := "link to the project"
+		if isProjectCopy(link) {
+			kind = "copy of the project"
+		}
+		if dryRun {
+			fmtcolor(Gray, " Would remove: %s, the %s\n", link, kind)
+			continue
+		}
+		if err := os.RemoveAll(link); err != nil {
+			return err
+		}
+		fmtcolor(Gray, "      Removed: %s, the %s\n", link, kind)
+	}
+
+	for _, path := range paths {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		}
+		if dryRun {
+			fmtcolor(Gray, " Would remove: %s\n", path)
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		fmtcolor(Gray, "      Removed: %s\n", path)
+	}
+	return nil
+}
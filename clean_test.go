@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func cleanFixture() (string, string) {
+	setupTestPwd()
+	vendor := filepath.Join(pwd, VendorDir)
+	createSourceFixture(filepath.Join(vendor, "src", "github.com/gopack-test/clean"), "main.go", "package clean\n")
+	os.Symlink(pwd, filepath.Join(vendor, "src", "github.com/gopack-test/project"))
+	createSourceFixture(filepath.Join(pwd, GopackDir), "checksum", "c0ffee")
+	createSourceFixture(filepath.Join(pwd, GopackDir), "gopack.lock", "")
+	return vendor, filepath.Join(pwd, GopackChecksum)
+}
+
+func TestCleanRemovesVendorAndChecksum(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	vendor, checksum := cleanFixture()
+
+	if err := clean(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{vendor, checksum} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed\n", path)
+		}
+	}
+	if _, err := os.Stat(pwd); err != nil {
+		t.Errorf("Expected the project linked into the vendor dir to be kept, got %v\n", err)
+	}
+	if _, err := os.Stat(lockPath()); err != nil {
+		t.Errorf("Expected the lock to be kept, got %v\n", err)
+	}
+}
+
+func TestCleanDryRunRemovesNothing(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	vendor, checksum := cleanFixture()
+
+	dryRun = true
+	defer func() { dryRun = false }()
+
+	out := captureStdout(func() {
+		if err := clean(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	for _, path := range []string{vendor, checksum} {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("Expected %s to be kept with --dry-run\n", path)
+		}
+		if !strings.Contains(out, "Would remove: "+path) {
+			t.Errorf("Expected --dry-run to list %s, printed %s\n", path, out)
+		}
+	}
+}
+
+func TestCleanRefusesVendorOutsideProject(t *testing.T) {
+	for _, dir := range []string{"../shared-gopath", "."} {
+		restore := stubVendorDir(dir)
+		setupTestPwd()
+		if _, err := cleanPaths(); err == nil || !strings.HasPrefix(err.Error(), "Refusing to clean") {
+			t.Errorf("Expected a vendor dir at %s to not be cleaned, got %v\n", dir, err)
+		}
+		restore()
+	}
+}
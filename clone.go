@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fetch an import that isn't in any config into the vendor tree, with
+// the spec written the way a gopack.deps line is: an optional branch,
+// or branch, tag or commit and a ref.
+func cloneDependency(args []string) (*Dep, error) {
+	if len(args) == 0 || len(args) > 3 {
+		return nil, fmt.Errorf("Usage: gp clone <import> [branch | branch|tag|commit ref]")
+	}
+	tree, err := parseRequirements(strings.Join(args, " "))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{DepsTree: tree, Features: make(map[string]bool)}
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		return nil, err
+	}
+
+	dep := deps.DepList[0]
+	dep.Fetch(true)
+	if err := dep.resolveTagRange(nil); err != nil {
+		return nil, err
+	}
+	if err := updateDependency(deps, dep); err != nil {
+		return nil, err
+	}
+	return dep, nil
+}
+
+func cloneDep(args []string) error {
+	dep, err := cloneDependency(args)
+	if err != nil {
+		return err
+	}
+	fmtcolor(Green, "       Cloned: `%s` into %s\n", dep.Import, dep.Src())
+	return nil
+}
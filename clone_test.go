@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCloneFetchesImportAtSpec(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	ran := []string{}
+	defer recordCommands(&ran, commandStubs{
+		"get": func(cmd *exec.Cmd) ([]byte, error) {
+			createPath(path.Join(NewDependency(cmd.Args[len(cmd.Args)-1]).Src(), HiddenGit))
+			return nil, nil
+		},
+	})()
+
+	dep, err := cloneDependency([]string{"github.com/gopack-test/cloned", "tag", "v1.2.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(dep.Src(), HiddenGit)); err != nil {
+		t.Errorf("Expected github.com/gopack-test/cloned to be vendored: %s\n", err)
+	}
+	expected := []string{"go get -d -u github.com/gopack-test/cloned", "git checkout v1.2.0"}
+	if len(ran) < 2 || ran[0] != expected[0] || ran[1] != expected[1] {
+		t.Errorf("Expected %v, ran %v\n", expected, ran)
+	}
+}
+
+func TestCloneUsage(t *testing.T) {
+	if _, err := cloneDependency([]string{}); err == nil || !strings.HasPrefix(err.Error(), "Usage") {
+		t.Errorf("Expected the usage, got %v\n", err)
+	}
+}
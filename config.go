@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -11,8 +12,13 @@ import (
 	toml "github.com/pelletier/go-toml"
 )
 
+// skip linking the repository into the vendor tree, set with --no-self-link
+var noSelfLink = false
+
 type Config struct {
 	Checksum []byte
+	// the config as parsed, summed into the checksum
+	tree *toml.TomlTree
 	// Path to the configuration file.
 	Path string
 	// Name of your repository "github.com/d2fn/gopack" for instance.
@@ -21,33 +27,193 @@ type Config struct {
 	DepsTree *toml.TomlTree
 	// Development Dependencies tree
 	DevDepsTree *toml.TomlTree
+	// the names of the deps by section, in the order they're listed
+	depOrder map[string][]string
+	// the sources of each dep table that took a var from the
+	// environment, to what they are as written
+	envSources map[*toml.TomlTree]map[string]string
+	// Remove the vcs metadata of vendored dependencies after checkout.
+	StripVcs bool
+	// Features toggling the deps that declare them.
+	Features map[string]bool
+	// Config files the deps are merged from, local deps take precedence.
+	Includes []*Include
+	// Specs forced on transitive dependencies
+	OverridesTree *toml.TomlTree
+	// Rules redirecting sources, keyed by name
+	RewritesTree *toml.TomlTree
+	// the names of the rules in the order they are declared
+	rewriteOrder []string
+	// json file or url of the advisories installdeps --audit checks
+	Advisories string
+	// known_hosts file and host keys ssh hosts are checked against
+	KnownHostsFile string
+	HostKeys       []string
+	// where the deps are vendored, relative to the config
+	VendorDir string
+	// imports never fetched, nor the packages below them
+	Excludes []string
+	// the dep whose gopack.config this is, empty for the project's
+	Parent string
+}
+
+func NewConfig(dir string) (*Config, error) {
+	return NewConfigFile(filepath.Join(dir, "gopack.config"), dir)
+}
+
+// Load the config at path for the project in projectDir. What the config
+// names relative to itself is relative to the dir it's in, wherever the
+// project is.
+func NewConfigFile(path, projectDir string) (*Config, error) {
+	return loadConfig(path, projectDir, true)
+}
+
+// Load the gopack.config of a dep, its vars can't come from the environment.
+func NewDepConfig(dir string) (*Config, error) {
+	return loadConfig(filepath.Join(dir, "gopack.config"), dir, false)
 }
 
-func NewConfig(dir string) *Config {
-	config := &Config{Path: filepath.Join(dir, "gopack.config")}
+func loadConfig(path, projectDir string, env bool) (*Config, error) {
+	dir := filepath.Dir(path)
+	config := &Config{Path: path}
+
+	requirements, err := LoadRequirements(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// gopack.deps can be used on its own
+	data, err := ioutil.ReadFile(config.Path)
+	if os.IsNotExist(err) && requirements == nil {
+		return nil, &ConfigNotFoundError{dir, path}
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	t, err := loadToml(config.Path, data)
+	if err != nil {
+		return nil, err
+	}
+	config.tree = t
+
+	config.depOrder = make(map[string][]string)
+	if config.DepsTree, config.depOrder["deps"], err = loadDepsSection(t, "deps", data); err != nil {
+		return nil, err
+	}
+	if config.DevDepsTree, config.depOrder["dev-deps"], err = loadDepsSection(t, "dev-deps", data); err != nil {
+		return nil, err
+	}
+
+	// vars are only interpolated in the repo and deps of this config
+	vars, err := loadVars(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.interpolateDeps("deps", config.DepsTree, vars, env); err != nil {
+		return nil, err
+	}
+	if err := config.interpolateDeps("dev-deps", config.DevDepsTree, vars, env); err != nil {
+		return nil, err
+	}
+
+	if requirements != nil {
+		config.Includes = append(config.Includes, requirements)
+	}
 
-	t, err := toml.LoadFile(config.Path)
+	includes, err := loadIncludes(dir, includePaths(t), []string{config.Path})
 	if err != nil {
-		fail(err)
+		return nil, err
 	}
+	config.Includes = append(config.Includes, includes...)
 
-	if deps := t.Get("deps"); deps != nil {
-		config.DepsTree = deps.(*toml.TomlTree)
+	if len(config.Includes) > 0 {
+		config.mergeIncludes()
 	}
 
-	if deps := t.Get("dev-deps"); deps != nil {
-		config.DevDepsTree = deps.(*toml.TomlTree)
+	if overrides, ok := t.Get("override").(*toml.TomlTree); ok {
+		if err := config.interpolateDeps("override", overrides, vars, env); err != nil {
+			return nil, err
+		}
+		config.OverridesTree = overrides
+	}
+
+	if _, list := t.Get("rewrites").([]*toml.TomlTree); list || t.Get("[rewrites") != nil {
+		return nil, fmt.Errorf("Error reading %s: rewrites - [[rewrites]] entries aren't supported, list them as [rewrites.name] tables", config.Path)
+	}
+	if rewrites, ok := t.Get("rewrites").(*toml.TomlTree); ok {
+		config.RewritesTree = rewrites
+		config.rewriteOrder = tableOrder("rewrites", data)
+	}
+
+	if file, ok := t.Get("ssh.known_hosts").(string); ok {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		config.KnownHostsFile = file
+	}
+	if keys, ok := t.Get("ssh.host_keys").([]interface{}); ok {
+		for _, k := range keys {
+			if key, ok := k.(string); ok {
+				config.HostKeys = append(config.HostKeys, key)
+			}
+		}
 	}
 
 	if repo := t.Get("repo"); repo != nil {
-		config.Repository = repo.(string)
+		if config.Repository, err = interpolate(repo.(string), vars, env); err != nil {
+			return nil, fmt.Errorf("repo - %s", err)
+		}
+	}
+
+	if config.VendorDir, err = vendorDirOf(t, dir, projectDir); err != nil {
+		return nil, err
+	}
+
+	if excludes, ok := t.Get("exclude").([]interface{}); ok {
+		for _, e := range excludes {
+			if importPath, ok := e.(string); ok {
+				config.Excludes = append(config.Excludes, importPath)
+			}
+		}
+	}
+
+	if strip, ok := t.Get("strip_vcs").(bool); ok {
+		config.StripVcs = strip
+	}
+
+	if advisories, ok := t.Get("advisories").(string); ok {
+		config.Advisories = advisories
 	}
 
-	return config
+	config.Features = make(map[string]bool)
+	if features, ok := t.Get("features").(*toml.TomlTree); ok {
+		for _, k := range features.Keys() {
+			if enabled, ok := features.Get(k).(bool); ok {
+				config.Features[k] = enabled
+			}
+		}
+	}
+
+	config.sum()
+	return config, nil
+}
+
+// Later includes take precedence over earlier ones.
+func (c *Config) mergeIncludes() {
+	deps := []*toml.TomlTree{c.DepsTree}
+	devDeps := []*toml.TomlTree{c.DevDepsTree}
+	for i := len(c.Includes) - 1; i >= 0; i-- {
+		deps = append(deps, c.Includes[i].DepsTree)
+		devDeps = append(devDeps, c.Includes[i].DevDepsTree)
+		for _, section := range []string{"deps", "dev-deps"} {
+			c.depOrder[section] = append(c.depOrder[section], c.Includes[i].depOrder[section]...)
+		}
+	}
+	c.DepsTree = mergeDepsTrees(deps...)
+	c.DevDepsTree = mergeDepsTrees(devDeps...)
 }
 
-func (c *Config) InitRepo(importGraph *Graph) {
-	if c.Repository != "" {
+func (c *Config) InitRepo(importGraph *Graph) error {
+	if c.Repository != "" && !noSelfLink && !graphOnly {
 		src := filepath.Join(pwd, VendorDir, "src")
 		os.MkdirAll(src, 0755)
 
@@ -56,14 +222,14 @@ func (c *Config) InitRepo(importGraph *Graph) {
 		os.MkdirAll(base, 0755)
 
 		repo := filepath.Join(src, c.Repository)
-		err := os.Symlink(pwd, repo)
-		if err != nil && !os.IsExist(err) {
-			fail(err)
+		if err := linkProject(pwd, repo); err != nil {
+			return err
 		}
 
 		dependency := NewDependency(c.Repository)
 		importGraph.Insert(dependency)
 	}
+	return nil
 }
 
 func (c *Config) modifiedChecksum() bool {
@@ -71,93 +237,163 @@ func (c *Config) modifiedChecksum() bool {
 	return (err != nil && os.IsNotExist(err)) || !bytes.Equal(dat, c.checksum())
 }
 
-func (c *Config) WriteChecksum() {
+func (c *Config) WriteChecksum() error {
 	os.MkdirAll(filepath.Join(pwd, GopackDir), 0755)
-	err := ioutil.WriteFile(c.checksumPath(), c.checksum(), 0644)
-
-	if err != nil {
-		fail(err)
-	}
+	return ioutil.WriteFile(c.checksumPath(), c.checksum(), 0644)
 }
 
 func (c *Config) checksumPath() string {
 	return filepath.Join(pwd, GopackChecksum)
 }
 
+// NewConfig sums the config up front, configs built by hand are summed
+// on first use.
 func (c *Config) checksum() []byte {
 	if c.Checksum == nil {
-		dat, err := ioutil.ReadFile(c.Path)
-		if err != nil {
-			fail(err)
-		}
-
-		h := md5.New()
-		h.Write(dat)
-		c.Checksum = h.Sum(nil)
+		c.sum()
 	}
-	return []byte(hex.EncodeToString(c.Checksum))
+	return []byte(ChecksumVersion + ":" + hex.EncodeToString(c.Checksum))
 }
 
-func (c *Config) LoadDependencyModel(importGraph *Graph) (deps *Dependencies, err error) {
-	totalDeps := 0
-
-	if c.DepsTree != nil {
-		totalDeps += len(c.DepsTree.Keys())
-	}
-	if c.DevDepsTree != nil {
-		totalDeps += len(c.DevDepsTree.Keys())
-	}
+func (c *Config) sum() {
+	h := md5.New()
+	c.writeCanonical(h)
+	c.Checksum = h.Sum(nil)
+}
 
-	if totalDeps == 0 {
-		return
-	}
+func (c *Config) LoadDependencyModel(importGraph *Graph) (deps *Dependencies, err error) {
+	totalDeps := countDeps(c.DepsTree) + countDeps(c.DevDepsTree)
 
 	deps = new(Dependencies)
-	deps.Imports = make([]string, totalDeps)
-	deps.Keys = make([]string, totalDeps)
-	deps.DepList = make([]*Dep, totalDeps)
+	deps.Imports = make([]string, 0, totalDeps)
+	deps.Keys = make([]string, 0, totalDeps)
+	deps.DepList = make([]*Dep, 0, totalDeps)
 	deps.ImportGraph = importGraph
+	deps.StripVcs = c.StripVcs
+	deps.Excludes = c.Excludes
+
+	if deps.Overrides, err = c.loadOverrides(); err != nil {
+		return nil, err
+	}
+	if deps.Rewrites, err = c.loadRewrites(); err != nil {
+		return nil, err
+	}
 
 	modifiedChecksum := c.modifiedChecksum()
 
-	if err := addDepsTree(deps, c.DepsTree, modifiedChecksum, 0); err != nil {
+	if err := c.addDepsTree(deps, c.DepsTree, modifiedChecksum, false); err != nil {
 		return nil, err
 	}
-	if err := addDepsTree(deps, c.DevDepsTree, modifiedChecksum, len(c.DepsTree.Keys())); err != nil {
+	if err := c.addDepsTree(deps, c.DevDepsTree, modifiedChecksum, true); err != nil {
+		return nil, err
+	}
+	if !modifiedChecksum {
+		if reason := c.desynchronized(deps); reason != "" {
+			fmtcolor(Yellow, "      Warning: %s, a previous run didn't finish, resolving again\n", reason)
+			for _, dep := range deps.DepList {
+				dep.Fetch(true)
+			}
+		}
+	}
+	if err := deps.applyRewrites(); err != nil {
 		return nil, err
 	}
 	return deps, nil
 }
 
-func addDepsTree(deps *Dependencies, depsTree *toml.TomlTree, modifiedChecksum bool, pos int) error {
+// A feature is enabled in the config or with --enable.
+func (c *Config) FeatureEnabled(feature string) bool {
+	for _, f := range enabledFeatures {
+		if f == feature {
+			return true
+		}
+	}
+	return c.Features[feature]
+}
+
+// Only tables are dependencies, an empty [deps] header has none.
+func countDeps(depsTree *toml.TomlTree) int {
+	total := 0
+	if depsTree != nil {
+		for _, k := range depsTree.Keys() {
+			if _, ok := depsTree.Get(k).(*toml.TomlTree); ok {
+				total++
+			}
+		}
+	}
+	return total
+}
+
+func (c *Config) addDepsTree(deps *Dependencies, depsTree *toml.TomlTree, modifiedChecksum bool, dev bool) error {
 	if depsTree == nil {
 		return nil
 	}
-	for _, k := range depsTree.Keys() {
 
-		depTree := depsTree.Get(k).(*toml.TomlTree)
-		d := NewDependency(depTree.Get("import").(string))
+	section := "deps"
+	if dev {
+		section = "dev-deps"
+	}
+
+	for _, k := range orderedKeys(depsTree, c.depOrder[section]) {
+
+		depTree, ok := depsTree.Get(k).(*toml.TomlTree)
+		if !ok {
+			return fmt.Errorf("%s.%s - expected a dependency table", section, k)
+		}
+
+		importPath, ok := depTree.Get("import").(string)
+		if !ok || importPath == "" {
+			return fmt.Errorf("%s.%s - missing import", section, k)
+		}
+
+		d := NewDependency(importPath)
+		d.Dev = dev
 
 		d.setScm(depTree)
 		d.setSource(depTree)
+		d.setMirrors(depTree)
+		d.envSources = c.envSources[depTree]
 
 		d.setCheckout(depTree, "branch", BranchFlag)
 		d.setCheckout(depTree, "commit", CommitFlag)
 		d.setCheckout(depTree, "tag", TagFlag)
+		d.setAsOf(depTree)
+		d.setDepth(depTree)
+		d.setSubmodules(depTree)
+		d.setPath(depTree, filepath.Dir(c.Path))
+		d.setGopkg()
+
+		d.setFeature(depTree)
+		d.setValidateCommand(depTree)
+		d.setGenerateCommand(depTree)
+		d.setPostInstallCommand(depTree)
+		d.setTrusted(depTree)
+		d.setPriority(depTree)
+
+		if err := d.setTransitive(depTree); err != nil {
+			return err
+		}
 
 		if err := d.Validate(); err != nil {
 			return err
 		}
 
-		d.Fetch(modifiedChecksum)
+		if d.Feature != "" && !c.FeatureEnabled(d.Feature) {
+			continue
+		}
 
-		deps.Keys[pos] = k
-		deps.Imports[pos] = d.Import
-		deps.DepList[pos] = d
+		if excluded(d.Import, c.Excludes) {
+			fmtcolor(Gray, "     Excluded: `%s`\n", d.Import)
+			continue
+		}
+
+		d.Fetch(modifiedChecksum)
 
-		pos++
+		deps.Keys = append(deps.Keys, k)
+		deps.Imports = append(deps.Imports, d.Import)
+		deps.DepList = append(deps.DepList, d)
 
-		deps.ImportGraph.Insert(d)
+		deps.ImportGraph.Insert(d, c.Parent)
 	}
 	return nil
 }
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"strings"
 	"testing"
 )
 
@@ -17,7 +21,13 @@ func setupTestConfig(fixture string) *Config {
 	setupEnv()
 
 	createFixtureConfig(pwd, fixture)
-	return NewConfig(pwd)
+	return loadTestConfig(pwd)
+}
+
+func loadTestConfig(dir string) *Config {
+	config, err := NewConfig(dir)
+	check(err)
+	return config
 }
 
 func TestNewConfig(t *testing.T) {
@@ -38,6 +48,22 @@ repo = "github.com/d2fn/gopack"
 	}
 }
 
+func TestNewConfigReturnsErrors(t *testing.T) {
+	setupTestPwd()
+	setupEnv()
+	createFixtureConfig(pwd, `
+repo = "github.com/gopack-test/${GOPACK_TEST_UNSET}"
+`)
+
+	config, err := NewConfig(pwd)
+	if err == nil || config != nil {
+		t.Fatalf("Expected the unknown var to be returned as an error, got %v\n", err)
+	}
+	if !strings.HasPrefix(err.Error(), "repo - unknown var ${GOPACK_TEST_UNSET}") {
+		t.Errorf("Expected the error to name the key, got %s\n", err)
+	}
+}
+
 func TestInitRepoWithoutRepo(t *testing.T) {
 	config := setupTestConfig(`
 [deps.testgopack]
@@ -46,7 +72,7 @@ func TestInitRepoWithoutRepo(t *testing.T) {
 `)
 
 	graph := NewGraph()
-	config.InitRepo(graph)
+	check(config.InitRepo(graph))
 
 	src := path.Join(pwd, VendorDir, "src")
 	_, err := os.Stat(src)
@@ -60,7 +86,7 @@ func TestInitRepo(t *testing.T) {
 	config := setupTestConfig(`repo = "github.com/d2fn/gopack"`)
 
 	graph := NewGraph()
-	config.InitRepo(graph)
+	check(config.InitRepo(graph))
 
 	dep := path.Join(pwd, VendorDir, "src", "github.com", "d2fn", "gopack")
 	stat, err := os.Stat(dep)
@@ -74,6 +100,25 @@ func TestInitRepo(t *testing.T) {
 	}
 }
 
+func TestInitRepoWithNoSelfLink(t *testing.T) {
+	config := setupTestConfig(`repo = "github.com/gopack-test/self"`)
+
+	noSelfLink = true
+	defer func() { noSelfLink = false }()
+
+	graph := NewGraph()
+	check(config.InitRepo(graph))
+
+	dep := path.Join(pwd, VendorDir, "src", "github.com", "gopack-test", "self")
+	if _, err := os.Lstat(dep); !os.IsNotExist(err) {
+		t.Errorf("Expected repository %s to not be linked with --no-self-link\n", config.Repository)
+	}
+
+	if graph.Search(config.Repository) != nil {
+		t.Errorf("Expected repository %s to not be in the dependencies graph\n", config.Repository)
+	}
+}
+
 func TestWriteChecksum(t *testing.T) {
 	config := setupTestConfig(`
 [deps.testgopack]
@@ -81,7 +126,7 @@ func TestWriteChecksum(t *testing.T) {
   branch = "master"
 `)
 
-	config.WriteChecksum()
+	check(config.WriteChecksum())
 
 	path := path.Join(pwd, GopackChecksum)
 	_, err := ioutil.ReadFile(path)
@@ -108,7 +153,7 @@ func TestFetchDependenciesWithoutChanges(t *testing.T) {
   import = "github.com/calavera/testGoPack"
   commit = "182cae2ee3926a960223d8db4998aa9d57c89788"
 `)
-	config.WriteChecksum()
+	check(config.WriteChecksum())
 
 	deps, _ := config.LoadDependencyModel(NewGraph())
 	if deps.AnyDepsNeedFetching() {
@@ -122,7 +167,7 @@ func TestFetchDependenciesWithBranch(t *testing.T) {
   import = "github.com/calavera/testGoPack"
   branch = "master"
 `)
-	config.WriteChecksum()
+	check(config.WriteChecksum())
 
 	deps, _ := config.LoadDependencyModel(NewGraph())
 	if len(deps.DepList) != 1 {
@@ -137,7 +182,7 @@ func TestFetchDependenciesWithChanges(t *testing.T) {
   commit = "182cae2ee3926a960223d8db4998aa9d57c89788"
 `)
 
-	config.WriteChecksum()
+	check(config.WriteChecksum())
 	config.Checksum = nil
 
 	fixture := `
@@ -165,7 +210,7 @@ func TestFetchWithCommitSpecs(t *testing.T) {
   import = "github.com/calavera/foo"
   branch = "master"
 `)
-	config.WriteChecksum()
+	check(config.WriteChecksum())
 
 	deps, _ := config.LoadDependencyModel(NewGraph())
 	if deps.DepList[0].fetch {
@@ -185,7 +230,7 @@ func TestFetchWithTagSpecs(t *testing.T) {
   import = "github.com/calavera/foo"
   branch = "master"
 `)
-	config.WriteChecksum()
+	check(config.WriteChecksum())
 
 	deps, _ := config.LoadDependencyModel(NewGraph())
 	if deps.DepList[0].fetch {
@@ -205,7 +250,7 @@ func TestFetchWithMixedSpecsIgnoringOrder(t *testing.T) {
   import = "github.com/calavera/testGoPack"
   commit = "182cae2ee3926a960223d8db4998aa9d57c89788"
 `)
-	config.WriteChecksum()
+	check(config.WriteChecksum())
 
 	deps, _ := config.LoadDependencyModel(NewGraph())
 	if !deps.DepList[0].fetch {
@@ -215,3 +260,498 @@ func TestFetchWithMixedSpecsIgnoringOrder(t *testing.T) {
 		t.Errorf("Expected to fetch the branch dependencies")
 	}
 }
+
+const featureFixture = `
+[features]
+  experimental = %s
+
+[deps.testgopack]
+  import = "github.com/calavera/testGoPack"
+  branch = "master"
+[deps.foo]
+  import = "github.com/calavera/foo"
+  branch = "master"
+  feature = "experimental"
+`
+
+func TestFeatureDisabledExcludesDep(t *testing.T) {
+	config := setupTestConfig(fmt.Sprintf(featureFixture, "false"))
+
+	graph := NewGraph()
+	deps, _ := config.LoadDependencyModel(graph)
+	if len(deps.DepList) != 1 || deps.DepList[0].Import != "github.com/calavera/testGoPack" {
+		t.Errorf("Expected the experimental dependency to be excluded, found %s\n", deps)
+	}
+
+	if graph.Search("github.com/calavera/foo") != nil {
+		t.Errorf("Expected the experimental dependency to not be in the graph\n")
+	}
+}
+
+func TestFeatureEnabledInConfigIncludesDep(t *testing.T) {
+	config := setupTestConfig(fmt.Sprintf(featureFixture, "true"))
+
+	deps, _ := config.LoadDependencyModel(NewGraph())
+	if len(deps.DepList) != 2 {
+		t.Errorf("Expected the experimental dependency to be included, found %s\n", deps)
+	}
+}
+
+func TestFeatureEnabledWithFlagIncludesDep(t *testing.T) {
+	config := setupTestConfig(fmt.Sprintf(featureFixture, "false"))
+
+	enabledFeatures.Set("experimental")
+	defer func() { enabledFeatures = listFlag{} }()
+
+	graph := NewGraph()
+	deps, _ := config.LoadDependencyModel(graph)
+	if len(deps.DepList) != 2 {
+		t.Errorf("Expected --enable to include the experimental dependency, found %s\n", deps)
+	}
+
+	if graph.Search("github.com/calavera/foo") == nil {
+		t.Errorf("Expected the experimental dependency to be in the graph\n")
+	}
+}
+
+func checkNoNilDeps(t *testing.T, deps *Dependencies, expected int) {
+	if deps == nil {
+		t.Fatal("Expected dependencies to be loaded")
+	}
+
+	if len(deps.DepList) != expected || len(deps.Imports) != expected || len(deps.Keys) != expected {
+		t.Fatalf("Expected %d dependencies, found %s\n", expected, deps)
+	}
+
+	for i, dep := range deps.DepList {
+		if dep == nil || deps.Imports[i] == "" || deps.Keys[i] == "" {
+			t.Errorf("Expected no empty slot at %d in %s\n", i, deps)
+		}
+	}
+
+	deps.VisitDeps(func(dep *Dep) error { _ = dep.Import; return nil })
+}
+
+func TestLoadWithEmptyDevDeps(t *testing.T) {
+	config := setupTestConfig(`
+[deps.testgopack]
+  import = "github.com/calavera/testGoPack"
+  branch = "master"
+
+[dev-deps]
+`)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoNilDeps(t, deps, 1)
+}
+
+func TestLoadWithEmptyDeps(t *testing.T) {
+	config := setupTestConfig(`
+[deps]
+
+[dev-deps.testgopack]
+  import = "github.com/calavera/testGoPack"
+  branch = "master"
+`)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoNilDeps(t, deps, 1)
+
+	if !deps.DepList[0].Dev {
+		t.Errorf("Expected %s to be a dev dependency\n", deps.DepList[0].Import)
+	}
+}
+
+func TestLoadWithOnlyDevDeps(t *testing.T) {
+	config := setupTestConfig(`
+[dev-deps.testgopack]
+  import = "github.com/calavera/testGoPack"
+  branch = "master"
+`)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoNilDeps(t, deps, 1)
+}
+
+func TestLoadWithBothEmpty(t *testing.T) {
+	config := setupTestConfig(`
+[deps]
+[dev-deps]
+`)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoNilDeps(t, deps, 0)
+}
+
+func TestLoadRejectsDepWithoutImport(t *testing.T) {
+	config := setupTestConfig(`
+[deps.testgopack]
+  branch = "master"
+`)
+
+	if _, err := config.LoadDependencyModel(NewGraph()); err == nil {
+		t.Error("Expected a dependency without an import to be rejected")
+	}
+}
+
+const transitiveFixture = `
+[deps.full]
+  import = "github.com/gopack-test/full"
+[deps.none]
+  import = "github.com/gopack-test/none"
+  transitive = "none"
+[deps.one]
+  import = "github.com/gopack-test/one"
+  transitive = 1
+`
+
+func TestLoadTransitiveDepth(t *testing.T) {
+	config := setupTestConfig(transitiveFixture)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{
+		"github.com/gopack-test/full": TransitiveFull,
+		"github.com/gopack-test/none": TransitiveNone,
+		"github.com/gopack-test/one":  1,
+	}
+	for _, dep := range deps.DepList {
+		if dep.Transitive != expected[dep.Import] {
+			t.Errorf("Expected %s to have transitive depth %d, was %d\n", dep.Import, expected[dep.Import], dep.Transitive)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidTransitive(t *testing.T) {
+	config := setupTestConfig(`
+[deps.some]
+  import = "github.com/gopack-test/some"
+  transitive = "some"
+`)
+
+	if _, err := config.LoadDependencyModel(NewGraph()); err == nil {
+		t.Errorf("Expected an invalid transitive value to be rejected\n")
+	}
+}
+
+const sharedDepsFixture = `
+[deps.shared]
+  import = "github.com/gopack-test/shared"
+  tag = "v1.0"
+[deps.overridden]
+  import = "github.com/gopack-test/overridden"
+  tag = "v1.0"
+[deps.renamed]
+  import = "github.com/gopack-test/renamed"
+  tag = "v1.0"
+`
+
+const includingFixture = `
+include = ["shared-deps.config"]
+
+[deps.overridden]
+  import = "github.com/gopack-test/overridden"
+  tag = "v2.0"
+[deps.local-name]
+  import = "github.com/gopack-test/renamed"
+  tag = "v2.0"
+`
+
+func TestIncludeMergesDeps(t *testing.T) {
+	setupTestPwd()
+	setupEnv()
+	check(ioutil.WriteFile(path.Join(pwd, "shared-deps.config"), []byte(sharedDepsFixture), 0644))
+	createFixtureConfig(pwd, includingFixture)
+
+	deps, err := loadTestConfig(pwd).LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"github.com/gopack-test/shared":     "v1.0",
+		"github.com/gopack-test/overridden": "v2.0",
+		"github.com/gopack-test/renamed":    "v2.0",
+	}
+	if len(deps.DepList) != len(expected) {
+		t.Errorf("Expected %d deps, found %s\n", len(expected), deps)
+	}
+	for _, dep := range deps.DepList {
+		if dep.CheckoutSpec != expected[dep.Import] {
+			t.Errorf("Expected %s at %s, was %s\n", dep.Import, expected[dep.Import], dep.CheckoutSpec)
+		}
+	}
+}
+
+func TestIncludeChangesChecksum(t *testing.T) {
+	setupTestPwd()
+	setupEnv()
+	check(ioutil.WriteFile(path.Join(pwd, "shared-deps.config"), []byte(sharedDepsFixture), 0644))
+	createFixtureConfig(pwd, includingFixture)
+
+	check(loadTestConfig(pwd).WriteChecksum())
+
+	check(ioutil.WriteFile(path.Join(pwd, "shared-deps.config"), []byte(strings.Replace(sharedDepsFixture, "v1.0", "v1.1", 1)), 0644))
+	if !loadTestConfig(pwd).modifiedChecksum() {
+		t.Errorf("Expected a changed include to change the checksum\n")
+	}
+}
+
+func TestIncludeFromURLIsFetchedOnce(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, sharedDepsFixture)
+	}))
+	defer server.Close()
+
+	setupTestPwd()
+	setupEnv()
+	createFixtureConfig(pwd, fmt.Sprintf("include = [%q]\n", server.URL+"/shared-deps.config"))
+
+	for i := 0; i < 2; i++ {
+		if deps, _ := loadTestConfig(pwd).LoadDependencyModel(NewGraph()); len(deps.DepList) != 3 {
+			t.Errorf("Expected the deps of the include, found %s\n", deps)
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected the include to be downloaded once, was downloaded %d times\n", requests)
+	}
+}
+
+func TestIncludesAreNested(t *testing.T) {
+	setupTestPwd()
+	setupEnv()
+	createPath(path.Join(pwd, "shared"))
+	check(ioutil.WriteFile(path.Join(pwd, "shared", "base.config"), []byte(sharedDepsFixture), 0644))
+	check(ioutil.WriteFile(path.Join(pwd, "shared", "service.config"), []byte(`
+include = ["base.config"]
+
+[deps.shared]
+  import = "github.com/gopack-test/shared"
+  tag = "v1.5"
+`), 0644))
+	createFixtureConfig(pwd, strings.Replace(includingFixture, "shared-deps.config", "shared/service.config", 1))
+
+	config := loadTestConfig(pwd)
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"github.com/gopack-test/shared":     "v1.5",
+		"github.com/gopack-test/overridden": "v2.0",
+		"github.com/gopack-test/renamed":    "v2.0",
+	}
+	for _, dep := range deps.DepList {
+		if dep.CheckoutSpec != expected[dep.Import] {
+			t.Errorf("Expected %s at %s, was %s\n", dep.Import, expected[dep.Import], dep.CheckoutSpec)
+		}
+	}
+
+	check(config.WriteChecksum())
+	check(ioutil.WriteFile(path.Join(pwd, "shared", "base.config"), []byte(sharedDepsFixture+"[deps.added]\n  import = \"github.com/gopack-test/added\"\n"), 0644))
+	if !loadTestConfig(pwd).modifiedChecksum() {
+		t.Errorf("Expected a changed nested include to change the checksum\n")
+	}
+}
+
+func TestIncludeCycleFails(t *testing.T) {
+	setupTestPwd()
+	setupEnv()
+	check(ioutil.WriteFile(path.Join(pwd, "shared-deps.config"), []byte("include = [\"gopack.config\"]\n"+sharedDepsFixture), 0644))
+	createFixtureConfig(pwd, includingFixture)
+
+	if _, err := NewConfig(pwd); err == nil || !strings.Contains(err.Error(), "includes itself") {
+		t.Errorf("Expected the cycle to fail, got %v\n", err)
+	}
+}
+
+func TestMissingConfigIsNotAProject(t *testing.T) {
+	setupTestPwd()
+	_, err := NewConfig(pwd)
+	if _, ok := err.(*ConfigNotFoundError); !ok || !strings.Contains(err.Error(), "isn't a gopack project") {
+		t.Errorf("Expected the dir not to be a gopack project, got %v\n", err)
+	}
+}
+
+func TestConfigSyntaxErrorsHaveALine(t *testing.T) {
+	setupTestPwd()
+	createFixtureConfig(pwd, `
+[deps.mux]
+  import = "github.com/gorilla/mux"
+  tag = "v1.8.0
+  branch = "master"
+`)
+	_, err := NewConfig(pwd)
+	syntaxErr, ok := err.(*ConfigSyntaxError)
+	if !ok {
+		t.Fatalf("Expected a syntax error, got %v\n", err)
+	}
+	if syntaxErr.Line != 4 || syntaxErr.Text != `tag = "v1.8.0` {
+		t.Errorf("Expected the error on line 4, got %d %q\n", syntaxErr.Line, syntaxErr.Text)
+	}
+}
+
+func TestChecksumIgnoresOrderAndFormatting(t *testing.T) {
+	config := setupTestConfig(`
+repo = "github.com/gopack-test/canonical"
+
+[deps.mux]
+  import = "github.com/gopack-test/mux"
+  tag = "v1.8.0"
+[deps.toml]
+  import = "github.com/gopack-test/toml"
+  branch = "master"
+`)
+	check(config.WriteChecksum())
+
+	createFixtureConfig(pwd, `
+# reordered, renamed and reformatted
+repo   =   "github.com/gopack-test/canonical"
+[deps.go-toml]
+branch = "master"
+import = "github.com/gopack-test/toml"
+
+[deps.mux]
+import   = "github.com/gopack-test/mux"
+tag      = "v1.8.0"
+`)
+	if loadTestConfig(pwd).modifiedChecksum() {
+		t.Errorf("Expected the same deps in another order not to change the checksum\n")
+	}
+
+	createFixtureConfig(pwd, `
+repo = "github.com/gopack-test/canonical"
+
+[deps.mux]
+  import = "github.com/gopack-test/mux"
+  tag = "v1.8.1"
+[deps.toml]
+  import = "github.com/gopack-test/toml"
+  branch = "master"
+`)
+	if !loadTestConfig(pwd).modifiedChecksum() {
+		t.Errorf("Expected another tag to change the checksum\n")
+	}
+}
+
+func TestOldChecksumsAreModified(t *testing.T) {
+	config := setupTestConfig(`
+[deps.mux]
+  import = "github.com/gopack-test/mux"
+  tag = "v1.8.0"
+`)
+	// the md5 of the file bytes older versions wrote
+	createSourceFixture(path.Join(pwd, GopackDir), "checksum", "9e107d9d372bb6826bd81d3542a419d6")
+	if !config.modifiedChecksum() {
+		t.Errorf("Expected a checksum in the old format to be modified\n")
+	}
+}
+
+func TestConfigFlagOnlyMovesTheConfig(t *testing.T) {
+	setupTestPwd()
+	build := path.Join(pwd, "build")
+	createPath(build)
+	check(ioutil.WriteFile(path.Join(build, "shared-deps.config"), []byte(sharedDepsFixture), 0644))
+	check(ioutil.WriteFile(path.Join(build, "app.config"), []byte("repo = \"github.com/gopack-test/app\"\nvendor = \"../vendor\"\ninclude = [\"shared-deps.config\"]\n"), 0644))
+
+	configFile = path.Join(build, "app.config")
+	defer func() { configFile = "" }()
+
+	config, err := NewConfigFile(rootConfig(pwd), pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Repository != "github.com/gopack-test/app" || len(config.Includes) != 1 {
+		t.Errorf("Expected build/app.config to be loaded with its include, got %s %v\n", config.Repository, config.Includes)
+	}
+	if config.VendorDir != "vendor" || configuredVendorDir(pwd) != "vendor" {
+		t.Errorf("Expected the vendor dir to be relative to the config but in the project, got %s\n", config.VendorDir)
+	}
+
+	configFile = path.Join(build, "missing.config")
+	if _, err := NewConfigFile(rootConfig(pwd), pwd); err == nil || !strings.Contains(err.Error(), "missing.config doesn't exist") {
+		t.Errorf("Expected a missing --config to be reported, got %v\n", err)
+	}
+}
+
+func TestConfigFlagWinsOverAppConfig(t *testing.T) {
+	setupTestPwd()
+	if rootConfig(pwd) != path.Join(pwd, "gopack.config") {
+		t.Errorf("Expected the config to be in GOPACK_APP_CONFIG without --config, got %s\n", rootConfig(pwd))
+	}
+
+	wd, _ := os.Getwd()
+	configFile = "build/app.config"
+	defer func() { configFile = "" }()
+	setConfigFile()
+	if rootConfig(pwd) != path.Join(wd, "build/app.config") {
+		t.Errorf("Expected --config relative to the working dir to win, got %s\n", rootConfig(pwd))
+	}
+}
+
+func TestDepsLoadInTheOrderListed(t *testing.T) {
+	config := setupTestConfig(`
+[deps.zeta]
+  import = "github.com/gopack-test/zeta"
+  tag = "v1.0"
+[deps.alpha]
+  import = "github.com/gopack-test/alpha"
+  branch = "master"
+[dev-deps.mock]
+  import = "github.com/gopack-test/mock"
+  tag = "v0.1"
+`)
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"github.com/gopack-test/zeta", "github.com/gopack-test/alpha", "github.com/gopack-test/mock"}
+	if len(deps.DepList) != len(expected) {
+		t.Fatalf("Expected %d deps, found %s\n", len(expected), deps)
+	}
+	for i, dep := range deps.DepList {
+		if dep.Import != expected[i] {
+			t.Errorf("Expected %s at %d, was %s\n", expected[i], i, dep.Import)
+		}
+	}
+	if deps.DepList[0].CheckoutSpec != "v1.0" || !deps.DepList[2].Dev {
+		t.Errorf("Expected the specs and dev flags to be kept, found %s\n", deps)
+	}
+}
+
+func TestDepsListIsRejected(t *testing.T) {
+	setupTestPwd()
+	for section, expected := range map[string]string{
+		"deps":     "deps - [[deps]] entries aren't supported",
+		"dev-deps": "dev-deps - [[dev-deps]] entries aren't supported",
+	} {
+		createFixtureConfig(pwd, `
+[[`+section+`]]
+  import = "github.com/gopack-test/first"
+[[`+section+`]]
+  import = "github.com/gopack-test/second"
+`)
+		if _, err := NewConfig(pwd); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected [[%s]] to be rejected rather than dropping deps, got %v\n", section, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// The dir has neither a gopack.config nor a gopack.deps.
+type ConfigNotFoundError struct {
+	Dir  string
+	Path string
+}
+
+func (e *ConfigNotFoundError) Error() string {
+	if filepath.Base(e.Path) != "gopack.config" {
+		return fmt.Sprintf("%s doesn't exist, check --config", e.Path)
+	}
+	return fmt.Sprintf("%s has no gopack.config, it isn't a gopack project, run gp init to create one", e.Dir)
+}
+
+// A config that isn't valid toml, with the line the parser gave up on.
+type ConfigSyntaxError struct {
+	Path string
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *ConfigSyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d isn't valid toml: %s\n    %s", e.Path, e.Line, e.Err, e.Text)
+}
+
+// Parse a config, telling the line of a syntax error. The parser doesn't
+// know where it failed, so the error is on the line after the longest
+// run of lines from the top that parses.
+func loadToml(path string, data []byte) (*toml.TomlTree, error) {
+	t, err := toml.Load(string(data))
+	if err == nil {
+		return t, nil
+	}
+
+	lines := strings.Split(string(data), "\n")
+	line := len(lines) - 1
+	for ; line > 0; line-- {
+		if _, prefixErr := toml.Load(strings.Join(lines[:line], "\n")); prefixErr == nil {
+			break
+		}
+	}
+	return nil, &ConfigSyntaxError{Path: path, Line: line + 1, Text: strings.TrimSpace(lines[line]), Err: err}
+}
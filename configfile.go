@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// the root config wherever it is, set with --config
+var configFile = ""
+
+// Make --config absolute while the working directory is still the one
+// it was given in.
+func setConfigFile() error {
+	if configFile == "" || filepath.IsAbs(configFile) {
+		return nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	configFile = filepath.Join(wd, configFile)
+	return nil
+}
+
+// The config of the project in dir. --config wins over GOPACK_APP_CONFIG,
+// which moves the project dir, and so where its gopack.config is looked
+// for, along with the .gopack dir and the checksum. --config only moves
+// the config, the project stays where it is.
+func rootConfig(dir string) string {
+	if configFile != "" {
+		return configFile
+	}
+	return filepath.Join(dir, "gopack.config")
+}
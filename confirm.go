@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	// update locked deps without asking, set with --yes
+	assumeYes = false
+	// ask before updating locked deps even without a terminal, set with --confirm
+	forceConfirm           = false
+	confirmInput io.Reader = os.Stdin
+	// tests swap it to pretend to run in a terminal
+	isTerminal = func() bool {
+		info, err := os.Stdin.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0
+	}
+)
+
+// The locked deps about to be fetched that resolve to another revision
+// than the one in the lock, a branch that advanced for instance. Deps
+// that can't be resolved without fetching them are left out.
+func (d *Dependencies) PlanUpdates(lock *Lock) []DepChange {
+	updates := []DepChange{}
+	for _, dep := range d.DepList {
+		locked := lock.Deps[dep.Import]
+		if !dep.fetch || dep.Locked != "" || locked == nil || locked.Revision == "" {
+			continue
+		}
+
+		to := ""
+		switch {
+		case dep.CheckoutFlag == CommitFlag:
+			to = dep.CheckoutSpec
+		case dep.Scm == GitTag:
+			to = remoteRevision(dep)
+		}
+
+		if to != "" && !strings.HasPrefix(locked.Revision, to) {
+			planned := &LockedDep{Import: dep.Import, Checkout: dep.Checkout(), Revision: to}
+			updates = append(updates, DepChange{Changed, dep.Import, locked, planned})
+		}
+	}
+	sort.Sort(byChangedImport(updates))
+	return updates
+}
+
+// Whether to go ahead with updating the locked deps. Only asks in a
+// terminal, unless --confirm, and never with --yes.
+func (d *Dependencies) confirmUpdates(w io.Writer) (bool, error) {
+	if assumeYes || (!forceConfirm && !isTerminal()) {
+		return true, nil
+	}
+
+	lock, err := ReadLock()
+	if err != nil {
+		return false, err
+	}
+	updates := d.PlanUpdates(lock)
+	if len(updates) == 0 {
+		return true, nil
+	}
+
+	PrintDepDiff(w, updates)
+	fmt.Fprintf(w, "Update %d locked deps? [y/N] ", len(updates))
+
+	answer, _ := bufio.NewReader(confirmInput).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
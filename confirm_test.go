@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func stubTerminal(answer string) func() {
+	origTerminal, origInput := isTerminal, confirmInput
+	isTerminal = func() bool { return true }
+	confirmInput = strings.NewReader(answer)
+	refreshRemote = true
+	return func() {
+		isTerminal, confirmInput = origTerminal, origInput
+		refreshRemote = false
+	}
+}
+
+func TestConfirmUpdatesShowsAdvancedBranches(t *testing.T) {
+	setupTestPwd()
+	defer stubTerminal("n\n")()
+
+	stable := vendoredGitDep("github.com/gopack-test/stable", "branch", "master")
+	moved := vendoredGitDep("github.com/gopack-test/moved", "branch", "master")
+	if err := lockDeps(t, stable, moved).Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched := []string{}
+	defer stubSinceGit(&fetched)()
+
+	var out bytes.Buffer
+	deps := &Dependencies{DepList: []*Dep{stable, moved}}
+	if confirmed, _ := deps.confirmUpdates(&out); confirmed {
+		t.Errorf("Expected answering no to not update the deps\n")
+	}
+
+	expected := "~ github.com/gopack-test/moved branch master (182cae2) -> branch master (9d57c89)\n" +
+		"Update 1 locked deps? [y/N] "
+	if out.String() != expected {
+		t.Errorf("Expected the prompt\n%s\nbut printed\n%s\n", expected, out.String())
+	}
+}
+
+func TestConfirmUpdatesSkippedWithYes(t *testing.T) {
+	setupTestPwd()
+	defer stubTerminal("")()
+	assumeYes = true
+	defer func() { assumeYes = false }()
+
+	moved := vendoredGitDep("github.com/gopack-test/moved", "branch", "master")
+	if err := lockDeps(t, moved).Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched := []string{}
+	defer stubSinceGit(&fetched)()
+
+	var out bytes.Buffer
+	deps := &Dependencies{DepList: []*Dep{moved}}
+	if confirmed, _ := deps.confirmUpdates(&out); !confirmed {
+		t.Errorf("Expected --yes to update the deps without asking\n")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no prompt with --yes but printed %s\n", out.String())
+	}
+}
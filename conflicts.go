@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// only warn about deps asking for different versions, set with --allow-conflicts
+var allowConflicts = false
+
+// An import path the project and its deps ask for at different versions.
+type VersionConflict struct {
+	Import string
+	// one dep per distinct version asked for, in the order they were inserted
+	Requests []*Dep
+}
+
+// Every import path inserted with more than one checkout, whichever was
+// inserted last being the one vendored.
+func (graph *Graph) Conflicts() []VersionConflict {
+	imports := []string{}
+	for importPath := range graph.Requested {
+		imports = append(imports, importPath)
+	}
+	sort.Strings(imports)
+
+	conflicts := []VersionConflict{}
+	for _, importPath := range imports {
+		requests := []*Dep{}
+		seen := make(map[string]bool)
+		for _, dep := range graph.Requested[importPath] {
+			if key := dep.Checkout() + "\x00" + dep.Parent; !seen[key] {
+				seen[key] = true
+				requests = append(requests, dep)
+			}
+		}
+
+		checkouts := make(map[string]bool)
+		for _, dep := range requests {
+			checkouts[dep.Checkout()] = true
+		}
+		if len(checkouts) > 1 {
+			conflicts = append(conflicts, VersionConflict{importPath, requests})
+		}
+	}
+	return conflicts
+}
+
+func (c VersionConflict) String() string {
+	requests := []string{}
+	for _, dep := range c.Requests {
+		checkout := dep.Checkout()
+		if checkout == "" {
+			checkout = "no checkout"
+		}
+		parent := dep.Parent
+		if parent == "" {
+			parent = "gopack.config"
+		}
+		requests = append(requests, fmt.Sprintf("%s by %s", checkout, parent))
+	}
+	return fmt.Sprintf("%s is asked for at %s", c.Import, strings.Join(requests, ", "))
+}
+
+// Conflicting versions are errors unless they are allowed.
+func checkConflicts(graph *Graph) []*ProjectError {
+	conflicts := graph.Conflicts()
+	if allowConflicts {
+		for _, c := range conflicts {
+			fmtcolor(Yellow, "      Warning: %s\n", c)
+		}
+		return nil
+	}
+
+	errors := []*ProjectError{}
+	for _, c := range conflicts {
+		errors = append(errors, VersionConflictError(c))
+	}
+	return errors
+}
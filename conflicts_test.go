@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConflictsReportEveryParent(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	ran := []string{}
+	defer stubGitWithConfigs(&ran, map[string]string{
+		"https://github.com/gopack-test/conflict-a.git": `
+[deps.log]
+  import = "github.com/gopack-test/conflict-log"
+  scm = "git"
+  source = "https://github.com/gopack-test/conflict-log.git"
+  tag = "v1.0.0"
+`,
+		"https://github.com/gopack-test/conflict-b.git": `
+[deps.log]
+  import = "github.com/gopack-test/conflict-log"
+  scm = "git"
+  source = "https://github.com/gopack-test/conflict-log.git"
+  tag = "v2.0.0"
+`,
+	})()
+
+	a := gitDep("github.com/gopack-test/conflict-a", "", "")
+	b := gitDep("github.com/gopack-test/conflict-b", "", "")
+	graph := NewGraph()
+	graph.Insert(a)
+	graph.Insert(b)
+	deps := &Dependencies{ImportGraph: graph, DepList: []*Dep{a, b}}
+
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts := graph.Conflicts()
+	expected := "github.com/gopack-test/conflict-log is asked for at tag v1.0.0 by github.com/gopack-test/conflict-a, " +
+		"tag v2.0.0 by github.com/gopack-test/conflict-b"
+	if len(conflicts) != 1 || conflicts[0].String() != expected {
+		t.Errorf("Expected the conflict %q, got %v\n", expected, conflicts)
+	}
+}
+
+func TestSameVersionFromSeveralParentsIsNoConflict(t *testing.T) {
+	graph := NewGraph()
+	for _, parent := range []string{"", "github.com/gopack-test/a", "github.com/gopack-test/b"} {
+		dep := gitDep("github.com/gopack-test/shared", "tag", "v1.0.0")
+		dep.Parent = parent
+		graph.Insert(dep)
+	}
+
+	if conflicts := graph.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v\n", conflicts)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Why the checksum, the lock and the vendor tree disagree, which only a
+// run that died half way leaves behind. Empty when they agree. Locks
+// without a checksum predate the check and can't be compared.
+func (c *Config) desynchronized(deps *Dependencies) string {
+	lock, err := ReadLock()
+	if err != nil {
+		return err.Error()
+	}
+	if lock.Checksum == "" {
+		return ""
+	}
+	if lock.Checksum != string(c.checksum()) {
+		return fmt.Sprintf("%s was resolved from another gopack.config", GopackLock)
+	}
+
+	for _, dep := range deps.DepList {
+		locked := lock.Deps[dep.Import]
+		if locked == nil {
+			return fmt.Sprintf("`%s` is missing from %s", dep.Import, GopackLock)
+		}
+		if locked.Checkout != dep.Checkout() {
+			return fmt.Sprintf("`%s` is locked at %s instead of %s", dep.Import, locked.Checkout, dep.Checkout())
+		}
+		if _, err := os.Stat(dep.Src()); os.IsNotExist(err) {
+			return fmt.Sprintf("`%s` is locked but not vendored", dep.Import)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const consistentFixture = `
+[deps.pinned]
+  import = "github.com/gopack-test/consistent-pinned"
+  commit = "182cae2ee3926a960223d8db4998aa9d57c89788"
+`
+
+// A finished run: the dep vendored, locked and the checksum written.
+func finishedRun(t *testing.T) (*Config, *Dependencies) {
+	config := setupTestConfig(consistentFixture)
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	createSourceFixture(deps.DepList[0].Src(), "pinned.go", "package pinned\n")
+	if err := writeLock(config, deps); err != nil {
+		t.Fatal(err)
+	}
+	check(config.WriteChecksum())
+	return config, deps
+}
+
+func TestFinishedRunIsConsistent(t *testing.T) {
+	config, _ := finishedRun(t)
+
+	deps, _ := loadTestConfig(pwd).LoadDependencyModel(NewGraph())
+	if deps.AnyDepsNeedFetching() {
+		t.Errorf("Expected nothing to fetch after a finished run of %s\n", config.Path)
+	}
+}
+
+func TestRecoverFromRunThatDiedBeforeLocking(t *testing.T) {
+	finishedRun(t)
+
+	// the config changed and the checksum got written, but not the lock
+	createFixtureConfig(pwd, strings.Replace(consistentFixture, "182cae2", "9d57c89", 1))
+	check(loadTestConfig(pwd).WriteChecksum())
+
+	deps, _ := loadTestConfig(pwd).LoadDependencyModel(NewGraph())
+	if !deps.AllDepsNeedFetching() {
+		t.Errorf("Expected the deps to be resolved again when the lock is from another config\n")
+	}
+}
+
+func TestRecoverFromMissingVendoredDep(t *testing.T) {
+	_, deps := finishedRun(t)
+	os.RemoveAll(deps.DepList[0].Src())
+
+	deps, _ = loadTestConfig(pwd).LoadDependencyModel(NewGraph())
+	if !deps.AllDepsNeedFetching() {
+		t.Errorf("Expected a locked dep that isn't vendored to be fetched again\n")
+	}
+}
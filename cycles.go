@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sort"
+)
+
+// Every dependency cycle in the graph, each starting and ending with
+// the same dep, e.g. [a b a]. Edges are followed to the dep that
+// vendors the import they point at, so an edge to a package of a dep
+// counts as an edge to the dep itself.
+func (graph *Graph) DetectCycles() [][]string {
+	cycles := [][]string{}
+	found := make(map[string]bool)
+	done := make(map[string]bool)
+	stack := []string{}
+
+	var visit func(importPath string)
+	visit = func(importPath string) {
+		for i, v := range stack {
+			if v == importPath {
+				cycle := append(append([]string{}, stack[i:]...), importPath)
+				if key := cycleKey(cycle); !found[key] {
+					found[key] = true
+					cycles = append(cycles, cycle)
+				}
+				return
+			}
+		}
+		if done[importPath] {
+			return
+		}
+
+		stack = append(stack, importPath)
+		for _, child := range graph.depsOf(importPath) {
+			visit(child)
+		}
+		stack = stack[:len(stack)-1]
+		done[importPath] = true
+	}
+
+	parents := []string{}
+	for parent := range graph.Edges {
+		parents = append(parents, parent)
+	}
+	sort.Strings(parents)
+	for _, parent := range parents {
+		visit(graph.depOf(parent))
+	}
+	return cycles
+}
+
+// The import path of the dep in the tree vendoring importPath.
+func (graph *Graph) depOf(importPath string) string {
+	if node := graph.Search(importPath); node != nil && node.Dependency != nil {
+		return node.Dependency.Import
+	}
+	return importPath
+}
+
+func (graph *Graph) depsOf(importPath string) []string {
+	deps := []string{}
+	for _, child := range graph.Edges[importPath] {
+		if dep := graph.depOf(child); !containsString(deps, dep) {
+			deps = append(deps, dep)
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// The same cycle found from another of its deps is only reported once.
+func cycleKey(cycle []string) string {
+	deps := append([]string{}, cycle[1:]...)
+	start := 0
+	for i, dep := range deps {
+		if dep < deps[start] {
+			start = i
+		}
+	}
+	key := ""
+	for i := range deps {
+		key += deps[(start+i)%len(deps)] + " "
+	}
+	return key
+}
+
+// Leave out the deps that are already being loaded further up the
+// chain, fetching them again would never end. The edge back to them
+// is what DetectCycles reports.
+func (d *Dependencies) skipAncestors() {
+	deps := []*Dep{}
+	for _, dep := range d.DepList {
+		if !containsString(d.Ancestors, dep.Import) {
+			deps = append(deps, dep)
+		}
+	}
+	d.DepList = deps
+}
+
+func (d *Dependencies) cycleErrors() []*ProjectError {
+	errors := []*ProjectError{}
+	for _, cycle := range d.ImportGraph.DetectCycles() {
+		errors = append(errors, DependencyCycleError(cycle))
+	}
+	return errors
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDetectCycles(t *testing.T) {
+	graph := NewGraph()
+	for _, importPath := range []string{"github.com/gopack-test/a", "github.com/gopack-test/b", "github.com/gopack-test/c"} {
+		graph.Insert(&Dep{Import: importPath})
+	}
+	graph.AddEdge("github.com/gopack-test/a", "github.com/gopack-test/b/sub")
+	graph.AddEdge("github.com/gopack-test/b", "github.com/gopack-test/a")
+	graph.AddEdge("github.com/gopack-test/b", "github.com/gopack-test/c")
+
+	expected := [][]string{{"github.com/gopack-test/a", "github.com/gopack-test/b", "github.com/gopack-test/a"}}
+	if cycles := graph.DetectCycles(); !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("Expected the cycles %v, got %v\n", expected, cycles)
+	}
+}
+
+func TestDetectCyclesWithoutCycles(t *testing.T) {
+	graph := NewGraph()
+	graph.AddEdge("github.com/gopack-test/a", "github.com/gopack-test/b")
+	graph.AddEdge("github.com/gopack-test/a", "github.com/gopack-test/c")
+	graph.AddEdge("github.com/gopack-test/b", "github.com/gopack-test/c")
+
+	if cycles := graph.DetectCycles(); len(cycles) != 0 {
+		t.Errorf("Expected no cycles, got %v\n", cycles)
+	}
+}
+
+func TestCyclicDepsAreLoadedOnce(t *testing.T) {
+	setupTestPwd()
+	defer stubVendorDir(".gopack/vendor")()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	cloned := []string{}
+	defer stubGitWithDeps(&cloned, map[string][]string{
+		"https://github.com/gopack-test/cycle-a.git": {"github.com/gopack-test/cycle-b"},
+		"https://github.com/gopack-test/cycle-b.git": {"github.com/gopack-test/cycle-a"},
+	})()
+
+	a := gitDep("github.com/gopack-test/cycle-a", "", "")
+	graph := NewGraph()
+	graph.Insert(a)
+	deps := &Dependencies{ImportGraph: graph, DepList: []*Dep{a}}
+
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cloned) != 2 {
+		t.Errorf("Expected each dep of the cycle to be cloned once, cloned %v\n", cloned)
+	}
+
+	errors := deps.cycleErrors()
+	expected := "dependency cycle github.com/gopack-test/cycle-a -> github.com/gopack-test/cycle-b -> github.com/gopack-test/cycle-a\n"
+	if len(errors) != 1 || errors[0].Kind != DependencyCycle || errors[0].Message != expected {
+		t.Errorf("Expected %q, got %v\n", expected, errors)
+	}
+}
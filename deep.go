@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// build the project against the vendor tree too, set with verify --deep
+	verifyDeep = false
+	// and vet it, set with verify --vet
+	verifyVet = false
+)
+
+// Pins that are each correct can still be incompatible together, so the
+// deepest check is whether the vendored set compiles.
+func buildVendored(dir string, vet bool) []*ProjectError {
+	commands := [][]string{{"build", "./..."}}
+	if vet {
+		commands = append(commands, []string{"vet", "./..."})
+	}
+
+	errors := []*ProjectError{}
+	for _, args := range commands {
+		cmd := goCommand(args...)
+		cmd.Dir = dir
+		if out, err := execute(cmd); err != nil {
+			errors = append(errors, BuildFailureError(strings.Join(cmd.Args, " "), string(out)))
+		}
+	}
+	return errors
+}
+
+// go with the localized GOPATH setupEnv exported.
+func goCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("go", args...)
+	cmd.Env = os.Environ()
+	return cmd
+}
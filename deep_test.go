@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Build in GOPATH mode against the test's vendor tree.
+func setupBuildEnv() func() {
+	origPath, origModule := os.Getenv("GOPATH"), os.Getenv("GO111MODULE")
+	os.Setenv("GOPATH", filepath.Join(pwd, VendorDir))
+	os.Setenv("GO111MODULE", "off")
+	return func() {
+		os.Setenv("GOPATH", origPath)
+		os.Setenv("GO111MODULE", origModule)
+	}
+}
+
+func TestDeepVerifyBuildsAgainstVendor(t *testing.T) {
+	setupTestPwd()
+	defer setupBuildEnv()()
+
+	createVendoredDep("github.com/gopack-test/compiles", map[string]string{
+		"compiles.go": "package compiles\n\nfunc Answer() int { return 42 }\n",
+	})
+	createSourceFixture(pwd, "main.go", `package main
+
+import "github.com/gopack-test/compiles"
+
+func main() { println(compiles.Answer()) }
+`)
+
+	if errors := buildVendored(pwd, true); len(errors) > 0 {
+		t.Errorf("Expected the project to build, got %v\n", errors[0])
+	}
+}
+
+func TestDeepVerifyReportsIncompatibleDeps(t *testing.T) {
+	setupTestPwd()
+	defer setupBuildEnv()()
+
+	createVendoredDep("github.com/gopack-test/incompatible", map[string]string{
+		"incompatible.go": "package incompatible\n\nfunc Answer() string { return \"42\" }\n",
+	})
+	createSourceFixture(pwd, "main.go", `package main
+
+import "github.com/gopack-test/incompatible"
+
+func main() { println(incompatible.Answer() + 1) }
+`)
+
+	errors := buildVendored(pwd, false)
+	if len(errors) != 1 {
+		t.Fatalf("Expected the build to fail, got %d errors\n", len(errors))
+	}
+	if errors[0].Kind != BuildFailure {
+		t.Errorf("Expected a %s error, got %s\n", BuildFailure, errors[0].Kind)
+	}
+	if !strings.Contains(errors[0].Message, "go build ./...") || !strings.Contains(errors[0].Message, "main.go") {
+		t.Errorf("Expected the compile errors in the message, got %s\n", errors[0].Message)
+	}
+}
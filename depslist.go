@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// The name of a [deps.name], [dev-deps.name] or [rewrites.name] table header.
+var depHeader = regexp.MustCompile(`(?m)^\s*\[\s*(deps|dev-deps|rewrites)\.([A-Za-z0-9_-]+)`)
+
+// A deps section as tables keyed by name, along with the names in the
+// order the config lists them. The parser doesn't keep the order of the
+// tables so it's read from data. The pinned go-toml has no arrays of
+// tables, it reads [[deps]] as a table named "[deps" holding the last
+// entry, so those are rejected instead of losing deps.
+func loadDepsSection(t *toml.TomlTree, section string, data []byte) (*toml.TomlTree, []string, error) {
+	if t.Get("["+section) != nil {
+		return nil, nil, depsListError(section)
+	}
+	switch deps := t.Get(section).(type) {
+	case nil:
+		return nil, nil, nil
+	case *toml.TomlTree:
+		return deps, tableOrder(section, data), nil
+	case []*toml.TomlTree:
+		return nil, nil, depsListError(section)
+	}
+	return nil, nil, fmt.Errorf("%s - expected [%s.name] tables", section, section)
+}
+
+func depsListError(section string) error {
+	return fmt.Errorf("%s - [[%s]] entries aren't supported, list them as [%s.name] tables", section, section, section)
+}
+
+func tableOrder(section string, data []byte) []string {
+	order := []string{}
+	for _, m := range depHeader.FindAllSubmatch(data, -1) {
+		if string(m[1]) == section {
+			order = append(order, string(m[2]))
+		}
+	}
+	return order
+}
+
+// The names of the deps in the tree, those in order first and then the
+// ones it doesn't list, a dep from gopack.deps for instance, sorted.
+func orderedKeys(deps *toml.TomlTree, order []string) []string {
+	keys := []string{}
+	listed := make(map[string]bool)
+	for _, k := range order {
+		if deps.Get(k) != nil && !listed[k] {
+			keys = append(keys, k)
+			listed[k] = true
+		}
+	}
+	rest := []string{}
+	for _, k := range deps.Keys() {
+		if !listed[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+func (d *Dep) setDepth(t *toml.TomlTree) {
+	if depth, ok := t.Get("depth").(int64); ok {
+		d.Depth = int(depth)
+	}
+}
+
+func (d *Dep) validateDepth() error {
+	if d.Depth == 0 {
+		return nil
+	}
+	if d.Depth < 0 {
+		return fmt.Errorf("%s - depth can't be negative", d.Import)
+	}
+	if d.Scm != GitTag {
+		return fmt.Errorf("%s - depth is only supported with git", d.Import)
+	}
+	if d.AsOf != "" {
+		return fmt.Errorf("%s - depth can't be combined with as_of", d.Import)
+	}
+	return nil
+}
+
+// Shallow clones only have the branch or tag they're cloned at, a commit
+// is looked for on the default branch.
+func (d *Dep) shallowCloneArgs(cmd *exec.Cmd) {
+	cmd.Args = append(cmd.Args, "--depth", strconv.Itoa(d.Depth))
+	spec := d.CheckoutSpec
+	if d.Tag != "" {
+		spec = d.Tag
+	}
+	if d.CheckoutFlag == BranchFlag || d.CheckoutFlag == TagFlag {
+		cmd.Args = append(cmd.Args, "--branch", spec)
+	}
+}
+
+// Check out the dep, fetching the whole history when what it asks for
+// isn't in the shallow clone.
+func (g Git) checkoutShallow(d *Dep) error {
+	err := g.checkout(d)
+	if err == nil {
+		return nil
+	}
+	fmtcolor(Gray, "    Deepening: `%s`, %s isn't in the last %d commits\n", d.Import, d.CheckoutSpec, d.Depth)
+	if err := runScmIn(d.Src(), "git", "fetch", "--unshallow", "--tags", "origin", "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+		return err
+	}
+	return g.checkout(d)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestDepthClonesShallow(t *testing.T) {
+	setupTestPwd()
+	defer stubVendorDir(".gopack/vendor")()
+
+	var args []string
+	defer stubCommands(commandStubs{"clone": func(cmd *exec.Cmd) ([]byte, error) {
+		args = cmd.Args
+		return cloneCheckout(cmd)
+	}})()
+
+	dep := gitDep("github.com/gopack-test/shallow", "tag", "v1.2.0")
+	dep.Depth = 1
+	if err := dep.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(strings.Join(args, " "), "--depth 1 --branch v1.2.0") {
+		t.Errorf("Expected a clone of the last commit of the tag, ran %v\n", args)
+	}
+
+	full := gitDep("github.com/gopack-test/not-shallow", "tag", "v1.2.0")
+	if err := full.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.Join(args, " "), "--depth") {
+		t.Errorf("Expected the whole history to be cloned without a depth, ran %v\n", args)
+	}
+}
+
+func TestShallowCloneIsDeepenedForOlderCommits(t *testing.T) {
+	setupTestPwd()
+	defer stubVendorDir(".gopack/vendor")()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	dir, shas := createDatedHistory(t, "2023-01-01", "2023-02-01", "2023-03-01")
+	dep := gitDep("github.com/gopack-test/deepened", "commit", shas["2023-01-01"])
+	// git ignores the depth of local clones unless they go through file://
+	dep.Source = "file://" + dir
+	dep.Depth = 1
+
+	if err := dep.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dep.switchToBranchOrTag(); err != nil {
+		t.Fatal(err)
+	}
+
+	if revision, _ := dep.CurrentRevision(); revision != shas["2023-01-01"] {
+		t.Errorf("Expected the shallow clone to be deepened to %s but is at %s\n", shas["2023-01-01"], revision)
+	}
+}
+
+func TestDepthValidation(t *testing.T) {
+	hg := &Dep{Import: "github.com/gopack-test/shallow", Scm: HgTag, Source: "https://github.com/gopack-test/shallow", Depth: 1}
+	asOf := asOfDep("https://github.com/gopack-test/history.git", "2023-01-15")
+	asOf.Depth = 1
+	negative := gitDep("github.com/gopack-test/shallow", "tag", "v1")
+	negative.Depth = -1
+
+	for name, dep := range map[string]*Dep{"hg": hg, "as_of": asOf, "a negative depth": negative} {
+		if dep.Validate() == nil {
+			t.Errorf("Expected depth with %s to be rejected\n", name)
+		}
+	}
+}
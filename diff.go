@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	Added   = '+'
+	Removed = '-'
+	Changed = '~'
+)
+
+// A dep that differs between two locks.
+type DepChange struct {
+	Kind   byte
+	Import string
+	Old    *LockedDep
+	New    *LockedDep
+}
+
+// The deps added, removed or resolved differently in to, by import path.
+func DiffLocks(from, to *Lock) []DepChange {
+	changes := []DepChange{}
+	for importPath, old := range from.Deps {
+		if _, ok := to.Deps[importPath]; !ok {
+			changes = append(changes, DepChange{Removed, importPath, old, nil})
+		}
+	}
+	for importPath, dep := range to.Deps {
+		old, ok := from.Deps[importPath]
+		if !ok {
+			changes = append(changes, DepChange{Added, importPath, nil, dep})
+		} else if old.Checkout != dep.Checkout || old.Revision != dep.Revision || old.Hash != dep.Hash {
+			changes = append(changes, DepChange{Changed, importPath, old, dep})
+		}
+	}
+	sort.Sort(byChangedImport(changes))
+	return changes
+}
+
+var diffColors = map[byte]uint8{Added: Green, Removed: Red, Changed: Yellow}
+
+// One line per change, colored when colors are on.
+func PrintDepDiff(w io.Writer, changes []DepChange) {
+	for _, c := range changes {
+		switch c.Kind {
+		case Added:
+			fcolor(w, diffColors[c.Kind], "%c %s %s\n", c.Kind, c.Import, describeLocked(c.New))
+		case Removed:
+			fcolor(w, diffColors[c.Kind], "%c %s %s\n", c.Kind, c.Import, describeLocked(c.Old))
+		case Changed:
+			fcolor(w, diffColors[c.Kind], "%c %s %s -> %s\n", c.Kind, c.Import, describeLocked(c.Old), describeLocked(c.New))
+		}
+	}
+}
+
+func describeLocked(l *LockedDep) string {
+	revision := l.Revision
+	if len(revision) > 7 {
+		revision = revision[:7]
+	}
+	switch {
+	case l.Checkout != "" && revision != "":
+		return fmt.Sprintf("%s (%s)", l.Checkout, revision)
+	case l.Checkout != "":
+		return l.Checkout
+	case revision != "":
+		return revision
+	}
+	return "(unpinned)"
+}
+
+type byChangedImport []DepChange
+
+func (s byChangedImport) Len() int           { return len(s) }
+func (s byChangedImport) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byChangedImport) Less(i, j int) bool { return s[i].Import < s[j].Import }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func diffFixture() []DepChange {
+	from := NewLock()
+	from.Deps["github.com/gopack-test/removed"] = &LockedDep{Import: "github.com/gopack-test/removed", Checkout: "tag v1.0"}
+	from.Deps["github.com/gopack-test/changed"] = &LockedDep{Import: "github.com/gopack-test/changed", Checkout: "branch master", Revision: "182cae2ee3926a960223d8db4998aa9d57c89788"}
+	from.Deps["github.com/gopack-test/same"] = &LockedDep{Import: "github.com/gopack-test/same", Checkout: "tag v2.0", Hash: "sha256:abc"}
+
+	to := NewLock()
+	to.Deps["github.com/gopack-test/added"] = &LockedDep{Import: "github.com/gopack-test/added", Checkout: "branch master", Revision: "9d57c89788182cae2ee3926a960223d8db4998aa"}
+	to.Deps["github.com/gopack-test/changed"] = &LockedDep{Import: "github.com/gopack-test/changed", Checkout: "branch master", Revision: "9d57c89788182cae2ee3926a960223d8db4998aa"}
+	to.Deps["github.com/gopack-test/same"] = &LockedDep{Import: "github.com/gopack-test/same", Checkout: "tag v2.0", Hash: "sha256:abc"}
+
+	return DiffLocks(from, to)
+}
+
+func TestDepDiffWithoutColors(t *testing.T) {
+	var out bytes.Buffer
+	PrintDepDiff(&out, diffFixture())
+
+	expected := "+ github.com/gopack-test/added branch master (9d57c89)\n" +
+		"~ github.com/gopack-test/changed branch master (182cae2) -> branch master (9d57c89)\n" +
+		"- github.com/gopack-test/removed tag v1.0\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\nbut printed\n%s\n", expected, out.String())
+	}
+}
+
+func TestDepDiffWithColors(t *testing.T) {
+	showColors = true
+	defer func() { showColors = false }()
+
+	var out bytes.Buffer
+	PrintDepDiff(&out, diffFixture())
+
+	expected := fmt.Sprintf("\033[%dm+ github.com/gopack-test/added branch master (9d57c89)\n%s", Green, EndColor) +
+		fmt.Sprintf("\033[%dm~ github.com/gopack-test/changed branch master (182cae2) -> branch master (9d57c89)\n%s", Yellow, EndColor) +
+		fmt.Sprintf("\033[%dm- github.com/gopack-test/removed tag v1.0\n%s", Red, EndColor)
+	if out.String() != expected {
+		t.Errorf("Expected\n%q\nbut printed\n%q\n", expected, out.String())
+	}
+}
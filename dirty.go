@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// keep fetching deps with local modifications, set with --allow-dirty
+var allowDirty = false
+
+// Whether the vendored dep has changes of its own. A checkout asks its
+// scm, a stripped tree or one with files generate or postinstall wrote is
+// compared against the hash in the lock.
+func (d *Dep) Dirty() (bool, error) {
+	if _, err := os.Stat(d.Src()); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	dir := d.vcsDir()
+	for tag, hidden := range HiddenDirs {
+		if dir != "" && path.Base(dir) == hidden && d.GenerateCommand == "" && d.PostInstallCommand == "" {
+			return Scms[tag].Dirty(d.Src())
+		}
+	}
+
+	lock, err := ReadLock()
+	if err != nil {
+		return false, err
+	}
+	locked := lock.Deps[d.Import]
+	if locked == nil || locked.Hash == "" {
+		// nothing to compare with
+		return false, nil
+	}
+	hash, err := contentHash(d.Src())
+	return hash != locked.Hash, err
+}
+
+// Refuse to fetch over local modifications unless --allow-dirty is set.
+func (d *Dep) checkClean() error {
+	if allowDirty {
+		return nil
+	}
+	dirty, err := d.Dirty()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%s - has local modifications in %s, commit or revert them or run with --allow-dirty", d.Import, d.Src())
+	}
+	return nil
+}
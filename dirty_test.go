@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func stubGitStatus(status string) func() {
+	return stubCommands(commandStubs{
+		"status": func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte(status), nil
+		},
+	})
+}
+
+func TestDirtyDepIsRefused(t *testing.T) {
+	setupTestPwd()
+	dep := vendoredGitDep("github.com/gopack-test/patched", "branch", "master")
+	defer stubGitStatus(" M main.go\n")()
+
+	err := dep.checkClean()
+	if err == nil || !strings.Contains(err.Error(), dep.Import) || !strings.Contains(err.Error(), "--allow-dirty") {
+		t.Errorf("Expected the dirty dep to be refused, got %v\n", err)
+	}
+}
+
+func TestDirtyDepAllowed(t *testing.T) {
+	setupTestPwd()
+	dep := vendoredGitDep("github.com/gopack-test/patched", "branch", "master")
+	defer stubGitStatus(" M main.go\n")()
+
+	allowDirty = true
+	defer func() { allowDirty = false }()
+
+	if err := dep.checkClean(); err != nil {
+		t.Errorf("Expected --allow-dirty to fetch over local modifications, got %s\n", err)
+	}
+}
+
+func TestCleanDepIsFetched(t *testing.T) {
+	setupTestPwd()
+	dep := vendoredGitDep("github.com/gopack-test/clean", "branch", "master")
+	defer stubGitStatus("")()
+
+	if err := dep.checkClean(); err != nil {
+		t.Errorf("Expected a clean dep to be fetched, got %s\n", err)
+	}
+}
+
+func TestDirtyStrippedDep(t *testing.T) {
+	setupTestPwd()
+	dep := vendoredGitDep("github.com/gopack-test/stripped", "branch", "master")
+	if err := lockDeps(t, dep).Write(); err != nil {
+		t.Fatal(err)
+	}
+	dep.StripVcs()
+
+	if dirty, err := dep.Dirty(); dirty || err != nil {
+		t.Errorf("Expected a stripped dep matching the lock to be clean, got %v %v\n", dirty, err)
+	}
+
+	createSourceFixture(dep.Src(), "main.go", "package patched\n")
+	if dirty, _ := dep.Dirty(); !dirty {
+		t.Errorf("Expected a stripped dep that doesn't match the lock to be dirty\n")
+	}
+}
+
+func TestPostInstallOutputsAreNotDirty(t *testing.T) {
+	setupTestPwd()
+	dep := vendoredGitDep("github.com/gopack-test/built", "branch", "master")
+	dep.PostInstallCommand = "make"
+	createSourceFixture(dep.Src(), "libbuilt.a", "built\n")
+	if err := lockDeps(t, dep).Write(); err != nil {
+		t.Fatal(err)
+	}
+	// git sees what make wrote
+	defer stubGitStatus("?? libbuilt.a\n")()
+
+	if err := dep.checkClean(); err != nil {
+		t.Errorf("Expected what postinstall wrote not to make the dep dirty, got %s\n", err)
+	}
+
+	createSourceFixture(dep.Src(), "main.go", "package patched\n")
+	if dirty, _ := dep.Dirty(); !dirty {
+		t.Errorf("Expected a change after postinstall to make the dep dirty\n")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// Compare what's checked out in the vendor tree with the lock and the
+// config without fetching or changing anything. Stripped deps have no
+// checkout to ask, their hash is verified instead. Deps linked to a local
+// checkout are flagged rather than compared.
+func (l *Lock) VerifyCheckouts(deps []*Dep) []*ProjectError {
+	errors := []*ProjectError{}
+
+	for _, dep := range deps {
+		if dep.Path != "" {
+			errors = append(errors, LocalPathError(dep.Import, dep.Path))
+			continue
+		}
+		locked := l.Deps[dep.Import]
+		// a gopkg.in dep without a tag of its major version is locked at
+		// the branch named after it
+		if locked != nil && dep.gopkgBranch != "" && locked.Checkout == "branch "+dep.gopkgBranch {
+			continue
+		}
+		if locked != nil && locked.Checkout != "" && dep.Checkout() != "" && locked.Checkout != dep.Checkout() {
+			errors = append(errors, RevisionDriftError(dep.Import, dep.Checkout()+" in gopack.config", locked.Checkout))
+		}
+	}
+
+	configured := make(map[string]*Dep)
+	for _, dep := range deps {
+		configured[dep.Import] = dep
+	}
+
+	for _, importPath := range l.Imports() {
+		if c := configured[importPath]; c != nil && c.Path != "" {
+			continue
+		}
+		locked := l.Deps[importPath]
+		dep := NewDependency(importPath)
+		if _, err := os.Stat(dep.Src()); os.IsNotExist(err) {
+			continue
+		}
+		scm := checkoutScm(dep)
+		if scm == nil {
+			continue
+		}
+
+		revision, err := scm.Revision(dep.Src())
+		if err != nil {
+			fmtcolor(Yellow, "      Warning: can't tell the revision of `%s`: %s\n", importPath, err)
+			continue
+		}
+		if locked.Revision != "" && revision != locked.Revision {
+			errors = append(errors, RevisionDriftError(importPath, locked.Revision, revision))
+		} else if c := configured[importPath]; c != nil && c.CheckoutFlag == CommitFlag && !strings.HasPrefix(revision, c.CheckoutSpec) {
+			errors = append(errors, RevisionDriftError(importPath, "commit "+c.CheckoutSpec, revision))
+		}
+
+		// patched in place on purpose
+		if locked.Trusted {
+			continue
+		}
+		if dirty, err := scm.Dirty(dep.Src()); err == nil && dirty {
+			errors = append(errors, LocalChangesError(importPath, dep.Src()))
+		}
+	}
+	return errors
+}
+
+// The scm of the checkout the dep is vendored in, nil once stripped.
+func checkoutScm(d *Dep) Scm {
+	dir := d.vcsDir()
+	for tag, hidden := range HiddenDirs {
+		if dir != "" && path.Base(dir) == hidden {
+			return Scms[tag]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Every vendored checkout is at the revision given, the dirty ones have
+// uncommitted changes.
+func stubCheckouts(revisions map[string]string, dirty ...string) func() {
+	importPath := func(cmd *exec.Cmd) string {
+		rel, _ := filepath.Rel(filepath.Join(pwd, VendorDir, "src"), cmd.Dir)
+		return rel
+	}
+	return stubCommands(commandStubs{
+		"rev-parse": func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte(revisions[importPath(cmd)] + "\n"), nil
+		},
+		"status": func(cmd *exec.Cmd) ([]byte, error) {
+			if containsString(dirty, importPath(cmd)) {
+				return []byte(" M main.go\n"), nil
+			}
+			return nil, nil
+		},
+	})
+}
+
+func vendorCheckouts(imports ...string) {
+	for _, importPath := range imports {
+		createPath(filepath.Join(dependencyPath(importPath), HiddenGit))
+	}
+}
+
+func TestVerifyCheckoutsFindsDrift(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	setupTestPwd()
+	vendorCheckouts("github.com/gopack-test/drift-ok", "github.com/gopack-test/drift-moved",
+		"github.com/gopack-test/drift-dirty", "github.com/gopack-test/drift-trusted")
+	defer stubCheckouts(map[string]string{
+		"github.com/gopack-test/drift-ok":      lockedSha,
+		"github.com/gopack-test/drift-moved":   movedSha,
+		"github.com/gopack-test/drift-dirty":   lockedSha,
+		"github.com/gopack-test/drift-trusted": lockedSha,
+	}, "github.com/gopack-test/drift-dirty", "github.com/gopack-test/drift-trusted")()
+
+	lock := NewLock()
+	for _, importPath := range []string{"github.com/gopack-test/drift-ok", "github.com/gopack-test/drift-moved",
+		"github.com/gopack-test/drift-dirty", "github.com/gopack-test/drift-trusted", "github.com/gopack-test/drift-missing"} {
+		lock.Deps[importPath] = &LockedDep{Import: importPath, Checkout: "branch master", Revision: lockedSha}
+	}
+	lock.Deps["github.com/gopack-test/drift-trusted"].Trusted = true
+
+	errors := lock.VerifyCheckouts([]*Dep{
+		gitDep("github.com/gopack-test/drift-ok", "branch", "master"),
+		gitDep("github.com/gopack-test/drift-dirty", "branch", "develop"),
+	})
+
+	expected := []string{
+		"github.com/gopack-test/drift-dirty should be at branch develop in gopack.config but is at branch master",
+		"github.com/gopack-test/drift-dirty has uncommitted changes",
+		"github.com/gopack-test/drift-moved should be at " + lockedSha + " but is at " + movedSha,
+	}
+	if len(errors) != len(expected) {
+		t.Errorf("Expected %d errors but found %v\n", len(expected), errors)
+	}
+	for i, e := range errors {
+		if i < len(expected) && !strings.HasPrefix(e.Message, expected[i]) {
+			t.Errorf("Expected %s but found %s\n", expected[i], e.Message)
+		}
+	}
+}
+
+func TestVerifyCheckoutsAgainstCommitSpec(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	setupTestPwd()
+	vendorCheckouts("github.com/gopack-test/drift-commit")
+	defer stubCheckouts(map[string]string{"github.com/gopack-test/drift-commit": movedSha})()
+
+	// locked without a revision, only the config says where it should be
+	lock := NewLock()
+	lock.Deps["github.com/gopack-test/drift-commit"] = &LockedDep{Import: "github.com/gopack-test/drift-commit"}
+
+	errors := lock.VerifyCheckouts([]*Dep{gitDep("github.com/gopack-test/drift-commit", "commit", lockedSha[:7])})
+	if len(errors) != 1 || errors[0].Kind != Drift {
+		t.Errorf("Expected the checkout to drift from the commit in the config but found %v\n", errors)
+	}
+}
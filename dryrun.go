@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// print what a command would do without doing it, set with --dry-run:
+// what gp clean or gp prune would remove, or what gp installdeps would
+// fetch and check out
+var dryRun = false
+
+// Print what installdeps would do to the dep once it's resolved without
+// fetching it. Deps without a checkout would be cloned, the others
+// switched when they resolve to another revision than the checked out one.
+func (d *Dep) printPlan(w io.Writer) {
+	if d.Path != "" {
+		fcolor(w, Yellow, "   Would link: `%s` to %s\n", d.Import, d.Path)
+		return
+	}
+
+	current := ""
+	if _, err := os.Stat(d.Src()); err == nil && d.vcsDir() != "" {
+		current, _ = d.CurrentRevision()
+	}
+
+	switch {
+	case current == "":
+		fcolor(w, Yellow, "  Would clone: `%s` at %s\n", d.Import, d.plannedCheckout())
+	case !d.fetch || (d.Revision != "" && strings.HasPrefix(current, d.Revision)):
+		fcolor(w, Gray, "   Would keep: `%s` at %s\n", d.Import, current)
+	case d.Revision == "":
+		fcolor(w, Yellow, "  Would fetch: `%s`, what it checks out is only known once fetched\n", d.Import)
+	default:
+		fcolor(w, Yellow, " Would switch: `%s` from %s to %s\n", d.Import, current, d.Revision)
+	}
+}
+
+func (d *Dep) plannedCheckout() string {
+	switch {
+	case d.Revision != "":
+		return d.Revision
+	case d.Checkout() != "":
+		return d.Checkout()
+	}
+	return "the default branch"
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestDryRunPlansWithoutFetching(t *testing.T) {
+	config := setupTestConfig(`
+[deps.new]
+  import = "github.com/gopack-test/dry-run-new"
+  scm = "git"
+  source = "https://github.com/gopack-test/dry-run-new.git"
+  branch = "master"
+
+[deps.moved]
+  import = "github.com/gopack-test/dry-run-moved"
+  scm = "git"
+  source = "https://github.com/gopack-test/dry-run-moved.git"
+  commit = "9d57c89788182cae2ee3926a960223d8db4998aa"
+
+[deps.kept]
+  import = "github.com/gopack-test/dry-run-kept"
+  scm = "git"
+  source = "https://github.com/gopack-test/dry-run-kept.git"
+  commit = "182cae2ee3926a960223d8db4998aa9d57c89788"
+`)
+	graphOnly, dryRun = true, true
+	defer func() { graphOnly, dryRun = false, false }()
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dep := range deps.DepList {
+		dep.fetch = true
+		if dep.Import != "github.com/gopack-test/dry-run-new" {
+			vendoredGitDep(dep.Import, "commit", lockedSha)
+		}
+	}
+
+	ran := []string{}
+	defer recordCommands(&ran, commandStubs{
+		"ls-remote": func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte(movedSha + "\trefs/heads/master\n"), nil
+		},
+		"rev-parse": func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte(lockedSha + "\n"), nil
+		},
+	})()
+
+	out := captureStdout(func() { loadTransitiveDependencies(deps) })
+
+	for _, expected := range []string{
+		"Would clone: `github.com/gopack-test/dry-run-new` at " + movedSha,
+		"Would switch: `github.com/gopack-test/dry-run-moved` from " + lockedSha + " to " + movedSha,
+		"Would keep: `github.com/gopack-test/dry-run-kept` at " + lockedSha,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected the plan to say %s, printed %s\n", expected, out)
+		}
+	}
+
+	if _, err := os.Stat(dependencyPath("github.com/gopack-test/dry-run-new")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be cloned with --dry-run\n")
+	}
+	for _, command := range ran {
+		if !strings.HasPrefix(command, "git ls-remote") && !strings.HasPrefix(command, "git rev-parse") {
+			t.Errorf("Expected only read only commands to run, ran %v\n", ran)
+		}
+	}
+}
+
+func TestDryRunPlansUnresolvedAndLocalDeps(t *testing.T) {
+	setupTestPwd()
+	dep := vendoredGitDep("github.com/gopack-test/dry-run-unresolved", "", "")
+	dep.fetch = true
+	dep.Revision = ""
+	local := &Dep{Import: "github.com/gopack-test/dry-run-local", Path: "../local"}
+
+	defer stubCommands(commandStubs{
+		"*": func(cmd *exec.Cmd) ([]byte, error) { return []byte(lockedSha), nil },
+	})()
+
+	var buf bytes.Buffer
+	dep.printPlan(&buf)
+	local.printPlan(&buf)
+
+	if !strings.Contains(buf.String(), "Would fetch: `github.com/gopack-test/dry-run-unresolved`") {
+		t.Errorf("Expected an unresolved dep to be fetched, printed %s\n", buf.String())
+	}
+	if !strings.Contains(buf.String(), "Would link: `github.com/gopack-test/dry-run-local` to ../local") {
+		t.Errorf("Expected a local dep to be linked, printed %s\n", buf.String())
+	}
+}
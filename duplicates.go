@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// list the deps vendored at more than one import path, set with graph --duplicates
+var graphDuplicates = false
+
+// Deps in the graph checked out from the same source at the same
+// revision under different import paths, aliases of one another.
+func (d *Dependencies) Duplicates() [][]*Dep {
+	groups := make(map[string][]*Dep)
+	keys := []string{}
+	seen := make(map[string]bool)
+	for e := d.ImportGraph.Leafs.Front(); e != nil; e = e.Next() {
+		importPath := e.Value.(string)
+		node := d.ImportGraph.Search(importPath)
+		if seen[importPath] || node == nil || node.Dependency == nil || node.Dependency.Revision == "" {
+			continue
+		}
+		seen[importPath] = true
+
+		dep := node.Dependency
+		key := normalizeSource(dep.RemoteURL()) + "@" + dep.Revision
+		if _, found := groups[key]; !found {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], dep)
+	}
+
+	duplicates := [][]*Dep{}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			sort.Sort(byImport(groups[key]))
+			duplicates = append(duplicates, groups[key])
+		}
+	}
+	return duplicates
+}
+
+// The same repository written as https, ssh or scp-like urls, with or
+// without .git, is the same source.
+func normalizeSource(source string) string {
+	if host, ok := sshHost(source); ok && !strings.Contains(source, "://") {
+		source = host + "/" + source[strings.Index(source, ":")+1:]
+	} else if u, err := url.Parse(source); err == nil && u.Host != "" {
+		source = u.Host + u.Path
+	}
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(source, "/"), ".git"))
+}
+
+func (d *Dependencies) PrintDuplicates(w io.Writer) {
+	duplicates := d.Duplicates()
+	for _, group := range duplicates {
+		imports := []string{}
+		for _, dep := range group {
+			imports = append(imports, dep.Import)
+		}
+		fmt.Fprintf(w, "%s at %s\n", strings.Join(imports, ", "), group[0].Revision)
+	}
+	fmt.Fprintf(w, "%d deps vendored at more than one import path\n", len(duplicates))
+}
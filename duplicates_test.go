@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDuplicatesReportsAliases(t *testing.T) {
+	deps := &Dependencies{ImportGraph: NewGraph()}
+	for _, dep := range []*Dep{
+		{Import: "gopkg.in/gopack-test/yaml.v2", Scm: GitTag, Source: "https://github.com/gopack-test/yaml.git", Revision: "9fceb02d0ae598e95dc970b74767f19372d61af8"},
+		{Import: "github.com/gopack-test/yaml", Scm: "go", Revision: "9fceb02d0ae598e95dc970b74767f19372d61af8"},
+		{Import: "github.com/gopack-test/yaml-fork", Scm: GitTag, Source: "git@github.com:gopack-test/yaml.git", Revision: "9fceb02d0ae598e95dc970b74767f19372d61af8"},
+		// same source at another revision isn't a duplicate
+		{Import: "gopkg.in/gopack-test/yaml.v1", Scm: GitTag, Source: "https://github.com/gopack-test/yaml.git", Revision: "182cae2ee3926a960223d8db4998aa9d57c89788"},
+		{Import: "github.com/gopack-test/other", Scm: "go", Revision: "9fceb02d0ae598e95dc970b74767f19372d61af8"},
+	} {
+		deps.ImportGraph.Insert(dep)
+	}
+
+	var out bytes.Buffer
+	deps.PrintDuplicates(&out)
+
+	expected := "github.com/gopack-test/yaml, github.com/gopack-test/yaml-fork, gopkg.in/gopack-test/yaml.v2 at 9fceb02d0ae598e95dc970b74767f19372d61af8\n" +
+		"1 deps vendored at more than one import path\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\nbut printed\n%s\n", expected, out.String())
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const Masked = "xxxxx"
+
+var (
+	// print the environment used for fetches, set with --print-env
+	printEnv = false
+
+	sensitiveKeys = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIAL", "AUTH", "API_KEY", "PRIVATE_KEY"}
+
+	// curl, and so git over http, only reads some of them in lowercase
+	proxyKeys = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+)
+
+// The environment the scm commands that fetch dependencies run with.
+func fetchEnv() []string {
+	// a private repo without credentials fails instead of waiting for a
+	// password nobody is going to type
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	env = append(env, proxyEnv()...)
+	if knownHostsPath != "" {
+		env = append(env, "GIT_SSH_COMMAND="+sshCommand())
+	}
+	return env
+}
+
+// The proxy settings in both cases, whichever one was set, so every scm
+// and the helpers it runs go through the proxy.
+func proxyEnv() []string {
+	env := []string{}
+	for _, key := range proxyKeys {
+		upper, hasUpper := os.LookupEnv(key)
+		lower, hasLower := os.LookupEnv(strings.ToLower(key))
+		switch {
+		case hasUpper && !hasLower:
+			env = append(env, strings.ToLower(key)+"="+upper)
+		case hasLower && !hasUpper:
+			env = append(env, key+"="+lower)
+		}
+	}
+	return env
+}
+
+func PrintFetchEnv(w io.Writer) {
+	env := fetchEnv()
+	sort.Strings(env)
+	for _, kv := range env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fmt.Fprintf(w, "%s=%s\n", parts[0], maskEnvValue(parts[0], parts[1]))
+	}
+}
+
+func maskEnvValue(key, value string) string {
+	if value == "" {
+		return value
+	}
+	upper := strings.ToUpper(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(upper, s) {
+			return Masked
+		}
+	}
+	return maskCredentials(value)
+}
+
+// Hide the password of a url with embedded credentials, anything else
+// is returned as is.
+func maskCredentials(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, hasPassword := u.User.Password(); !hasPassword {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), Masked)
+	return u.String()
+}
+
+var credentialsPattern = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+):[^/\s@]+@`)
+
+// Hide the passwords of every url with embedded credentials found in
+// text, scm output for instance.
+func maskURLCredentials(text string) string {
+	return credentialsPattern.ReplaceAllString(text, "$1:"+Masked+"@")
+}
+
+// Whether a url has a password embedded in it.
+func hasCredentials(s string) bool {
+	return maskCredentials(s) != s
+}
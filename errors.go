@@ -2,11 +2,21 @@ package main
 
 import (
 	"fmt"
+	"strings"
 )
 
 const (
 	UnusedDep       = "unused-dep"
 	UnmanagedImport = "unmanaged-import"
+	IntegrityError  = "integrity-mismatch"
+	BuildFailure    = "build-failure"
+	KnownIssue      = "known-issue"
+	DependencyCycle = "dependency-cycle"
+	Conflict        = "version-conflict"
+	Drift           = "revision-drift"
+	LocalChanges    = "local-changes"
+	InvalidImport   = "invalid-import"
+	LocalPath       = "local-path"
 )
 
 type ProjectError struct {
@@ -21,14 +31,87 @@ func UnusedDependencyError(importPath string) *ProjectError {
 	}
 }
 
-func UnmanagedImportError(s *ImportStats) *ProjectError {
-	msg := fmt.Sprintf("%s referenced in the following locations but not managed in gopack.config\n%s", s.Path, s.ReferenceList())
+// The imports of a repository that no dep governs, reported once with
+// every place they're imported.
+func UnmanagedImportError(root string, imports []*ImportStats) *ProjectError {
+	refs := []string{}
+	for _, s := range imports {
+		refs = append(refs, s.ReferenceList())
+	}
+	msg := fmt.Sprintf("%s is imported in the following locations but not managed in gopack.config, add it to [deps]\n%s\n", root, strings.Join(refs, "\n"))
 	return &ProjectError{
 		UnmanagedImport,
 		msg,
 	}
 }
 
+func IntegrityMismatchError(importPath string) *ProjectError {
+	return &ProjectError{
+		IntegrityError,
+		fmt.Sprintf("%s in vendor does not match the hash in %s\n", importPath, GopackLock),
+	}
+}
+
+func BuildFailureError(command, output string) *ProjectError {
+	return &ProjectError{
+		BuildFailure,
+		fmt.Sprintf("%s failed against the vendored dependencies\n%s", command, output),
+	}
+}
+
+func AdvisoryError(locked *LockedDep, a *Advisory) *ProjectError {
+	at := locked.Checkout
+	if at == "" {
+		at = locked.Revision
+	}
+	return &ProjectError{
+		KnownIssue,
+		fmt.Sprintf("%s at %s is affected by %s: %s\n", locked.Import, at, a.ID, a.Summary),
+	}
+}
+
+func DependencyCycleError(cycle []string) *ProjectError {
+	return &ProjectError{
+		DependencyCycle,
+		fmt.Sprintf("dependency cycle %s\n", strings.Join(cycle, " -> ")),
+	}
+}
+
+func VersionConflictError(c VersionConflict) *ProjectError {
+	return &ProjectError{
+		Conflict,
+		fmt.Sprintf("%s, pin it in [override] or run with --allow-conflicts\n", c),
+	}
+}
+
+func RevisionDriftError(importPath, expected, actual string) *ProjectError {
+	return &ProjectError{
+		Drift,
+		fmt.Sprintf("%s should be at %s but is at %s\n", importPath, expected, actual),
+	}
+}
+
+func LocalChangesError(importPath, src string) *ProjectError {
+	return &ProjectError{
+		LocalChanges,
+		fmt.Sprintf("%s has uncommitted changes in %s\n", importPath, src),
+	}
+}
+
+func InvalidImportError(importPath, reason string) *ProjectError {
+	return &ProjectError{
+		InvalidImport,
+		fmt.Sprintf("%s in gopack.config %s\n", importPath, reason),
+	}
+}
+
+func LocalPathError(importPath, path string) *ProjectError {
+	return &ProjectError{
+		LocalPath,
+		fmt.Sprintf("%s is linked to the local checkout %s, it isn't pinned\n", importPath, path),
+	}
+}
+
 func (e *ProjectError) String() string {
 	return e.Message
 }
@@ -36,3 +119,22 @@ func (e *ProjectError) String() string {
 func (e *ProjectError) Error() string {
 	return e.String()
 }
+
+// Project errors returned together, gopack exits with how many there are.
+type ProjectErrors []*ProjectError
+
+func (errors ProjectErrors) Error() string {
+	messages := []string{}
+	for _, e := range errors {
+		messages = append(messages, e.String())
+	}
+	return strings.Join(messages, "")
+}
+
+// The errors as an error, nil when there are none.
+func projectErrors(errors []*ProjectError) error {
+	if len(errors) == 0 {
+		return nil
+	}
+	return ProjectErrors(errors)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+)
+
+// Whether the import, or a package below it, is in the exclude list.
+func excluded(importPath string, excludes []string) bool {
+	for _, e := range excludes {
+		e = strings.TrimSuffix(e, "/")
+		if importPath == e || strings.HasPrefix(importPath, e+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// The imports of the project that are only unmanaged because they're
+// excluded, they're warned about instead of failing validation.
+func (d *Dependencies) warnExcluded(s *ImportStats) bool {
+	if !excluded(s.Path, d.Excludes) {
+		return false
+	}
+	fmtcolor(Yellow, "      Warning: `%s` is excluded in gopack.config but imported in\n%s", s.Path, s.ReferenceList())
+	return true
+}
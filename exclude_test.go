@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExcludedMatchesPackagesBelow(t *testing.T) {
+	excludes := []string{"github.com/gopack-test/heavy", "github.com/gopack-test/suite/"}
+	for importPath, expected := range map[string]bool{
+		"github.com/gopack-test/heavy":        true,
+		"github.com/gopack-test/heavy/assert": true,
+		"github.com/gopack-test/suite/mock":   true,
+		"github.com/gopack-test/heavyweight":  false,
+		"github.com/gopack-test/light":        false,
+	} {
+		if excluded(importPath, excludes) != expected {
+			t.Errorf("Expected %s excluded to be %v\n", importPath, expected)
+		}
+	}
+}
+
+func TestTransitiveDepsAreExcluded(t *testing.T) {
+	setupTestPwd()
+	parent := NewDependency("github.com/gopack-test/exclude-parent")
+	createPath(parent.Src())
+	createFixtureConfig(parent.Src(), `
+[deps.heavy]
+  import = "github.com/gopack-test/exclude-heavy"
+  branch = "master"
+
+[deps.light]
+  import = "github.com/gopack-test/exclude-light"
+  branch = "master"
+`)
+
+	graph := NewGraph()
+	deps, err := parent.LoadTransitiveDeps(graph, []string{"github.com/gopack-test/exclude-heavy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps.DepList) != 1 || deps.DepList[0].Import != "github.com/gopack-test/exclude-light" {
+		t.Errorf("Expected only the light dep to be loaded, got %v\n", deps.Imports)
+	}
+	if graph.Search("github.com/gopack-test/exclude-heavy") != nil {
+		t.Errorf("Expected the excluded dep not to be in the graph\n")
+	}
+}
+
+func TestExcludedImportsOnlyWarn(t *testing.T) {
+	config := setupTestConfig(`
+exclude = ["github.com/gopack-test/exclude-suite"]
+`)
+	createSourceFixture(pwd, "main.go", "package main\n\nimport _ \"github.com/gopack-test/exclude-suite/assert\"\n")
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errors []*ProjectError
+	out := captureStdout(func() { errors = deps.Validate(p) })
+	if len(errors) != 0 {
+		t.Errorf("Expected the excluded import not to fail validation, got %v\n", errors)
+	}
+	if !strings.Contains(out, "Warning: `github.com/gopack-test/exclude-suite/assert` is excluded in gopack.config") {
+		t.Errorf("Expected a warning about the excluded import, printed %s\n", out)
+	}
+}
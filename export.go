@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Writes the graph in one format, gp graph --export <format>.
+type Exporter interface {
+	Export(w io.Writer, g *GraphJSON) error
+}
+
+var Exporters = map[string]Exporter{
+	"dot":      DotExporter{},
+	"json":     JSONExporter{},
+	"edgelist": EdgeListExporter{},
+	"mermaid":  MermaidExporter{},
+}
+
+func ExportFormats() string {
+	formats := []string{}
+	for format := range Exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return strings.Join(formats, ", ")
+}
+
+type GraphJSON struct {
+	Nodes []*GraphNodeJSON `json:"nodes"`
+	Edges []*GraphEdgeJSON `json:"edges"`
+}
+
+type GraphNodeJSON struct {
+	Import   string `json:"import"`
+	Checkout string `json:"checkout,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	// declared in the project's own gopack.config
+	Root bool `json:"root"`
+	Dev  bool `json:"dev"`
+}
+
+type GraphEdgeJSON struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// The resolved graph as a node and edge list, both sorted.
+func (d *Dependencies) GraphJSON() *GraphJSON {
+	g := &GraphJSON{Nodes: []*GraphNodeJSON{}, Edges: []*GraphEdgeJSON{}}
+
+	roots := make(map[string]bool)
+	for _, importPath := range d.Imports {
+		roots[importPath] = true
+	}
+
+	seen := make(map[string]bool)
+	for e := d.ImportGraph.Leafs.Front(); e != nil; e = e.Next() {
+		importPath := e.Value.(string)
+		node := d.ImportGraph.Search(importPath)
+		if seen[importPath] || node == nil || node.Dependency == nil {
+			continue
+		}
+		seen[importPath] = true
+
+		dep := node.Dependency
+		g.Nodes = append(g.Nodes, &GraphNodeJSON{dep.Import, dep.Checkout(), dep.Revision, roots[importPath], dep.Dev})
+	}
+	sort.Sort(byNodeImport(g.Nodes))
+
+	parents := []string{}
+	for parent := range d.ImportGraph.Edges {
+		parents = append(parents, parent)
+	}
+	sort.Strings(parents)
+	for _, parent := range parents {
+		children := append([]string{}, d.ImportGraph.Edges[parent]...)
+		sort.Strings(children)
+		for _, child := range children {
+			g.Edges = append(g.Edges, &GraphEdgeJSON{parent, child})
+		}
+	}
+	return g
+}
+
+type byNodeImport []*GraphNodeJSON
+
+func (s byNodeImport) Len() int           { return len(s) }
+func (s byNodeImport) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byNodeImport) Less(i, j int) bool { return s[i].Import < s[j].Import }
+
+type JSONExporter struct{}
+
+func (e JSONExporter) Export(w io.Writer, g *GraphJSON) error {
+	out, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+type DotExporter struct{}
+
+// Leaves, the deps that depend on nothing, are drawn as boxes.
+func (e DotExporter) Export(w io.Writer, g *GraphJSON) error {
+	parents := make(map[string]bool)
+	for _, e := range g.Edges {
+		parents[e.From] = true
+	}
+
+	fmt.Fprintln(w, "digraph dependencies {")
+	for _, n := range g.Nodes {
+		if parents[n.Import] {
+			fmt.Fprintf(w, "  %q [label=%q];\n", n.Import, n.label())
+		} else {
+			fmt.Fprintf(w, "  %q [label=%q, shape=box];\n", n.Import, n.label())
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  %q -> %q;\n", e.From, e.To)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+type EdgeListExporter struct{}
+
+func (e EdgeListExporter) Export(w io.Writer, g *GraphJSON) error {
+	for _, e := range g.Edges {
+		if _, err := fmt.Fprintf(w, "%s %s\n", e.From, e.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A flowchart to embed in markdown. Import paths aren't valid mermaid
+// ids so the nodes are numbered in import order.
+type MermaidExporter struct{}
+
+func (e MermaidExporter) Export(w io.Writer, g *GraphJSON) error {
+	ids := make(map[string]string)
+	fmt.Fprintln(w, "flowchart TD")
+	for i, n := range g.Nodes {
+		ids[n.Import] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(w, "  %s[\"%s\"]\n", ids[n.Import], n.label())
+	}
+	for _, e := range g.Edges {
+		if ids[e.From] == "" || ids[e.To] == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "  %s --> %s\n", ids[e.From], ids[e.To]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *GraphNodeJSON) label() string {
+	if n.Checkout == "" {
+		return n.Import
+	}
+	return n.Import + " @ " + n.Checkout
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// app -> lib -> util and app -> util, util has no checkout
+func exportFixture() *GraphJSON {
+	app := gitDep("github.com/gopack-test/app", "branch", "master")
+	lib := gitDep("github.com/gopack-test/lib", "tag", "v1.0")
+	util := gitDep("github.com/gopack-test/util", "", "")
+
+	graph := NewGraph()
+	for _, dep := range []*Dep{util, lib, app} {
+		graph.Insert(dep)
+	}
+	graph.AddEdge(app.Import, util.Import)
+	graph.AddEdge(app.Import, lib.Import)
+	graph.AddEdge(lib.Import, util.Import)
+
+	deps := &Dependencies{Imports: []string{app.Import}, DepList: []*Dep{app}, ImportGraph: graph}
+	return deps.GraphJSON()
+}
+
+func TestExportFormats(t *testing.T) {
+	expected := map[string]string{
+		"dot": `digraph dependencies {
+  "github.com/gopack-test/app" [label="github.com/gopack-test/app @ branch master"];
+  "github.com/gopack-test/lib" [label="github.com/gopack-test/lib @ tag v1.0"];
+  "github.com/gopack-test/util" [label="github.com/gopack-test/util", shape=box];
+  "github.com/gopack-test/app" -> "github.com/gopack-test/lib";
+  "github.com/gopack-test/app" -> "github.com/gopack-test/util";
+  "github.com/gopack-test/lib" -> "github.com/gopack-test/util";
+}
+`,
+		"edgelist": `github.com/gopack-test/app github.com/gopack-test/lib
+github.com/gopack-test/app github.com/gopack-test/util
+github.com/gopack-test/lib github.com/gopack-test/util
+`,
+		"mermaid": `flowchart TD
+  n0["github.com/gopack-test/app @ branch master"]
+  n1["github.com/gopack-test/lib @ tag v1.0"]
+  n2["github.com/gopack-test/util"]
+  n0 --> n1
+  n0 --> n2
+  n1 --> n2
+`,
+		"json": `{
+  "nodes": [
+    {
+      "import": "github.com/gopack-test/app",
+      "checkout": "branch master",
+      "root": true,
+      "dev": false
+    },
+    {
+      "import": "github.com/gopack-test/lib",
+      "checkout": "tag v1.0",
+      "root": false,
+      "dev": false
+    },
+    {
+      "import": "github.com/gopack-test/util",
+      "root": false,
+      "dev": false
+    }
+  ],
+  "edges": [
+    {
+      "from": "github.com/gopack-test/app",
+      "to": "github.com/gopack-test/lib"
+    },
+    {
+      "from": "github.com/gopack-test/app",
+      "to": "github.com/gopack-test/util"
+    },
+    {
+      "from": "github.com/gopack-test/lib",
+      "to": "github.com/gopack-test/util"
+    }
+  ]
+}
+`,
+	}
+
+	if len(expected) != len(Exporters) {
+		t.Errorf("Expected a test for each of %s\n", ExportFormats())
+	}
+
+	for format, content := range expected {
+		var out bytes.Buffer
+		if err := Exporters[format].Export(&out, exportFixture()); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != content {
+			t.Errorf("Expected the %s export to be\n%s\nbut was\n%s\n", format, content, out.String())
+		}
+	}
+}
+
+func TestDotEmitsSharedEdgesOnce(t *testing.T) {
+	app := gitDep("github.com/gopack-test/dot-app", "", "")
+	util := gitDep("github.com/gopack-test/dot-util", "", "")
+	graph := NewGraph()
+	graph.Insert(util)
+	graph.Insert(app)
+	// both the root config and a transitive config ask for util
+	graph.AddEdge(app.Import, util.Import)
+	graph.AddEdge(app.Import, util.Import)
+
+	deps := &Dependencies{Imports: []string{app.Import}, DepList: []*Dep{app}, ImportGraph: graph}
+	var out bytes.Buffer
+	if err := (DotExporter{}).Export(&out, deps.GraphJSON()); err != nil {
+		t.Fatal(err)
+	}
+	if edges := strings.Count(out.String(), " -> "); edges != 1 {
+		t.Errorf("Expected the edge to be emitted once, got %d in\n%s\n", edges, out.String())
+	}
+}
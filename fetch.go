@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// attempts made against every source before moving to the next one,
+	// unless GOPACK_FETCH_ATTEMPTS says otherwise
+	FetchAttempts = 3
+	// wait before the first retry, doubled after every failed attempt
+	FetchBackoff = time.Second
+	// how long a clone, fetch or ls-remote may take, unless
+	// GOPACK_FETCH_TIMEOUT says otherwise
+	FetchTimeout = 120 * time.Second
+	// no retry starts once the attempts against a source took this long,
+	// so a remote timing out every time doesn't hold the run up for long
+	FetchRetryLimit = 5 * time.Minute
+
+	sleep = time.Sleep
+)
+
+// Retry fn with exponential backoff, returning how many attempts it took.
+// Failures that won't go away by trying again aren't retried.
+func withRetries(fn func() error) (attempts int, err error) {
+	max := fetchAttempts()
+	backoff := FetchBackoff
+	started := timeNow()
+	for attempts < max {
+		attempts++
+		if err = fn(); err == nil || !retryable(err) || timeNow().Sub(started) >= FetchRetryLimit {
+			return
+		}
+		if attempts < max {
+			sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return
+}
+
+func fetchAttempts() int {
+	attempts := os.Getenv("GOPACK_FETCH_ATTEMPTS")
+	if attempts == "" {
+		return FetchAttempts
+	}
+	if n, err := strconv.Atoi(attempts); err == nil && n > 0 {
+		return n
+	}
+	fmt.Printf("      Warning: invalid GOPACK_FETCH_ATTEMPTS `%s`, using %d\n", attempts, FetchAttempts)
+	return FetchAttempts
+}
+
+// The timeout is either a go duration ("90s", "5m") or a number of
+// seconds, 0 waits forever.
+func fetchTimeout() time.Duration {
+	timeout := os.Getenv("GOPACK_FETCH_TIMEOUT")
+	if timeout == "" {
+		return FetchTimeout
+	}
+	if d, err := time.ParseDuration(timeout); err == nil && d >= 0 {
+		return d
+	}
+	if secs, err := strconv.Atoi(timeout); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	fmt.Printf("      Warning: invalid GOPACK_FETCH_TIMEOUT `%s`, using %s\n", timeout, FetchTimeout)
+	return FetchTimeout
+}
+
+// Get url over http, reading the body included, within the fetch timeout
+// so a server that stops answering doesn't hang the run.
+func httpGet(url string) (*http.Response, error) {
+	client := &http.Client{Timeout: fetchTimeout()}
+	return client.Get(url)
+}
+
+// A scm command killed for taking longer than the fetch timeout.
+type TimeoutError struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s, set GOPACK_FETCH_TIMEOUT to wait longer", e.Command, e.Timeout)
+}
+
+// Run a scm command that talks to a remote, killing it once it takes
+// longer than the fetch timeout so a hanging proxy doesn't block the
+// whole run.
+func runFetch(cmd *exec.Cmd) ([]byte, error) {
+	timeout := fetchTimeout()
+	if timeout == 0 {
+		return execute(cmd)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	timed := exec.CommandContext(ctx, cmd.Args[0], cmd.Args[1:]...)
+	timed.Dir, timed.Env = cmd.Dir, cmd.Env
+	// helpers git started, like git-remote-https, may keep the output open
+	timed.WaitDelay = time.Second
+
+	out, err := execute(timed)
+	if ctx.Err() == context.DeadlineExceeded {
+		// only the subcommand, the rest may be a url with credentials
+		command := cmd.Args[0]
+		if len(cmd.Args) > 1 {
+			command += " " + cmd.Args[1]
+		}
+		return out, &TimeoutError{command, timeout}
+	}
+	return out, err
+}
+
+// A repository that doesn't exist or refuses the credentials fails the
+// same way every time. Anything else, a timeout included, may be the
+// network.
+func retryable(err error) bool {
+	if scmErr, ok := err.(*ScmError); ok {
+		return !scmErr.NotFound() && !scmErr.AuthFailed()
+	}
+	return true
+}
+
+type FetchAttempt struct {
+	Source   string
+	Attempts int
+	Err      error
+}
+
+// Every source tried for a dependency, and how each of them failed.
+type FetchError struct {
+	Import string
+	Tried  []FetchAttempt
+}
+
+func (e *FetchError) Error() string {
+	tried := []string{}
+	for _, t := range e.Tried {
+		tried = append(tried, fmt.Sprintf("%s (%d attempts): %s", maskCredentials(t.Source), t.Attempts, t.Err))
+	}
+	return fmt.Sprintf("Error downloading dependency %s, tried %d sources\n  %s", e.Import, len(tried), strings.Join(tried, "\n  "))
+}
+
+// Whether every source failed because the repository doesn't exist.
+func (e *FetchError) NotFound() bool {
+	for _, t := range e.Tried {
+		scmErr, ok := t.Err.(*ScmError)
+		if !ok || !scmErr.NotFound() {
+			return false
+		}
+	}
+	return len(e.Tried) > 0
+}
+
+// Try every source in order, retrying each before falling back to the
+// next one. The error lists every source tried and how often.
+func fetchWithFallback(importPath string, sources []string, fetch func(source string) error) error {
+	fetchErr := &FetchError{Import: importPath}
+	for _, source := range sources {
+		attempts, err := withRetries(func() error { return fetch(source) })
+		if err == nil {
+			return nil
+		}
+		fetchErr.Tried = append(fetchErr.Tried, FetchAttempt{source, attempts, err})
+	}
+	return fetchErr
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fake clones failing for the given number of attempts per source.
+func stubClones(failures map[string]int, cloned *[]string) func() {
+	origSleep := sleep
+	sleep = func(time.Duration) {}
+	restore := stubCommands(commandStubs{"clone": func(cmd *exec.Cmd) ([]byte, error) {
+		source := cmd.Args[2]
+		*cloned = append(*cloned, source)
+		if failures[source] != 0 {
+			failures[source]--
+			return []byte("fatal: unable to access " + source), errors.New("exit status 128")
+		}
+		return nil, nil
+	}})
+	return func() { restore(); sleep = origSleep }
+}
+
+func mirroredDep() *Dep {
+	return &Dep{
+		Import:  "github.com/gopack-test/mirrored",
+		Scm:     GitTag,
+		Source:  "https://github.com/gopack-test/mirrored.git",
+		Mirrors: []string{"https://mirror-a.example.com/mirrored.git", "https://mirror-b.example.com/mirrored.git"},
+		fetch:   true,
+	}
+}
+
+func TestRetriesBeforeSwitchingMirror(t *testing.T) {
+	setupTestPwd()
+	dep := mirroredDep()
+
+	cloned := []string{}
+	defer stubClones(map[string]int{dep.Source: 3, dep.Mirrors[0]: 1}, &cloned)()
+
+	if err := dep.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{dep.Source, dep.Source, dep.Source, dep.Mirrors[0], dep.Mirrors[0]}
+	if strings.Join(cloned, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected clones from\n%v\nbut were\n%v", expected, cloned)
+	}
+}
+
+func TestFallbackErrorSummarizesSources(t *testing.T) {
+	setupTestPwd()
+	dep := mirroredDep()
+
+	cloned := []string{}
+	defer stubClones(map[string]int{dep.Source: 3, dep.Mirrors[0]: 3, dep.Mirrors[1]: 3}, &cloned)()
+
+	err := dep.Get()
+	if err == nil {
+		t.Fatal("Expected the download to fail when every source fails")
+	}
+
+	if len(cloned) != 9 {
+		t.Errorf("Expected 3 attempts for each of the 3 sources but made %d\n", len(cloned))
+	}
+
+	for _, source := range dep.Sources() {
+		if !strings.Contains(err.Error(), source+" (3 attempts)") {
+			t.Errorf("Expected the error to mention %s in\n%s", source, err)
+		}
+	}
+}
+
+func TestBackoffDoubles(t *testing.T) {
+	origSleep := sleep
+	defer func() { sleep = origSleep }()
+
+	waits := []time.Duration{}
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	attempts, _ := withRetries(func() error { return errors.New("timeout") })
+	if attempts != FetchAttempts {
+		t.Errorf("Expected %d attempts but made %d\n", FetchAttempts, attempts)
+	}
+
+	if len(waits) != 2 || waits[1] != 2*waits[0] {
+		t.Errorf("Expected the backoff to double between retries, waited %v\n", waits)
+	}
+}
+
+func TestSameMirrorChosenAcrossResolves(t *testing.T) {
+	config := setupTestConfig(`
+[deps.mirrored]
+  import = "github.com/gopack-test/mirrored"
+  scm = "git"
+  source = "https://github.com/gopack-test/mirrored.git"
+  mirrors = ["https://mirror-b.example.com/mirrored.git", "https://mirror-a.example.com/mirrored.git", "https://mirror-c.example.com/mirrored.git", "https://mirror-a.example.com/mirrored.git"]
+`)
+
+	for i := 0; i < 5; i++ {
+		deps, err := config.LoadDependencyModel(NewGraph())
+		if err != nil {
+			t.Fatal(err)
+		}
+		dep := deps.DepList[0]
+
+		sources := strings.Join(dep.Sources(), " ")
+		if sources != "https://github.com/gopack-test/mirrored.git https://mirror-b.example.com/mirrored.git https://mirror-a.example.com/mirrored.git https://mirror-c.example.com/mirrored.git" {
+			t.Errorf("Expected the sources in config order without repeats, found %s\n", sources)
+		}
+
+		// the source is down and the first two mirrors work
+		cloned := []string{}
+		restore := stubClones(map[string]int{dep.Source: 3}, &cloned)
+		err = fetchWithFallback(dep.Import, dep.Sources(), func(source string) error {
+			return runScm(Git{}.DownloadCommand(source, ""))
+		})
+		restore()
+
+		if err != nil || cloned[len(cloned)-1] != "https://mirror-b.example.com/mirrored.git" {
+			t.Errorf("Expected the first listed mirror to be chosen, cloned %v\n", cloned)
+		}
+	}
+}
+
+func TestFatalFailuresAreNotRetried(t *testing.T) {
+	setupTestPwd()
+	origSleep := sleep
+	defer func() { sleep = origSleep }()
+	sleep = func(time.Duration) {}
+
+	attempts := 0
+	defer stubCommands(commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			attempts++
+			return []byte("fatal: Authentication failed for 'https://github.com/gopack-test/private.git/'"), errors.New("exit status 128")
+		},
+	})()
+
+	dep := gitDep("github.com/gopack-test/private", "", "")
+	if err := dep.Get(); err == nil {
+		t.Fatal("Expected the clone to fail")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a failed authentication not to be retried but made %d attempts\n", attempts)
+	}
+}
+
+func TestFetchAttemptsFromEnvironment(t *testing.T) {
+	setupTestPwd()
+	os.Setenv("GOPACK_FETCH_ATTEMPTS", "5")
+	defer os.Unsetenv("GOPACK_FETCH_ATTEMPTS")
+
+	// the network fails 4 times before the clone goes through
+	dep := gitDep("github.com/gopack-test/flaky", "", "")
+	cloned := []string{}
+	defer stubClones(map[string]int{dep.Source: 4}, &cloned)()
+
+	if err := dep.Get(); err != nil {
+		t.Errorf("Expected the clone to succeed on the 5th attempt, got %s\n", err)
+	}
+	if len(cloned) != 5 {
+		t.Errorf("Expected 5 attempts but made %d\n", len(cloned))
+	}
+
+	os.Setenv("GOPACK_FETCH_ATTEMPTS", "none")
+	if fetchAttempts() != FetchAttempts {
+		t.Errorf("Expected an invalid GOPACK_FETCH_ATTEMPTS to fall back to %d\n", FetchAttempts)
+	}
+}
+
+func TestHangingFetchTimesOut(t *testing.T) {
+	os.Setenv("GOPACK_FETCH_TIMEOUT", "100ms")
+	defer os.Unsetenv("GOPACK_FETCH_TIMEOUT")
+
+	started := time.Now()
+	err := runScm(exec.Command("sleep", "5"))
+	if time.Since(started) > 3*time.Second {
+		t.Errorf("Expected the command to be killed after the timeout, took %s\n", time.Since(started))
+	}
+	if err == nil || err.Error() != "sleep 5 timed out after 100ms, set GOPACK_FETCH_TIMEOUT to wait longer" {
+		t.Errorf("Expected a timeout error, got %v\n", err)
+	}
+	if !retryable(&ScmError{Err: err}) {
+		t.Errorf("Expected a timeout to be retried\n")
+	}
+}
+
+func TestTimeoutsAreRetriedUpToTheLimit(t *testing.T) {
+	origSleep, origNow := sleep, timeNow
+	defer func() { sleep, timeNow = origSleep, origNow }()
+	sleep = func(time.Duration) {}
+	clock := time.Now()
+	timeNow = func() time.Time { return clock }
+
+	os.Setenv("GOPACK_FETCH_ATTEMPTS", "10")
+	defer os.Unsetenv("GOPACK_FETCH_ATTEMPTS")
+
+	// every attempt hangs until the 2 minute timeout
+	attempts, err := withRetries(func() error {
+		clock = clock.Add(2 * time.Minute)
+		return &ScmError{Err: &TimeoutError{"git clone", 2 * time.Minute}}
+	})
+	if err == nil || attempts != 3 {
+		t.Errorf("Expected timeouts to be retried until %s went by, made %d attempts\n", FetchRetryLimit, attempts)
+	}
+}
+
+func TestFetchTimeoutFromEnvironment(t *testing.T) {
+	defer os.Unsetenv("GOPACK_FETCH_TIMEOUT")
+	for value, expected := range map[string]time.Duration{"": FetchTimeout, "5m": 5 * time.Minute, "30": 30 * time.Second, "0": 0, "soon": FetchTimeout} {
+		os.Setenv("GOPACK_FETCH_TIMEOUT", value)
+		if timeout := fetchTimeout(); timeout != expected {
+			t.Errorf("Expected GOPACK_FETCH_TIMEOUT=%s to wait %s, got %s\n", value, expected, timeout)
+		}
+	}
+}
+
+func TestHttpGetTimesOut(t *testing.T) {
+	os.Setenv("GOPACK_FETCH_TIMEOUT", "100ms")
+	defer os.Unsetenv("GOPACK_FETCH_TIMEOUT")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	started := time.Now()
+	if _, err := httpGet(server.URL); err == nil {
+		t.Errorf("Expected a server that doesn't answer to time out\n")
+	}
+	if time.Since(started) > 900*time.Millisecond {
+		t.Errorf("Expected the request to give up after the timeout, took %s\n", time.Since(started))
+	}
+}
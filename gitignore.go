@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const Gitignore = ".gitignore"
+
+var (
+	// list the vendor dir in .gitignore, set with installdeps --write-gitignore
+	writeGitignore = false
+	// take the vendor dir out of .gitignore, set with installdeps --commit-vendor
+	commitVendor = false
+)
+
+// How the vendor dir is listed in .gitignore, empty when it's outside
+// of the project.
+func vendorIgnoreEntry() string {
+	dir := filepath.ToSlash(filepath.Clean(VendorDir))
+	if filepath.IsAbs(VendorDir) || dir == ".." || strings.HasPrefix(dir, "../") {
+		return ""
+	}
+	return dir + "/"
+}
+
+// Whether a .gitignore line ignores the vendor dir entry.
+func ignoresEntry(line, entry string) bool {
+	line = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(line), "/"), "/")
+	return line == strings.TrimSuffix(entry, "/")
+}
+
+func readGitignore(dir string) ([]string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, Gitignore))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.SplitAfter(string(content), "\n"), nil
+}
+
+// Append the vendor dir to the .gitignore in dir unless it's already
+// there, creating the file if needed. Reports whether it was changed.
+func WriteGitignore(dir string) (bool, error) {
+	entry := vendorIgnoreEntry()
+	lines, err := readGitignore(dir)
+	if entry == "" || err != nil {
+		return false, err
+	}
+
+	for _, line := range lines {
+		if ignoresEntry(line, entry) {
+			return false, nil
+		}
+	}
+
+	content := strings.Join(lines, "")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += entry + "\n"
+	return true, ioutil.WriteFile(filepath.Join(dir, Gitignore), []byte(content), 0644)
+}
+
+// Remove the lines of the .gitignore in dir ignoring the vendor dir.
+// Reports whether it was changed.
+func RemoveGitignore(dir string) (bool, error) {
+	entry := vendorIgnoreEntry()
+	lines, err := readGitignore(dir)
+	if entry == "" || err != nil {
+		return false, err
+	}
+
+	kept := []string{}
+	for _, line := range lines {
+		if !ignoresEntry(line, entry) {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == len(lines) {
+		return false, nil
+	}
+	return true, ioutil.WriteFile(filepath.Join(dir, Gitignore), []byte(strings.Join(kept, "")), 0644)
+}
+
+func updateGitignore() error {
+	switch {
+	case writeGitignore:
+		changed, err := WriteGitignore(pwd)
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmtcolor(Gray, "    Gitignore: added `%s` to %s\n", vendorIgnoreEntry(), Gitignore)
+		}
+	case commitVendor:
+		changed, err := RemoveGitignore(pwd)
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmtcolor(Gray, "    Gitignore: removed `%s` from %s\n", vendorIgnoreEntry(), Gitignore)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests calling setupEnv move the vendor dir.
+func stubVendorDir(dir string) func() {
+	orig := VendorDir
+	VendorDir = dir
+	return func() { VendorDir = orig }
+}
+
+func gitignoreFixture(content string) string {
+	dir, _ := ioutil.TempDir("", "gopack-gitignore-")
+	if content != "" {
+		ioutil.WriteFile(filepath.Join(dir, Gitignore), []byte(content), 0644)
+	}
+	return dir
+}
+
+func readGitignoreFixture(t *testing.T, dir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dir, Gitignore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestWriteGitignoreIsIdempotent(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	dir := gitignoreFixture("*.test\n/bin")
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 2; i++ {
+		if _, err := WriteGitignore(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := "*.test\n/bin\n.gopack/vendor/\n"
+	if content := readGitignoreFixture(t, dir); content != expected {
+		t.Errorf("Expected .gitignore to be\n%s\nbut was\n%s\n", expected, content)
+	}
+}
+
+func TestWriteGitignoreCreatesTheFile(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	dir := gitignoreFixture("")
+	defer os.RemoveAll(dir)
+
+	if changed, err := WriteGitignore(dir); !changed || err != nil {
+		t.Errorf("Expected .gitignore to be created, got %v %v\n", changed, err)
+	}
+	if content := readGitignoreFixture(t, dir); content != ".gopack/vendor/\n" {
+		t.Errorf("Expected .gitignore to only list the vendor dir but was\n%s\n", content)
+	}
+}
+
+func TestWriteGitignoreUsesCustomVendorDir(t *testing.T) {
+	dir := gitignoreFixture("/third_party/go\n")
+	defer os.RemoveAll(dir)
+
+	defer stubVendorDir("third_party/go")()
+
+	if changed, err := WriteGitignore(dir); changed || err != nil {
+		t.Errorf("Expected /third_party/go to already ignore the vendor dir, got %v %v\n", changed, err)
+	}
+
+	if changed, err := RemoveGitignore(dir); !changed || err != nil {
+		t.Errorf("Expected --commit-vendor to remove the vendor dir, got %v %v\n", changed, err)
+	}
+	if content := readGitignoreFixture(t, dir); content != "" {
+		t.Errorf("Expected .gitignore to be empty but was\n%s\n", content)
+	}
+}
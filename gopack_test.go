@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -30,7 +31,7 @@ func TestUnmanagedImport(t *testing.T) {
 }
 
 func findErrors(dir string, t *testing.T) []*ProjectError {
-	c := NewConfig(dir)
+	c := loadTestConfig(dir)
 	d, err := c.LoadDependencyModel(NewGraph())
 	p, err := AnalyzeSourceTree(dir)
 	if err != nil {
@@ -46,3 +47,41 @@ func PrintErrors(errors []*ProjectError, t *testing.T) {
 		t.Logf("%s\n", e.String())
 	}
 }
+
+func TestUnmanagedImportsAreGroupedByRepository(t *testing.T) {
+	config := setupTestConfig(`
+[deps.governed]
+  import = "github.com/gopack-test/unmanaged-governed"
+  scm = "git"
+  source = "https://github.com/gopack-test/unmanaged-governed.git"
+  branch = "master"
+`)
+	createSourceFixture(pwd, "main.go", `package main
+
+import (
+	"net/http"
+
+	_ "github.com/gopack-test/unmanaged-governed/pkg"
+	_ "github.com/gopack-test/unmanaged-missing/a"
+	_ "github.com/gopack-test/unmanaged-missing/b"
+)
+`)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errors := withoutUnused(deps.Validate(p))
+	if len(errors) != 1 || errors[0].Kind != UnmanagedImport {
+		t.Fatalf("Expected the missing repository to be reported once, got %v\n", errors)
+	}
+	message := errors[0].Message
+	if !strings.HasPrefix(message, "github.com/gopack-test/unmanaged-missing is imported") || !strings.Contains(message, "main.go:7") || !strings.Contains(message, "main.go:8") {
+		t.Errorf("Expected both imports of the missing repository to be listed, got %s\n", message)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The github repo and major version behind a gopkg.in import,
+// gopkg.in/yaml.v2 is github.com/go-yaml/yaml at v2 and
+// gopkg.in/user/pkg.v2 is github.com/user/pkg at v2.
+func gopkgRepo(importPath string) (string, int, bool) {
+	elements := strings.Split(importPath, "/")
+	if len(elements) < 2 || elements[0] != "gopkg.in" {
+		return "", 0, false
+	}
+
+	user := ""
+	m := gopkgVersion.FindStringSubmatch(elements[1])
+	if m == nil && len(elements) > 2 {
+		user = elements[1]
+		m = gopkgVersion.FindStringSubmatch(elements[2])
+	}
+	if m == nil {
+		return "", 0, false
+	}
+	if user == "" {
+		user = "go-" + m[1]
+	}
+	major, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return "github.com/" + user + "/" + m[1], major, true
+}
+
+// A gopkg.in dep without a source is cloned from its github repo, at the
+// highest tag of its major version unless the config asks for a branch,
+// tag or commit. Like gopkg.in itself, it falls back to the branch named
+// after the major version when there's no such tag.
+func (d *Dep) setGopkg() {
+	repo, major, ok := gopkgRepo(d.Import)
+	if !ok || d.Scm != "go" || d.Source != "" || d.Path != "" {
+		return
+	}
+
+	d.Scm = GitTag
+	d.Source = "https://" + repo + ".git"
+	if d.CheckoutFlag == 0 {
+		d.CheckoutFlag = TagFlag
+		d.CheckoutSpec = fmt.Sprintf("~%d", major)
+		d.gopkgBranch = fmt.Sprintf("v%d", major)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGopkgImportsMapToGithub(t *testing.T) {
+	expected := map[string]string{
+		"gopkg.in/yaml.v2":            "github.com/go-yaml/yaml",
+		"gopkg.in/gopack-test/pkg.v3": "github.com/gopack-test/pkg",
+	}
+	for importPath, repo := range expected {
+		if actual, major, ok := gopkgRepo(importPath); !ok || actual != repo || major < 2 {
+			t.Errorf("Expected %s to be %s, got %s v%d\n", importPath, repo, actual, major)
+		}
+	}
+
+	for _, importPath := range []string{"gopkg.in/yaml", "github.com/go-yaml/yaml.v2"} {
+		if _, _, ok := gopkgRepo(importPath); ok {
+			t.Errorf("Expected %s not to be a gopkg.in import\n", importPath)
+		}
+	}
+}
+
+func TestGopkgDepsResolveTheirMajorVersion(t *testing.T) {
+	config := setupTestConfig(`
+[deps.yaml]
+  import = "gopkg.in/yaml.v2"
+
+[deps.pkg]
+  import = "gopkg.in/gopack-test/pkg.v2"
+
+[deps.pinned]
+  import = "gopkg.in/gopack-test/pinned.v2"
+  branch = "master"
+`)
+	defer stubTags("v1.9.0", "v2.3.0", "v2.4.1", "v3.0.0")()
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deps.resolveTagRanges(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][3]string{
+		"gopkg.in/yaml.v2":               {"https://github.com/go-yaml/yaml.git", "tag ~2", "v2.4.1"},
+		"gopkg.in/gopack-test/pkg.v2":    {"https://github.com/gopack-test/pkg.git", "tag ~2", "v2.4.1"},
+		"gopkg.in/gopack-test/pinned.v2": {"https://github.com/gopack-test/pinned.git", "branch master", ""},
+	}
+	for _, dep := range deps.DepList {
+		actual := [3]string{dep.Source, dep.Checkout(), dep.Tag}
+		if dep.Scm != GitTag || actual != expected[dep.Import] {
+			t.Errorf("Expected %s to be cloned with git from %v, got %s %v\n", dep.Import, expected[dep.Import], dep.Scm, actual)
+		}
+	}
+}
+
+func TestGopkgFallsBackToMajorBranch(t *testing.T) {
+	config := setupTestConfig(`
+[deps.branched]
+  import = "gopkg.in/gopack-test/branched.v2"
+`)
+	defer stubTags("v1.0.0")()
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deps.resolveTagRanges(nil); err != nil {
+		t.Fatal(err)
+	}
+	if checkout := deps.DepList[0].Checkout(); checkout != "branch v2" {
+		t.Errorf("Expected the v2 branch without a v2 tag, got %s\n", checkout)
+	}
+}
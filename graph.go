@@ -2,12 +2,22 @@ package main
 
 import (
 	"container/list"
+	"fmt"
+	"sort"
 	"strings"
 )
 
 type Graph struct {
 	Nodes map[string]*Node
 	Leafs *list.List
+	// the deps each dep declares in its own gopack.config
+	Edges map[string][]string
+	// every dep inserted at an import path, the last one wins the node
+	Requested map[string][]*Dep
+	// the deps whose configs asked for an import path, by import path
+	parents map[string][]string
+	// the import paths asked for by the project's own config
+	roots map[string]bool
 }
 
 type Node struct {
@@ -19,33 +29,175 @@ type Node struct {
 
 func NewGraph() *Graph {
 	return &Graph{
-		Nodes: make(map[string]*Node),
-		Leafs: list.New()}
+		Nodes:     make(map[string]*Node),
+		Leafs:     list.New(),
+		Edges:     make(map[string][]string),
+		Requested: make(map[string][]*Dep),
+		parents:   make(map[string][]string),
+		roots:     make(map[string]bool)}
 }
 
-func (graph *Graph) Insert(dependency *Dep) {
+func (graph *Graph) AddEdge(parent, child string) {
+	for _, c := range graph.Edges[parent] {
+		if c == child {
+			return
+		}
+	}
+	graph.Edges[parent] = append(graph.Edges[parent], child)
+}
+
+// Every distinct path from one of the roots down to target, at most max
+// of them. Paths are found in import order so the result is stable.
+func (graph *Graph) Paths(roots []string, target string, max int) [][]string {
+	paths := [][]string{}
+
+	var visit func(path []string)
+	visit = func(path []string) {
+		if len(paths) >= max {
+			return
+		}
+		last := path[len(path)-1]
+		if last == target {
+			paths = append(paths, append([]string{}, path...))
+			return
+		}
+
+		children := append([]string{}, graph.Edges[last]...)
+		sort.Strings(children)
+		for _, child := range children {
+			if !containsString(path, child) {
+				visit(append(path, child))
+			}
+		}
+	}
+
+	sorted := append([]string{}, roots...)
+	sort.Strings(sorted)
+	for _, root := range sorted {
+		visit([]string{root})
+	}
+	return paths
+}
+
+// The longest chain from one of the roots down to a leaf, the first in
+// import order when several are as long. Dependency cycles have no
+// longest chain so they are reported instead.
+func (graph *Graph) LongestPath(roots []string) ([]string, error) {
+	longest := make(map[string][]string)
+	visiting := []string{}
+
+	var visit func(importPath string) error
+	visit = func(importPath string) error {
+		if _, done := longest[importPath]; done {
+			return nil
+		}
+		for i, v := range visiting {
+			if v == importPath {
+				cycle := append(append([]string{}, visiting[i:]...), importPath)
+				return fmt.Errorf("Dependency cycle %s", strings.Join(cycle, " -> "))
+			}
+		}
+		visiting = append(visiting, importPath)
+
+		children := append([]string{}, graph.Edges[importPath]...)
+		sort.Strings(children)
+		deepest := []string{}
+		for _, child := range children {
+			if err := visit(child); err != nil {
+				return err
+			}
+			if len(longest[child]) > len(deepest) {
+				deepest = longest[child]
+			}
+		}
+
+		visiting = visiting[:len(visiting)-1]
+		longest[importPath] = append([]string{importPath}, deepest...)
+		return nil
+	}
+
+	sorted := append([]string{}, roots...)
+	sort.Strings(sorted)
+	path := []string{}
+	for _, root := range sorted {
+		if err := visit(root); err != nil {
+			return nil, err
+		}
+		if len(longest[root]) > len(path) {
+			path = longest[root]
+		}
+	}
+	return path, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Insert a dep, asked for by the deps given as parents or by the project
+// itself without one.
+func (graph *Graph) Insert(dependency *Dep, parents ...string) {
+	graph.Requested[dependency.Import] = append(graph.Requested[dependency.Import], dependency)
+	graph.addParents(dependency.Import, parents)
 	keys := strings.Split(dependency.Import, "/")
 	graph.Nodes[keys[0]] = graph.deepInsert(graph.Nodes, keys, dependency)
 }
 
+func (graph *Graph) addParents(importPath string, parents []string) {
+	root := true
+	for _, parent := range parents {
+		if parent == "" {
+			continue
+		}
+		root = false
+		if !containsString(graph.parents[importPath], parent) {
+			graph.parents[importPath] = append(graph.parents[importPath], parent)
+		}
+	}
+	if root {
+		graph.roots[importPath] = true
+	}
+}
+
+// The deps that asked for importPath in their own configs, sorted.
+func (graph *Graph) ParentsOf(importPath string) []string {
+	parents := append([]string{}, graph.parents[importPath]...)
+	sort.Strings(parents)
+	return parents
+}
+
+// Whether the project's own config asks for importPath, whoever else
+// does too.
+func (graph *Graph) IsRoot(importPath string) bool {
+	return graph.roots[importPath]
+}
+
+// The dep governing importPath, the deepest one when a dep is vendored
+// inside another, so sub-packages resolve to the repository they're in.
 func (graph *Graph) Search(importPath string) *Node {
 	keys := strings.Split(importPath, "/")
 
+	var nearest *Node
 	nodes := graph.Nodes
 	for _, key := range keys {
 		node := nodes[key]
 		if node == nil {
-			return nil
+			break
 		}
 
 		if node.Leaf {
-			return node
+			nearest = node
 		}
 
 		nodes = node.Nodes
 	}
 
-	return nil
+	return nearest
 }
 
 func (graph *Graph) deepInsert(nodes map[string]*Node, keys []string, dependency *Dep) *Node {
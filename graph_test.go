@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 )
@@ -129,6 +130,30 @@ func TestSearchWorksWithExtendedNames(t *testing.T) {
 	}
 }
 
+func TestSearchFindsNearestEnclosingDep(t *testing.T) {
+	graph := NewGraph()
+	repo := &Dep{Import: "github.com/gopack-test/search"}
+	nested := &Dep{Import: "github.com/gopack-test/search/v2"}
+	graph.Insert(repo)
+	graph.Insert(nested)
+
+	for importPath, expected := range map[string]*Dep{
+		"github.com/gopack-test/search":               repo,
+		"github.com/gopack-test/search/subpkg/deeper": repo,
+		"github.com/gopack-test/search/v2":            nested,
+		"github.com/gopack-test/search/v2/subpkg":     nested,
+		"github.com/gopack-test":                      nil,
+		"github.com/gopack-test/unknown/subpkg":       nil,
+	} {
+		node := graph.Search(importPath)
+		if expected == nil && node != nil {
+			t.Errorf("Expected %s not to be found but found %s\n", importPath, node.Dependency.Import)
+		} else if expected != nil && (node == nil || node.Dependency != expected) {
+			t.Errorf("Expected %s to resolve to %s but found %v\n", importPath, expected.Import, node)
+		}
+	}
+}
+
 func TestInsertingLeafs(t *testing.T) {
 	graph := NewGraph()
 	dep := &Dep{Import: "github.com/d2fn/gopack"}
@@ -142,3 +167,164 @@ func TestInsertingLeafs(t *testing.T) {
 		t.Fatal("Expected to have github.com/d2fn/gopack in the list of leafs")
 	}
 }
+
+// a -> target, b -> c -> target and b -> target
+func createPathsGraph() *Graph {
+	graph := NewGraph()
+	for _, importPath := range []string{"github.com/a", "github.com/b", "github.com/c", "github.com/target"} {
+		graph.Insert(&Dep{Import: importPath})
+	}
+	graph.AddEdge("github.com/a", "github.com/target")
+	graph.AddEdge("github.com/b", "github.com/c")
+	graph.AddEdge("github.com/b", "github.com/target")
+	graph.AddEdge("github.com/c", "github.com/target")
+	return graph
+}
+
+func TestPathsListsEveryPath(t *testing.T) {
+	paths := createPathsGraph().Paths([]string{"github.com/b", "github.com/a"}, "github.com/target", 10)
+
+	expected := []string{
+		"github.com/a github.com/target",
+		"github.com/b github.com/c github.com/target",
+		"github.com/b github.com/target",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected %d paths, found %v\n", len(expected), paths)
+	}
+	for i, path := range paths {
+		if strings.Join(path, " ") != expected[i] {
+			t.Errorf("Expected path %s, found %s\n", expected[i], strings.Join(path, " "))
+		}
+	}
+}
+
+func TestPathsUpToMax(t *testing.T) {
+	paths := createPathsGraph().Paths([]string{"github.com/a", "github.com/b"}, "github.com/target", 2)
+	if len(paths) != 2 {
+		t.Errorf("Expected the paths to be capped at 2, found %v\n", paths)
+	}
+}
+
+func TestPrintPathsNotesMorePaths(t *testing.T) {
+	deps := &Dependencies{Imports: []string{"github.com/a", "github.com/b"}, ImportGraph: createPathsGraph()}
+
+	var out bytes.Buffer
+	if err := deps.PrintPaths(&out, "github.com/target/subpkg", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "github.com/a -> github.com/target\n" +
+		"github.com/b -> github.com/c -> github.com/target\n" +
+		"... more than 2 paths, raise --max-paths to see them\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\nbut printed\n%s\n", expected, out.String())
+	}
+}
+
+func TestWhyStartsAtTheProject(t *testing.T) {
+	deps := &Dependencies{Imports: []string{"github.com/a", "github.com/b"}, ImportGraph: createPathsGraph()}
+
+	var out bytes.Buffer
+	if err := deps.PrintWhy(&out, "github.com/me/app", "github.com/target", 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "github.com/me/app -> github.com/a -> github.com/target\n") {
+		t.Errorf("Expected the chain to start at the project, printed\n%s\n", out.String())
+	}
+
+	if err := deps.PrintWhy(&out, "github.com/me/app", "github.com/unknown", 1); err == nil {
+		t.Errorf("Expected an import outside the graph to be reported\n")
+	}
+}
+
+// a -> b -> d -> e, a -> c -> e and f -> e
+func createBranchingGraph() *Graph {
+	graph := NewGraph()
+	graph.AddEdge("github.com/a", "github.com/c")
+	graph.AddEdge("github.com/a", "github.com/b")
+	graph.AddEdge("github.com/b", "github.com/d")
+	graph.AddEdge("github.com/c", "github.com/e")
+	graph.AddEdge("github.com/d", "github.com/e")
+	graph.AddEdge("github.com/f", "github.com/e")
+	return graph
+}
+
+func TestLongestPathFollowsTheDeepestBranch(t *testing.T) {
+	path, err := createBranchingGraph().LongestPath([]string{"github.com/f", "github.com/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "github.com/a github.com/b github.com/d github.com/e"
+	if strings.Join(path, " ") != expected {
+		t.Errorf("Expected the longest path %s, found %s\n", expected, strings.Join(path, " "))
+	}
+}
+
+func TestLongestPathReportsCycles(t *testing.T) {
+	graph := createBranchingGraph()
+	graph.AddEdge("github.com/e", "github.com/b")
+
+	_, err := graph.LongestPath([]string{"github.com/a"})
+	expected := "Dependency cycle github.com/b -> github.com/d -> github.com/e -> github.com/b"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %s, got %v\n", expected, err)
+	}
+}
+
+func TestPrintLongestPath(t *testing.T) {
+	deps := &Dependencies{Imports: []string{"github.com/a", "github.com/f"}, ImportGraph: createBranchingGraph()}
+
+	var out bytes.Buffer
+	if err := deps.PrintLongestPath(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "github.com/a -> github.com/b -> github.com/d -> github.com/e\n4 deps deep\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\nbut printed\n%s\n", expected, out.String())
+	}
+}
+
+func TestSharedDepRecordsEveryParent(t *testing.T) {
+	graph := NewGraph()
+	shared := NewDependency("github.com/gopack-test/shared")
+	graph.Insert(NewDependency("github.com/gopack-test/a"))
+	graph.Insert(shared, "github.com/gopack-test/b")
+	graph.Insert(shared, "github.com/gopack-test/a")
+	graph.Insert(shared, "github.com/gopack-test/b")
+
+	parents := graph.ParentsOf("github.com/gopack-test/shared")
+	if strings.Join(parents, ",") != "github.com/gopack-test/a,github.com/gopack-test/b" {
+		t.Errorf("Expected both parents once, got %v\n", parents)
+	}
+	if graph.IsRoot("github.com/gopack-test/shared") || !graph.IsRoot("github.com/gopack-test/a") {
+		t.Errorf("Expected only the dep inserted without a parent to be a root\n")
+	}
+	if len(graph.ParentsOf("github.com/gopack-test/a")) != 0 {
+		t.Errorf("Expected a root to have no parents\n")
+	}
+}
+
+func TestTransitiveDepsRecordTheirParent(t *testing.T) {
+	setupTestPwd()
+	graph := NewGraph()
+	for _, name := range []string{"parents-a", "parents-b"} {
+		parent := NewDependency("github.com/gopack-test/" + name)
+		createPath(parent.Src())
+		createFixtureConfig(parent.Src(), `
+[deps.shared]
+  import = "github.com/gopack-test/parents-shared"
+  branch = "master"
+`)
+		if _, err := parent.LoadTransitiveDeps(graph, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parents := graph.ParentsOf("github.com/gopack-test/parents-shared")
+	if len(parents) != 2 || parents[0] != "github.com/gopack-test/parents-a" || parents[1] != "github.com/gopack-test/parents-b" {
+		t.Errorf("Expected the shared dep to record both parents, got %v\n", parents)
+	}
+}
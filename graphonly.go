@@ -0,0 +1,24 @@
+package main
+
+// resolve the graph without vendoring anything, set with --graph-only
+var graphOnly = false
+
+// Find out what the dep resolves to without cloning it. Git remotes are
+// asked what the branch or tag points to, pinned commits are taken as
+// they are and anything else is left unresolved.
+func (d *Dep) resolveRemote() {
+	switch {
+	case d.Locked != "":
+		d.Revision = d.Locked
+	case d.CheckoutFlag == CommitFlag:
+		d.Revision = d.CheckoutSpec
+	case d.Scm == GitTag:
+		d.Revision = remoteRevision(d)
+	}
+
+	if d.Revision != "" {
+		fmtcolor(Gray, "     Resolved: `%s` to %s\n", d.Import, d.Revision)
+	} else {
+		fmtcolor(Gray, "   Unresolved: `%s` can't be resolved without fetching it\n", d.Import)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGraphOnlyResolvesWithoutVendoring(t *testing.T) {
+	config := setupTestConfig(`
+[deps.floating]
+  import = "github.com/gopack-test/graph-only-floating"
+  scm = "git"
+  source = "https://github.com/gopack-test/graph-only-floating.git"
+  branch = "master"
+
+[deps.pinned]
+  import = "github.com/gopack-test/graph-only-pinned"
+  scm = "git"
+  source = "https://github.com/gopack-test/graph-only-pinned.git"
+  commit = "182cae2ee3926a960223d8db4998aa9d57c89788"
+`)
+	graphOnly = true
+	defer func() { graphOnly = false }()
+
+	ran := []string{}
+	defer recordCommands(&ran, commandStubs{
+		"ls-remote": func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte("9fceb02d0ae598e95dc970b74767f19372d61af8\trefs/heads/master\n"), nil
+		},
+	})()
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	for importPath, revision := range map[string]string{
+		"github.com/gopack-test/graph-only-floating": "9fceb02d0ae598e95dc970b74767f19372d61af8",
+		"github.com/gopack-test/graph-only-pinned":   "182cae2ee3926a960223d8db4998aa9d57c89788",
+	} {
+		node := deps.ImportGraph.Search(importPath)
+		if node == nil || node.Dependency.Revision != revision {
+			t.Fatalf("Expected %s to be in the graph at %s\n", importPath, revision)
+		}
+		if _, err := os.Stat(node.Dependency.Src()); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be vendored\n", importPath)
+		}
+	}
+
+	if len(ran) != 1 || !strings.HasPrefix(ran[0], "git ls-remote") {
+		t.Errorf("Expected only the remote to be queried, ran %v\n", ran)
+	}
+}
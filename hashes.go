@@ -0,0 +1,40 @@
+package main
+
+import "os"
+
+// compare what installdeps fetched with the hashes in the lock, set with
+// --verify-hashes
+var verifyHashes = false
+
+// The deps of the graph still at their locked revision must still hash to
+// what the lock recorded, a clone that was tampered with or corrupted
+// doesn't. Deps that moved on are hashed anew by Update, trusted and
+// local ones aren't verified.
+func (l *Lock) VerifyFetched(graph *Graph, repo string) []*ProjectError {
+	errors := []*ProjectError{}
+	for e := graph.Leafs.Front(); e != nil; e = e.Next() {
+		importPath := e.Value.(string)
+		node := graph.Search(importPath)
+		locked := l.Deps[importPath]
+		if importPath == repo || node == nil || node.Dependency == nil || locked == nil || locked.Hash == "" {
+			continue
+		}
+
+		dep := node.Dependency
+		if locked.Trusted || dep.Trusted || dep.Path != "" {
+			continue
+		}
+		if dep.fetch && dep.Revision != locked.Revision {
+			continue
+		}
+		if _, err := os.Stat(dep.Src()); os.IsNotExist(err) {
+			continue
+		}
+
+		hash, err := contentHash(dep.Src())
+		if err != nil || hash != locked.Hash {
+			errors = append(errors, IntegrityMismatchError(importPath))
+		}
+	}
+	return errors
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestVerifyFetchedFindsTamperedClones(t *testing.T) {
+	setupTestPwd()
+	tampered := createVendoredDep("github.com/gopack-test/hashes-tampered", map[string]string{"main.go": "package main\n"})
+	moved := createVendoredDep("github.com/gopack-test/hashes-moved", map[string]string{"main.go": "package main\n"})
+	intact := createVendoredDep("github.com/gopack-test/hashes-intact", map[string]string{"main.go": "package main\n"})
+	for _, dep := range []*Dep{tampered, moved, intact} {
+		dep.Revision = lockedSha
+	}
+	lock := lockDeps(t, tampered, moved, intact)
+
+	check(ioutil.WriteFile(path.Join(tampered.Src(), "main.go"), []byte("package evil\n"), 0644))
+	check(ioutil.WriteFile(path.Join(moved.Src(), "main.go"), []byte("package moved\n"), 0644))
+	moved.Revision = movedSha
+
+	graph := NewGraph()
+	for _, dep := range []*Dep{tampered, moved, intact} {
+		graph.Insert(dep)
+	}
+	errors := lock.VerifyFetched(graph, "")
+	if len(errors) != 1 || errors[0].Kind != IntegrityError || errors[0].Message != IntegrityMismatchError(tampered.Import).Message {
+		t.Errorf("Expected only the tampered dep to mismatch, got %v\n", errors)
+	}
+}
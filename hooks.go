@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// run the validate, generate and postinstall commands of deps, set with
+// --allow-hooks
+var allowHooks = false
+
+func (d *Dep) setValidateCommand(t *toml.TomlTree) {
+	if command, ok := t.Get("validate_command").(string); ok {
+		d.ValidateCommand = command
+	}
+}
+
+func (d *Dep) setGenerateCommand(t *toml.TomlTree) {
+	if command, ok := t.Get("generate").(string); ok {
+		d.GenerateCommand = command
+	}
+}
+
+func (d *Dep) setPostInstallCommand(t *toml.TomlTree) {
+	if command, ok := t.Get("postinstall").(string); ok {
+		d.PostInstallCommand = command
+	}
+}
+
+// Records the revision the postinstall command last ran at. It lives in
+// the vendor tree so a clean vendor tree runs every command again.
+func (d *Dep) postInstallStamp() string {
+	return filepath.Join(pwd, VendorDir, ".postinstall", d.Import)
+}
+
+// Run the postinstall command of the dep in its source dir, once per
+// revision.
+func (d *Dep) postInstall() error {
+	if d.PostInstallCommand == "" {
+		return nil
+	}
+	if ran, err := ioutil.ReadFile(d.postInstallStamp()); err == nil && d.Revision != "" && string(ran) == d.Revision {
+		return nil
+	}
+	if !d.hookAllowed("postinstall", d.PostInstallCommand) {
+		return nil
+	}
+
+	fmtcolor(Gray, "  Postinstall: `%s` with %s\n", d.Import, d.PostInstallCommand)
+	cmd := exec.Command("sh", "-c", d.PostInstallCommand)
+	cmd.Dir = d.Src()
+	cmd.Env = fetchEnv()
+	if out, err := execute(cmd); err != nil {
+		return fmt.Errorf("%s - postinstall `%s` failed: %s\n%s", d.Import, d.PostInstallCommand, err, strings.TrimSpace(string(out)))
+	}
+	if err := os.MkdirAll(filepath.Dir(d.postInstallStamp()), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(d.postInstallStamp(), []byte(d.Revision), 0644)
+}
+
+// Hooks run whatever the config says, deps' configs included, so they
+// only run with --allow-hooks.
+func (d *Dep) hookAllowed(hook, command string) bool {
+	if !allowHooks {
+		fmtcolor(Yellow, "      Warning: `%s` has a %s command, pass --allow-hooks to run it: %s\n", d.Import, hook, command)
+	}
+	return allowHooks
+}
+
+// Run the generate command of the dep in its source dir, with GOPATH
+// pointing at the vendor tree so go generate finds the deps.
+func (d *Dep) generate() error {
+	if !d.hookAllowed("generate", d.GenerateCommand) {
+		return nil
+	}
+	fmtcolor(Gray, "   Generating: `%s` with %s\n", d.Import, d.GenerateCommand)
+	cmd := exec.Command("sh", "-c", d.GenerateCommand)
+	cmd.Dir = d.Src()
+	cmd.Env = fetchEnv()
+	if out, err := execute(cmd); err != nil {
+		return fmt.Errorf("%s - generate `%s` failed: %s\n%s", d.Import, d.GenerateCommand, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Copy the vendored source aside so a rejected checkout can be rolled
+// back. A dep that isn't vendored yet has nothing to copy.
+func (d *Dep) snapshot() (string, error) {
+	if _, err := os.Stat(d.Src()); os.IsNotExist(err) {
+		return "", nil
+	}
+
+	dir, err := ioutil.TempDir("", "gopack-snapshot-")
+	if err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	return dir, copyDir(d.Src(), dir)
+}
+
+// Run the validate_command of the dep in its source dir, putting the
+// snapshot back in place when the command rejects the checkout.
+func (d *Dep) validateCheckout(snapshot string) error {
+	if snapshot != "" {
+		defer os.RemoveAll(snapshot)
+	}
+	if !d.hookAllowed("validate", d.ValidateCommand) {
+		return nil
+	}
+
+	cmd := exec.Command("sh", "-c", d.ValidateCommand)
+	cmd.Dir = d.Src()
+	cmd.Env = fetchEnv()
+	out, err := execute(cmd)
+	if err == nil {
+		return nil
+	}
+
+	if rollbackErr := d.rollback(snapshot); rollbackErr != nil {
+		return fmt.Errorf("%s - validate_command failed and rolling back failed: %s", d.Import, rollbackErr)
+	}
+	return fmt.Errorf("%s - validate_command `%s` rejected the checkout, rolled back: %s\n%s", d.Import, d.ValidateCommand, err, strings.TrimSpace(string(out)))
+}
+
+func (d *Dep) rollback(snapshot string) error {
+	if err := os.RemoveAll(d.Src()); err != nil {
+		return err
+	}
+	if snapshot == "" {
+		return nil
+	}
+	return copyDir(snapshot, d.Src())
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestValidateCommandRollsBackRejectedCheckout(t *testing.T) {
+	setupTestPwd()
+	allowHooks = true
+	defer func() { allowHooks = false }()
+
+	dep := gitDep("github.com/gopack-test/validated", "branch", "master")
+	dep.ValidateCommand = "grep -q known-good main.go || { echo marker missing; exit 1; }"
+	createSourceFixture(dep.Src(), "main.go", "package main // known-good\n")
+
+	snapshot, err := dep.snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the upstream state that gets checked out is broken
+	createSourceFixture(dep.Src(), "main.go", "package main\n")
+	createSourceFixture(dep.Src(), "broken.go", "package main\n")
+
+	err = dep.validateCheckout(snapshot)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") || !strings.Contains(err.Error(), "marker missing") {
+		t.Errorf("Expected the rejected checkout to be reported with the command output, got %v\n", err)
+	}
+
+	dat, _ := ioutil.ReadFile(path.Join(dep.Src(), "main.go"))
+	if string(dat) != "package main // known-good\n" {
+		t.Errorf("Expected main.go to be rolled back, was %s\n", dat)
+	}
+	if _, err := os.Stat(path.Join(dep.Src(), "broken.go")); !os.IsNotExist(err) {
+		t.Errorf("Expected files of the rejected checkout to be removed\n")
+	}
+}
+
+func TestValidateCommandRemovesRejectedNewDep(t *testing.T) {
+	setupTestPwd()
+	allowHooks = true
+	defer func() { allowHooks = false }()
+
+	dep := gitDep("github.com/gopack-test/validated-new", "branch", "master")
+	dep.ValidateCommand = "exit 1"
+
+	snapshot, err := dep.snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createSourceFixture(dep.Src(), "main.go", "package main\n")
+
+	if dep.validateCheckout(snapshot) == nil {
+		t.Errorf("Expected the checkout to be rejected\n")
+	}
+	if _, err := os.Stat(dep.Src()); !os.IsNotExist(err) {
+		t.Errorf("Expected a rejected new dep to be removed from the vendor tree\n")
+	}
+}
+
+func TestValidateCommandAcceptsCheckout(t *testing.T) {
+	setupTestPwd()
+	allowHooks = true
+	defer func() { allowHooks = false }()
+
+	dep := gitDep("github.com/gopack-test/validated-ok", "branch", "master")
+	dep.ValidateCommand = "test -f main.go"
+	createSourceFixture(dep.Src(), "main.go", "package main\n")
+
+	snapshot, _ := dep.snapshot()
+	if err := dep.validateCheckout(snapshot); err != nil {
+		t.Errorf("Expected the checkout to be accepted, got %s\n", err)
+	}
+	if _, err := os.Stat(snapshot); !os.IsNotExist(err) {
+		t.Errorf("Expected the snapshot to be cleaned up\n")
+	}
+}
+
+func TestValidateCommandRunsWithTheFetchEnvironment(t *testing.T) {
+	setupTestPwd()
+	allowHooks = true
+	defer func() { allowHooks = false }()
+
+	dep := gitDep("github.com/gopack-test/validated-env", "branch", "master")
+	dep.ValidateCommand = `test "$GIT_TERMINAL_PROMPT" = 0`
+	createSourceFixture(dep.Src(), "main.go", "package main\n")
+
+	snapshot, _ := dep.snapshot()
+	if err := dep.validateCheckout(snapshot); err != nil {
+		t.Errorf("Expected the validate command to run with the fetch environment, got %s\n", err)
+	}
+}
+
+func TestValidateCommandNeedsAllowHooks(t *testing.T) {
+	setupTestPwd()
+
+	dep := gitDep("github.com/gopack-test/validated-hook", "branch", "master")
+	dep.ValidateCommand = "exit 1"
+	createSourceFixture(dep.Src(), "main.go", "package main\n")
+
+	var err error
+	out := captureStdout(func() { err = dep.validateCheckout("") })
+	if err != nil || !strings.Contains(out, "--allow-hooks") {
+		t.Errorf("Expected validate_command to be skipped with a warning, got %v and printed %s\n", err, out)
+	}
+	if _, err := os.Stat(path.Join(dep.Src(), "main.go")); err != nil {
+		t.Errorf("Expected a skipped validate_command to leave the checkout in place\n")
+	}
+}
+
+func TestGenerateRunsOnFetchAndIsHashed(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	generated := 0
+	restore := stubCommands(commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			createSourceFixture(cmd.Args[3], "parser.y", "%token NUM\n")
+			return cloneCheckout(cmd)
+		},
+		// the generate command, run with sh -c
+		"-c": func(cmd *exec.Cmd) ([]byte, error) {
+			generated++
+			return cmd.CombinedOutput()
+		},
+	})
+	defer func() { restore(); allowHooks = false }()
+
+	dep := gitDep("github.com/gopack-test/generated", "commit", "182cae2ee3926a960223d8db4998aa9d57c89788")
+	dep.GenerateCommand = "echo 'package generated' > parser.go"
+	deps := &Dependencies{ImportGraph: NewGraph(), DepList: []*Dep{dep}}
+	deps.ImportGraph.Insert(dep)
+
+	if out := captureStdout(func() { check(dep.generate()) }); generated != 0 || !strings.Contains(out, "--allow-hooks") {
+		t.Errorf("Expected generate not to run without --allow-hooks, ran %d times and printed %s\n", generated, out)
+	}
+
+	allowHooks = true
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	if generated != 1 {
+		t.Fatalf("Expected generate to run once on the initial fetch, ran %d times\n", generated)
+	}
+	dat, _ := ioutil.ReadFile(path.Join(dep.Src(), "parser.go"))
+	if string(dat) != "package generated\n" {
+		t.Errorf("Expected the generated file to be vendored, found %q\n", dat)
+	}
+
+	lock := NewLock()
+	if err := lock.Update(deps.ImportGraph, ""); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path.Join(dep.Src(), "parser.go"))
+	if hash, _ := contentHash(dep.Src()); hash == lock.Deps[dep.Import].Hash {
+		t.Errorf("Expected the generated file to be part of the locked hash\n")
+	}
+
+	// the pinned commit is already vendored, there's nothing to generate
+	dep.fetch = false
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+	if generated != 1 {
+		t.Errorf("Expected generate to only run on fetch, ran %d times\n", generated)
+	}
+}
+
+func TestPostInstallRunsOncePerRevision(t *testing.T) {
+	setupTestPwd()
+	ran := 0
+	defer stubCommands(commandStubs{
+		"-c": func(cmd *exec.Cmd) ([]byte, error) {
+			ran++
+			if strings.HasSuffix(cmd.Args[2], "fail") {
+				return []byte("make: *** [all] Error 2\n"), errors.New("exit status 2")
+			}
+			return nil, nil
+		},
+	})()
+	defer func() { allowHooks = false }()
+
+	dep := gitDep("github.com/gopack-test/postinstall", "branch", "master")
+	dep.PostInstallCommand = "make"
+	dep.Revision = lockedSha
+	createPath(dep.Src())
+
+	if out := captureStdout(func() { check(dep.postInstall()) }); ran != 0 || !strings.Contains(out, "--allow-hooks") {
+		t.Errorf("Expected the hook not to run without --allow-hooks, ran %d times and printed %s\n", ran, out)
+	}
+
+	allowHooks = true
+	check(dep.postInstall())
+	check(dep.postInstall())
+	if ran != 1 {
+		t.Errorf("Expected the hook to run once at the same revision, ran %d times\n", ran)
+	}
+
+	dep.Revision = movedSha
+	check(dep.postInstall())
+	if ran != 2 {
+		t.Errorf("Expected the hook to run again at another revision, ran %d times\n", ran)
+	}
+
+	dep.Revision = lockedSha
+	dep.PostInstallCommand = "make fail"
+	if err := dep.postInstall(); err == nil || !strings.Contains(err.Error(), "Error 2") {
+		t.Errorf("Expected the failure to show the hook's output, got %v\n", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// how many elements the repository root of an import has on the hosts
+	// go get knows, anything else needs an scm and a source
+	repoRootElements = map[string]int{
+		"github.com":        3,
+		"bitbucket.org":     3,
+		"golang.org":        3,
+		"google.golang.org": 2,
+		"gopkg.in":          2,
+	}
+
+	importPathChars = regexp.MustCompile(`^[A-Za-z0-9._~+/-]+$`)
+	// the package and major version of gopkg.in/yaml.v2 or gopkg.in/user/pkg.v1
+	gopkgVersion = regexp.MustCompile(`^(.+)\.v([0-9]+)$`)
+)
+
+// Whether the import is a path gopack can vendor a repository at, the
+// reason it isn't otherwise.
+func (d *Dep) validateImportPath() string {
+	p := d.Import
+	if !importPathChars.MatchString(p) || strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") {
+		return "is not a valid import path"
+	}
+
+	elements := strings.Split(p, "/")
+	for _, e := range elements {
+		if e == "" || e == "." || e == ".." {
+			return "is not a valid import path"
+		}
+	}
+
+	host := elements[0]
+	if !strings.Contains(host, ".") {
+		return "doesn't start with a host, like github.com"
+	}
+
+	min, known := repoRootElements[host]
+	switch {
+	case !known && d.Scm == "go":
+		return "is on a host go get doesn't know, set its scm and source"
+	case !known:
+		return ""
+	case len(elements) < min:
+		return "is not a repository root, " + host + " imports look like " + repoRootExample(host)
+	case host == "gopkg.in" && !gopkgVersion.MatchString(elements[1]) && (len(elements) < 3 || !gopkgVersion.MatchString(elements[2])):
+		return "has no version, " + host + " imports look like " + repoRootExample(host)
+	}
+	return ""
+}
+
+// The repository root of an import on a host go get knows, the import
+// itself on any other since only its scm could tell.
+func repoRootOf(importPath string) string {
+	elements := strings.Split(importPath, "/")
+	min, known := repoRootElements[elements[0]]
+	// gopkg.in/user/pkg.v1 has one more element than gopkg.in/pkg.v1
+	if elements[0] == "gopkg.in" && len(elements) > 1 && !gopkgVersion.MatchString(elements[1]) {
+		min++
+	}
+	if !known || len(elements) <= min {
+		return importPath
+	}
+	return strings.Join(elements[:min], "/")
+}
+
+func repoRootExample(host string) string {
+	switch host {
+	case "gopkg.in":
+		return "gopkg.in/pkg.v1 or gopkg.in/user/pkg.v1"
+	case "golang.org":
+		return "golang.org/x/repo"
+	case "google.golang.org":
+		return "google.golang.org/repo"
+	}
+	return host + "/user/repo"
+}
+
+// Every dep whose import isn't a repository root gopack can resolve, so
+// they are reported together before anything is fetched.
+func (d *Dependencies) ImportPathErrors() []*ProjectError {
+	errors := []*ProjectError{}
+	for _, dep := range d.DepList {
+		if reason := dep.validateImportPath(); reason != "" {
+			errors = append(errors, InvalidImportError(dep.Import, reason))
+		}
+	}
+	return errors
+}
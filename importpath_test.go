@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestImportPathsAreRepositoryRoots(t *testing.T) {
+	valid := []*Dep{
+		{Import: "github.com/gorilla/mux", Scm: "go"},
+		{Import: "github.com/gorilla/mux/subpkg", Scm: "go"},
+		{Import: "gopkg.in/yaml.v2", Scm: "go"},
+		{Import: "gopkg.in/user/pkg.v1", Scm: "go"},
+		{Import: "golang.org/x/net", Scm: "go"},
+		{Import: "google.golang.org/grpc", Scm: "go"},
+		{Import: "git.internal/team/lib", Scm: GitTag, Source: "https://git.internal/team/lib.git"},
+	}
+	for _, dep := range valid {
+		if reason := dep.validateImportPath(); reason != "" {
+			t.Errorf("Expected %s to be valid, it %s\n", dep.Import, reason)
+		}
+	}
+
+	invalid := map[string]string{
+		"github.com/user":        "is not a repository root, github.com imports look like github.com/user/repo",
+		"bitbucket.org/team":     "is not a repository root, bitbucket.org imports look like bitbucket.org/user/repo",
+		"gopkg.in/yaml":          "has no version, gopkg.in imports look like gopkg.in/pkg.v1 or gopkg.in/user/pkg.v1",
+		"github.com//mux":        "is not a valid import path",
+		"github.com/gorilla/":    "is not a valid import path",
+		"https://github.com/a/b": "is not a valid import path",
+		"github.com/a/../b":      "is not a valid import path",
+		"mylib/pkg":              "doesn't start with a host, like github.com",
+		"git.internal/team/lib":  "is on a host go get doesn't know, set its scm and source",
+	}
+	for importPath, expected := range invalid {
+		dep := &Dep{Import: importPath, Scm: "go"}
+		if reason := dep.validateImportPath(); reason != expected {
+			t.Errorf("Expected %s to be reported with `%s`, got `%s`\n", importPath, expected, reason)
+		}
+	}
+}
+
+func TestImportPathErrorsAreReportedTogether(t *testing.T) {
+	deps := &Dependencies{DepList: []*Dep{
+		{Import: "github.com/user", Scm: "go"},
+		{Import: "github.com/gorilla/mux", Scm: "go"},
+		{Import: "gopkg.in/yaml", Scm: "go"},
+	}}
+
+	errors := deps.ImportPathErrors()
+	if len(errors) != 2 {
+		t.Fatalf("Expected both invalid imports to be reported, got %d errors\n", len(errors))
+	}
+	if errors[0].Kind != InvalidImport || errors[0].Message != "github.com/user in gopack.config is not a repository root, github.com imports look like github.com/user/repo\n" {
+		t.Errorf("Expected an invalid import error, got %s\n", errors[0])
+	}
+}
+
+func TestRepoRootOf(t *testing.T) {
+	for importPath, root := range map[string]string{
+		"github.com/gorilla/mux":           "github.com/gorilla/mux",
+		"github.com/gorilla/mux/subpkg":    "github.com/gorilla/mux",
+		"gopkg.in/yaml.v2":                 "gopkg.in/yaml.v2",
+		"gopkg.in/user/pkg.v1/sub":         "gopkg.in/user/pkg.v1",
+		"golang.org/x/net/context":         "golang.org/x/net",
+		"google.golang.org/grpc/codes":     "google.golang.org/grpc",
+		"git.example.com/team/repo/subpkg": "git.example.com/team/repo/subpkg",
+	} {
+		if got := repoRootOf(importPath); got != root {
+			t.Errorf("Expected %s to be in %s, got %s\n", importPath, root, got)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+const GopackIncludes = ".gopack/includes"
+
+// A config file whose deps are merged into the including gopack.config.
+type Include struct {
+	Path        string
+	DepsTree    *toml.TomlTree
+	DevDepsTree *toml.TomlTree
+	// the names of its deps by section, in the order they're listed
+	depOrder map[string][]string
+	// what the include includes itself, relative to it
+	included []string
+}
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// Load the includes in order, each preceded by the ones it includes so it
+// wins over them. loading are the files being included already, a file
+// including one of them is a cycle.
+func loadIncludes(dir string, paths []string, loading []string) ([]*Include, error) {
+	includes := []*Include{}
+	for _, path := range paths {
+		include, err := LoadInclude(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		if containsString(loading, include.Path) {
+			return nil, fmt.Errorf("Error reading include %s: it includes itself", include.Path)
+		}
+
+		nested, err := loadIncludes(include.dir(), include.included, append(loading, include.Path))
+		if err != nil {
+			return nil, err
+		}
+		includes = append(includes, nested...)
+		includes = append(includes, include)
+	}
+	return includes, nil
+}
+
+// Relative includes of a url are resolved against the url.
+func (i *Include) dir() string {
+	if isURL(i.Path) {
+		return i.Path
+	}
+	return filepath.Dir(i.Path)
+}
+
+// Load an include relative to dir, the url of the including file for the
+// includes of a url, or from a url. A url is only
+// downloaded once, --refresh-remote downloads it again.
+func LoadInclude(dir, include string) (*Include, error) {
+	var data []byte
+	var err error
+	switch {
+	case isURL(include):
+		data, err = fetchInclude(include)
+	case isURL(dir):
+		include, err = resolveURL(dir, include)
+		if err == nil {
+			data, err = fetchInclude(include)
+		}
+	default:
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(dir, include)
+		}
+		data, err = ioutil.ReadFile(include)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading include %s: %s", include, err)
+	}
+
+	t, err := loadToml(include, data)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Include{Path: include, depOrder: make(map[string][]string)}
+	if i.DepsTree, i.depOrder["deps"], err = loadDepsSection(t, "deps", data); err != nil {
+		return nil, fmt.Errorf("Error reading include %s: %s", include, err)
+	}
+	if i.DevDepsTree, i.depOrder["dev-deps"], err = loadDepsSection(t, "dev-deps", data); err != nil {
+		return nil, fmt.Errorf("Error reading include %s: %s", include, err)
+	}
+	i.included = includePaths(t)
+	return i, nil
+}
+
+func includePaths(t *toml.TomlTree) []string {
+	paths := []string{}
+	if includes, ok := t.Get("include").([]interface{}); ok {
+		for _, i := range includes {
+			if path, ok := i.(string); ok {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+func fetchInclude(url string) ([]byte, error) {
+	h := sha1.New()
+	h.Write([]byte(url))
+	cached := filepath.Join(pwd, GopackIncludes, hex.EncodeToString(h.Sum(nil)))
+
+	if !refreshRemote {
+		if data, err := ioutil.ReadFile(cached); err == nil {
+			return data, nil
+		}
+	}
+
+	resp, err := httpGet(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	os.MkdirAll(filepath.Dir(cached), 0755)
+	return data, ioutil.WriteFile(cached, data, 0644)
+}
+
+// Merge dep trees, the first has priority over the ones after it. A dep
+// is left out when its key or its import is already taken.
+func mergeDepsTrees(trees ...*toml.TomlTree) *toml.TomlTree {
+	merged, _ := toml.Load("")
+	imports := make(map[string]bool)
+	found := false
+
+	for _, tree := range trees {
+		if tree == nil {
+			continue
+		}
+		found = true
+		for _, k := range tree.Keys() {
+			importPath := ""
+			if depTree, ok := tree.Get(k).(*toml.TomlTree); ok {
+				importPath, _ = depTree.Get("import").(string)
+			}
+			if merged.Get(k) != nil || (importPath != "" && imports[importPath]) {
+				continue
+			}
+			merged.Set(k, tree.Get(k))
+			if importPath != "" {
+				imports[importPath] = true
+			}
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return merged
+}
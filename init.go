@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// overwrite an existing gopack.config, set with init --force
+var initForce = false
+
+// git@github.com:org/repo.git, https://github.com/org/repo.git and
+// ssh://git@github.com/org/repo all live at github.com/org/repo.
+var remotePattern = regexp.MustCompile(`^(?:[a-z+]+://)?(?:[^@/]+@)?([^/:]+\.[^/:]+)(?::\d+)?[:/](.+?)(?:\.git)?/?$`)
+
+func repoFromRemote(remote string) string {
+	m := remotePattern.FindStringSubmatch(strings.TrimSpace(remote))
+	if m == nil {
+		return ""
+	}
+	return m[1] + "/" + m[2]
+}
+
+// The repo of the project in dir, from the url of its origin remote or,
+// in a terminal, from what the user types.
+func detectRepo(dir string, w io.Writer) string {
+	if remote, err := scmOutput(dir, "git", "config", "--get", "remote.origin.url"); err == nil {
+		if repo := repoFromRemote(remote); repo != "" {
+			return repo
+		}
+	}
+	if !isTerminal() {
+		return ""
+	}
+	fmt.Fprint(w, "Repository import path, github.com/org/repo for instance: ")
+	answer, _ := bufio.NewReader(confirmInput).ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// Write a gopack.config to start from in dir, with the repo given or
+// detected, and create the .gopack dir.
+func initProject(dir string, args []string, w io.Writer) error {
+	path := rootConfig(dir)
+	if _, err := os.Stat(path); err == nil && !initForce {
+		return fmt.Errorf("%s already exists, pass --force to overwrite it", path)
+	}
+
+	repo := ""
+	if len(args) > 0 {
+		repo = args[0]
+	} else {
+		repo = detectRepo(dir, w)
+	}
+
+	config := "[deps]\n\n[dev-deps]\n"
+	if repo != "" {
+		config = fmt.Sprintf("repo = %q\n\n", repo) + config
+	} else {
+		fcolor(w, Yellow, "      Warning: can't tell the repository, add a repo key to %s\n", path)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, GopackDir), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		return err
+	}
+	fcolor(w, Green, "  Initialized: %s\n", path)
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Marks the project's copy in the vendor tree where it couldn't be linked.
+const ProjectCopyMarker = ".gopack-copy"
+
+var (
+	symlink = os.Symlink
+	// whether a failed symlink should be replaced by a copy, only ever
+	// on windows where unprivileged users can't create symlinks
+	copyInsteadOfLink = symlinkNotPermitted
+)
+
+// Link the project into the vendor tree at repo, or copy it there when
+// the platform won't let us link. The copy is made again on every run so
+// it picks up changes to the project.
+func linkProject(project, repo string) error {
+	if isProjectCopy(repo) {
+		if err := os.RemoveAll(repo); err != nil {
+			return err
+		}
+	}
+
+	err := symlink(project, repo)
+	if err == nil || os.IsExist(err) {
+		return nil
+	}
+	if !copyInsteadOfLink(err) {
+		return err
+	}
+
+	fmtcolor(Yellow, "      Warning: can't link %s into the vendor tree, copying it: %s\n", project, err)
+	skip := func(path string) bool {
+		return path == filepath.Join(project, GopackDir) || path == filepath.Join(project, VendorDir) || isVcsDir(filepath.Base(path))
+	}
+	if err := copyTree(project, repo, skip); err != nil {
+		return fmt.Errorf("Error copying %s into the vendor tree: %s", project, err)
+	}
+	return ioutil.WriteFile(filepath.Join(repo, ProjectCopyMarker), []byte(project+"\n"), 0644)
+}
+
+func isProjectCopy(path string) bool {
+	_, err := os.Stat(filepath.Join(path, ProjectCopyMarker))
+	return err == nil
+}
+
+// The links to and copies of the project below the vendor src dir. The
+// links aren't followed so the project itself is never walked.
+func projectLinks(src string) []string {
+	links := []string{}
+	filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Readlink(path); err == nil && filepath.Clean(target) == filepath.Clean(pwd) {
+				links = append(links, path)
+			}
+		} else if info.IsDir() && isProjectCopy(path) {
+			links = append(links, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return links
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+// Symlinks always work for the user here, a failure is a real error.
+func symlinkNotPermitted(err error) bool {
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Symlinks fail like they do for unprivileged users on windows.
+func stubNoSymlinks() func() {
+	origSymlink, origCopy := symlink, copyInsteadOfLink
+	symlink = func(oldname, newname string) error {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.New("privilege not held")}
+	}
+	copyInsteadOfLink = func(err error) bool { return true }
+	return func() { symlink, copyInsteadOfLink = origSymlink, origCopy }
+}
+
+func TestProjectIsCopiedWhenItCantBeLinked(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	defer stubNoSymlinks()()
+	setupTestPwd()
+	createSourceFixture(pwd, "main.go", "package main\n")
+	createSourceFixture(filepath.Join(pwd, ".git"), "HEAD", "ref: refs/heads/master\n")
+
+	config := &Config{Repository: "github.com/gopack-test/copied"}
+	check(config.InitRepo(NewGraph()))
+
+	repo := filepath.Join(pwd, VendorDir, "src", "github.com/gopack-test/copied")
+	if _, err := os.Stat(filepath.Join(repo, "main.go")); err != nil {
+		t.Errorf("Expected the project to be copied into the vendor tree, got %v\n", err)
+	}
+	for _, skipped := range []string{".git", GopackDir} {
+		if _, err := os.Stat(filepath.Join(repo, skipped)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be copied\n", skipped)
+		}
+	}
+
+	// the next run copies the changed project again
+	createSourceFixture(pwd, "added.go", "package main\n")
+	check(config.InitRepo(NewGraph()))
+	if _, err := os.Stat(filepath.Join(repo, "added.go")); err != nil {
+		t.Errorf("Expected the copy to be refreshed, got %v\n", err)
+	}
+}
+
+func TestProjectAlreadyLinked(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	setupTestPwd()
+
+	config := &Config{Repository: "github.com/gopack-test/relinked"}
+	check(config.InitRepo(NewGraph()))
+	if err := config.InitRepo(NewGraph()); err != nil {
+		t.Errorf("Expected the existing link to be kept, got %v\n", err)
+	}
+}
+
+func TestSymlinkErrorsFailOutsideWindows(t *testing.T) {
+	if symlinkNotPermitted(&os.LinkError{Op: "symlink", Err: os.ErrPermission}) != (os.PathSeparator == '\\') {
+		t.Errorf("Expected only windows to copy instead of linking\n")
+	}
+}
+
+func TestCleanRemovesProjectCopy(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	defer stubNoSymlinks()()
+	setupTestPwd()
+	createSourceFixture(pwd, "main.go", "package main\n")
+
+	repo := filepath.Join(pwd, VendorDir, "src", "github.com/gopack-test/cleaned")
+	os.MkdirAll(filepath.Dir(repo), 0755)
+	if err := linkProject(pwd, repo); err != nil {
+		t.Fatal(err)
+	}
+
+	out := captureStdout(func() {
+		if err := clean(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if !strings.Contains(out, repo+", the copy of the project") {
+		t.Errorf("Expected clean to report the copy, printed %s\n", out)
+	}
+	if _, err := os.Stat(filepath.Join(pwd, "main.go")); err != nil {
+		t.Errorf("Expected the project to be kept, got %v\n", err)
+	}
+}
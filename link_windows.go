@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// ERROR_PRIVILEGE_NOT_HELD, what creating a symlink fails with when
+// developer mode is off and the user isn't an administrator.
+const errPrivilegeNotHeld = syscall.Errno(1314)
+
+func symlinkNotPermitted(err error) bool {
+	if linkErr, ok := err.(*os.LinkError); ok && linkErr.Err == errPrivilegeNotHeld {
+		return true
+	}
+	return os.IsPermission(err)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pelletier/go-toml"
+)
+
+// path is a local checkout vendored in place of the dep, relative to the
+// config that declares it.
+func (d *Dep) setPath(t *toml.TomlTree, configDir string) {
+	if p, ok := t.Get("path").(string); ok && p != "" {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(configDir, p)
+		}
+		d.Path = filepath.Clean(p)
+	}
+}
+
+func (d *Dep) validatePath() error {
+	if d.Path == "" {
+		return nil
+	}
+	if d.Source != "" {
+		return fmt.Errorf("%s - only one of source/path may be specified", d.Import)
+	}
+	if d.CheckoutFlag != 0 {
+		return fmt.Errorf("%s - path is vendored as it is, it can't have a branch, commit or tag", d.Import)
+	}
+	if info, err := os.Stat(d.Path); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s - path %s is not a directory", d.Import, d.Path)
+	}
+	return nil
+}
+
+// Link the local checkout into the vendor tree, or copy it where it can't
+// be linked. A clone vendored before is replaced unless it has local
+// modifications.
+func (d *Dep) linkLocal() error {
+	if target, err := os.Readlink(d.Src()); err == nil && filepath.Clean(target) == d.Path {
+		return nil
+	}
+	if info, err := os.Lstat(d.Src()); err == nil && info.IsDir() && !isProjectCopy(d.Src()) {
+		if err := d.checkClean(); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(d.Src()); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.Src()), 0755); err != nil {
+		return err
+	}
+	fmtcolor(Gray, "       Linked: `%s` to %s\n", d.Import, d.Path)
+	return linkProject(d.Path, d.Src())
+}
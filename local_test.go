@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathIsLinkedInsteadOfCloned(t *testing.T) {
+	config := setupTestConfig(`
+[deps.mylib]
+  import = "github.com/gopack-test/local-mylib"
+  path = "../mylib"
+`)
+	local := filepath.Join(filepath.Dir(pwd), "mylib")
+	createSourceFixture(local, "lib.go", "package mylib\n")
+	defer os.RemoveAll(local)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dep := deps.DepList[0]
+	if dep.Path != local || !dep.fetch {
+		t.Fatalf("Expected the path to be relative to the config and always fetched, got %s\n", dep.Path)
+	}
+
+	calls := 0
+	defer stubLsRemote(&calls)()
+	if err := dep.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(dep.Src()); err != nil || target != local {
+		t.Errorf("Expected %s to link to %s, got %s %v\n", dep.Src(), local, target, err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected nothing to be cloned, ran %d commands\n", calls)
+	}
+
+	// the next run keeps the link
+	if err := dep.Get(); err != nil {
+		t.Errorf("Expected the link to be kept, got %v\n", err)
+	}
+}
+
+func TestPathCantHaveACheckout(t *testing.T) {
+	setupTestPwd()
+	dep := NewDependency("github.com/gopack-test/local-tagged")
+	dep.Path = pwd
+	dep.CheckoutFlag, dep.CheckoutSpec = TagFlag, "v1.0.0"
+	if err := dep.validatePath(); err == nil {
+		t.Errorf("Expected a path with a tag to be rejected\n")
+	}
+
+	dep = NewDependency("github.com/gopack-test/local-missing")
+	dep.Path = filepath.Join(pwd, "missing")
+	if err := dep.validatePath(); err == nil {
+		t.Errorf("Expected a missing path to be rejected\n")
+	}
+}
+
+func TestVerifyFlagsLocalPaths(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	setupTestPwd()
+	dep := NewDependency("github.com/gopack-test/local-verified")
+	dep.Path = pwd
+
+	lock := NewLock()
+	lock.Deps[dep.Import] = &LockedDep{Import: dep.Import, Revision: lockedSha}
+	errors := lock.VerifyCheckouts([]*Dep{dep})
+	if len(errors) != 1 || errors[0].Kind != LocalPath || !strings.Contains(errors[0].Message, pwd) {
+		t.Errorf("Expected only the local path to be flagged, got %v\n", errors)
+	}
+}
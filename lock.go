@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+const GopackLock = ".gopack/gopack.lock"
+
+// The lock records what was actually vendored for every dependency,
+// including transitive ones, so the vendor tree can be verified later.
+type Lock struct {
+	// checksum of the gopack.config the deps were resolved from
+	Checksum string
+	Deps     map[string]*LockedDep
+}
+
+type LockedDep struct {
+	Import string
+	// what the config asked for, "branch master" for instance
+	Checkout string
+	// the revision that was checked out
+	Revision string
+	// the tag a version range resolved to
+	Tag string
+	// content hash of the vendored source, vcs metadata excluded
+	Hash string
+	// patched in place on purpose, so the hash isn't verified
+	Trusted bool
+}
+
+func NewLock() *Lock {
+	return &Lock{Deps: make(map[string]*LockedDep)}
+}
+
+func lockPath() string {
+	return filepath.Join(pwd, GopackLock)
+}
+
+// Read the lock file, a missing lock is an empty lock.
+func ReadLock() (*Lock, error) {
+	return readLockFile(lockPath())
+}
+
+func readLockFile(path string) (*Lock, error) {
+	lock := NewLock()
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return lock, nil
+	}
+
+	t, err := toml.LoadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+
+	if s, ok := t.Get("checksum").(string); ok {
+		lock.Checksum = s
+	}
+
+	if deps, ok := t.Get("deps").(*toml.TomlTree); ok {
+		for _, k := range deps.Keys() {
+			tree, ok := deps.Get(k).(*toml.TomlTree)
+			if !ok {
+				continue
+			}
+			l := &LockedDep{}
+			if s, ok := tree.Get("import").(string); ok {
+				l.Import = s
+			}
+			if s, ok := tree.Get("checkout").(string); ok {
+				l.Checkout = s
+			}
+			if s, ok := tree.Get("revision").(string); ok {
+				l.Revision = s
+			}
+			if s, ok := tree.Get("tag").(string); ok {
+				l.Tag = s
+			}
+			if s, ok := tree.Get("hash").(string); ok {
+				l.Hash = s
+			}
+			if b, ok := tree.Get("trusted").(bool); ok {
+				l.Trusted = b
+			}
+			if l.Import != "" {
+				lock.Deps[l.Import] = l
+			}
+		}
+	}
+	return lock, nil
+}
+
+func (l *Lock) Write() error {
+	return l.writeFile(lockPath())
+}
+
+func (l *Lock) writeFile(path string) error {
+	os.MkdirAll(filepath.Dir(path), 0755)
+	return ioutil.WriteFile(path, l.Bytes(), 0644)
+}
+
+// Entries are [deps.key] tables keyed like gopack.deps lines, sorted by
+// import path so the lock diffs cleanly.
+func (l *Lock) Bytes() []byte {
+	var buf bytes.Buffer
+	if l.Checksum != "" {
+		fmt.Fprintf(&buf, "checksum = %q\n", l.Checksum)
+	}
+	keys, _ := toml.Load("")
+	for i, importPath := range l.Imports() {
+		dep := l.Deps[importPath]
+		if i > 0 || l.Checksum != "" {
+			buf.WriteString("\n")
+		}
+		key := requirementKey(keys, importPath)
+		keys.Set(key, true)
+		fmt.Fprintf(&buf, "[deps.%s]\n", key)
+		fmt.Fprintf(&buf, "import = %q\n", dep.Import)
+		if dep.Checkout != "" {
+			fmt.Fprintf(&buf, "checkout = %q\n", dep.Checkout)
+		}
+		if dep.Revision != "" {
+			fmt.Fprintf(&buf, "revision = %q\n", dep.Revision)
+		}
+		if dep.Tag != "" {
+			fmt.Fprintf(&buf, "tag = %q\n", dep.Tag)
+		}
+		if dep.Hash != "" {
+			fmt.Fprintf(&buf, "hash = %q\n", dep.Hash)
+		}
+		if dep.Trusted {
+			buf.WriteString("trusted = true\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+func (l *Lock) Imports() []string {
+	imports := make([]string, 0, len(l.Deps))
+	for importPath := range l.Deps {
+		imports = append(imports, importPath)
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// Record the current state of every fetched dependency in the graph.
+func (l *Lock) Update(graph *Graph, repo string) error {
+	for e := graph.Leafs.Front(); e != nil; e = e.Next() {
+		importPath := e.Value.(string)
+		if importPath == repo {
+			continue
+		}
+
+		node := graph.Search(importPath)
+		if node == nil || node.Dependency == nil {
+			continue
+		}
+
+		// a failed checkout is wherever HEAD happens to be
+		dep := node.Dependency
+		if _, locked := l.Deps[importPath]; (locked && !dep.fetch) || dep.checkoutFailed {
+			continue
+		}
+		if _, err := os.Stat(dep.Src()); os.IsNotExist(err) {
+			continue
+		}
+
+		hash, err := contentHash(dep.Src())
+		if err != nil {
+			return err
+		}
+		l.Deps[importPath] = &LockedDep{
+			Import:   importPath,
+			Checkout: dep.Checkout(),
+			Revision: dep.Revision,
+			Tag:      dep.Tag,
+			Hash:     hash,
+			Trusted:  dep.Trusted,
+		}
+	}
+	return nil
+}
+
+// The deps in the graph and, below those, the deps their vendored configs
+// ask for. Deps that weren't fetched have their own deps left out of the
+// graph, yet they're still needed.
+func (d *Dependencies) requiredImports() []string {
+	required := []string{}
+	seen := make(map[string]bool)
+	queue := []string{}
+	for e := d.ImportGraph.Leafs.Front(); e != nil; e = e.Next() {
+		queue = append(queue, e.Value.(string))
+	}
+	for len(queue) > 0 {
+		importPath := queue[0]
+		queue = queue[1:]
+		if seen[importPath] {
+			continue
+		}
+		seen[importPath] = true
+		required = append(required, importPath)
+		queue = append(queue, knownChildren(d.ImportGraph, importPath)...)
+	}
+	sort.Strings(required)
+	return required
+}
+
+// Compare the vendored source of every locked dependency with its hash.
+func (l *Lock) Verify() []*ProjectError {
+	errors := []*ProjectError{}
+	for _, importPath := range l.Imports() {
+		dep := l.Deps[importPath]
+		if dep.Trusted {
+			fmtcolor(Gray, "      Trusted: `%s` is not verified\n", importPath)
+			continue
+		}
+		hash, err := contentHash(NewDependency(importPath).Src())
+		if err != nil || hash != dep.Hash {
+			errors = append(errors, IntegrityMismatchError(importPath))
+		}
+	}
+	return errors
+}
+
+// Hash every file below dir in lexical order, skipping vcs metadata so
+// the hash is the same whether or not the metadata has been stripped.
+func contentHash(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if isVcsDir(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "link %s %s\n", filepath.ToSlash(rel), target)
+		case info.Mode().IsRegular():
+			fmt.Fprintf(h, "file %s %d\n", filepath.ToSlash(rel), info.Size())
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isVcsDir(name string) bool {
+	if name == FossilCheckout {
+		return true
+	}
+	for _, hidden := range HiddenDirs {
+		if name == hidden {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func createVendoredDep(project string, files map[string]string) *Dep {
+	dep := createScmDep(HiddenGit, project)
+	for name, content := range files {
+		createSourceFixture(dep.Src(), name, content)
+	}
+	return dep
+}
+
+func lockDeps(t *testing.T, deps ...*Dep) *Lock {
+	graph := NewGraph()
+	for _, dep := range deps {
+		dep.fetch = true
+		graph.Insert(dep)
+	}
+
+	lock := NewLock()
+	if err := lock.Update(graph, ""); err != nil {
+		t.Fatal(err)
+	}
+	return lock
+}
+
+func TestStripVcsRemovesMetadata(t *testing.T) {
+	setupTestPwd()
+
+	dep := createVendoredDep("github.com/gopack-test/strip", map[string]string{"main.go": "package main\n"})
+	if err := dep.StripVcs(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(dep.Src(), HiddenGit)); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed from %s\n", HiddenGit, dep.Src())
+	}
+
+	if _, err := os.Stat(path.Join(dep.Src(), "main.go")); err != nil {
+		t.Errorf("Expected the source to be kept in %s\n", dep.Src())
+	}
+}
+
+func TestContentHashIgnoresVcsMetadata(t *testing.T) {
+	setupTestPwd()
+
+	dep := createVendoredDep("github.com/gopack-test/hash", map[string]string{"main.go": "package main\n"})
+	createSourceFixture(path.Join(dep.Src(), HiddenGit), "HEAD", "ref: refs/heads/master\n")
+
+	before, _ := contentHash(dep.Src())
+	dep.StripVcs()
+	after, _ := contentHash(dep.Src())
+
+	if before != after {
+		t.Errorf("Expected stripping vcs metadata to keep the content hash, %s != %s\n", before, after)
+	}
+}
+
+func TestVerifyStrippedTree(t *testing.T) {
+	setupTestPwd()
+
+	dep := createVendoredDep("github.com/gopack-test/verify", map[string]string{"main.go": "package main\n"})
+	dep.StripVcs()
+
+	lock := lockDeps(t, dep)
+	if errors := lock.Verify(); len(errors) != 0 {
+		t.Errorf("Expected the stripped tree to verify, found %d errors\n", len(errors))
+	}
+
+	createSourceFixture(dep.Src(), "main.go", "package changed\n")
+	errors := lock.Verify()
+	if len(errors) != 1 || errors[0].Kind != IntegrityError {
+		t.Errorf("Expected a modified tree to fail verification, found %d errors\n", len(errors))
+	}
+}
+
+func TestLockRoundTrip(t *testing.T) {
+	setupTestPwd()
+
+	dep := createVendoredDep("github.com/gopack-test/roundtrip", map[string]string{"main.go": "package main\n"})
+	lock := lockDeps(t, dep)
+	if err := lock.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := read.Deps[dep.Import]
+	if locked == nil || locked.Hash != lock.Deps[dep.Import].Hash {
+		t.Errorf("Expected %s to be read back from the lock\n", dep.Import)
+	}
+
+	dat, _ := ioutil.ReadFile(path.Join(pwd, GopackLock))
+	if string(dat) != string(read.Bytes()) {
+		t.Errorf("Expected the lock to be written deterministically\n")
+	}
+	if !strings.Contains(string(dat), "[deps.github_com_gopack-test_roundtrip]\nimport = \"github.com/gopack-test/roundtrip\"\n") {
+		t.Errorf("Expected the deps to be written as tables, wrote\n%s\n", dat)
+	}
+}
+
+func TestVerifySkipsTrustedDeps(t *testing.T) {
+	setupTestPwd()
+
+	trusted := createVendoredDep("github.com/gopack-test/trusted", map[string]string{"main.go": "package main\n"})
+	trusted.Trusted = true
+	checked := createVendoredDep("github.com/gopack-test/checked", map[string]string{"main.go": "package main\n"})
+
+	if err := lockDeps(t, trusted, checked).Write(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := ReadLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createSourceFixture(trusted.Src(), "main.go", "package patched\n")
+	if errors := lock.Verify(); len(errors) != 0 {
+		t.Errorf("Expected the patched trusted dep to not be verified, found %d errors\n", len(errors))
+	}
+
+	createSourceFixture(checked.Src(), "main.go", "package patched\n")
+	errors := lock.Verify()
+	if len(errors) != 1 || errors[0].Kind != IntegrityError {
+		t.Errorf("Expected the other deps to still be verified, found %d errors\n", len(errors))
+	}
+}
+
+func TestWriteLockDropsRemovedDeps(t *testing.T) {
+	setupTestPwd()
+
+	kept := createVendoredDep("github.com/gopack-test/lock-kept", map[string]string{"gopack.config": `
+[deps.child]
+  import = "github.com/gopack-test/lock-child"
+  tag = "v1.0"
+`})
+	kept.CheckoutFlag, kept.CheckoutSpec = TagFlag, "v1.0"
+	previous := NewLock()
+	for _, importPath := range []string{"github.com/gopack-test/lock-kept", "github.com/gopack-test/lock-child", "github.com/gopack-test/lock-removed"} {
+		previous.Deps[importPath] = &LockedDep{Import: importPath, Revision: lockedSha}
+	}
+	check(previous.Write())
+
+	// the config is unchanged, the kept dep isn't fetched so its child
+	// isn't in the graph
+	graph := NewGraph()
+	graph.Insert(kept)
+	deps := &Dependencies{DepList: []*Dep{kept}, ImportGraph: graph}
+	if err := writeLock(&Config{}, deps); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, _ := ReadLock()
+	if lock.Deps["github.com/gopack-test/lock-removed"] != nil {
+		t.Errorf("Expected a dep removed from the config to be taken out of the lock\n")
+	}
+	for _, importPath := range []string{"github.com/gopack-test/lock-kept", "github.com/gopack-test/lock-child"} {
+		if locked := lock.Deps[importPath]; locked == nil || locked.Revision != lockedSha {
+			t.Errorf("Expected %s to stay locked at %s, locked %v\n", importPath, lockedSha, lock.Imports())
+		}
+	}
+}
+
+func TestFailedCheckoutKeepsTheLockedRevision(t *testing.T) {
+	setupTestPwd()
+	defer stubCommands(commandStubs{
+		"checkout": func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte("error: pathspec did not match\n"), errors.New("exit status 1")
+		},
+		"rev-parse": func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte(movedSha + "\n"), nil
+		},
+	})()
+
+	dep := vendoredGitDep("github.com/gopack-test/checkout-failed", "commit", lockedSha)
+	dep.Revision = ""
+	lock := NewLock()
+	lock.Deps[dep.Import] = &LockedDep{Import: dep.Import, Checkout: dep.Checkout(), Revision: lockedSha}
+
+	deps := &Dependencies{DepList: []*Dep{dep}, ImportGraph: NewGraph()}
+	deps.ImportGraph.Insert(dep)
+	captureStdout(func() { updateDependency(deps, dep) })
+
+	if dep.Revision == movedSha {
+		t.Errorf("Expected the revision of a failed checkout not to be read from HEAD\n")
+	}
+	if err := lock.Update(deps.ImportGraph, ""); err != nil {
+		t.Fatal(err)
+	}
+	if locked := lock.Deps[dep.Import]; locked.Revision != lockedSha {
+		t.Errorf("Expected a failed checkout to keep the locked revision, locked %s\n", locked.Revision)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 )
 
 const (
@@ -13,6 +16,7 @@ const (
 	Green    = uint8(92)
 	Red      = uint8(31)
 	Gray     = uint8(90)
+	Yellow   = uint8(93)
 	EndColor = "\033[0m"
 )
 
@@ -20,12 +24,51 @@ const (
 	GopackVersion  = "DEV"
 	GopackDir      = ".gopack"
 	GopackChecksum = ".gopack/checksum"
+
+	DefaultMaxPaths = 10
 )
 
 var (
 	pwd        string
 	VendorDir  = ".gopack/vendor"
 	showColors = false
+	outputMu   sync.Mutex
+
+	// features enabled with --enable on top of the config
+	enabledFeatures = listFlag{}
+
+	// gopack's own commands, anything else is handed over to go
+	actions = map[string]bool{
+		"dependencytree": true,
+		"stats":          true,
+		"installdeps":    true,
+		"verify":         true,
+		"graph":          true,
+		"deps-diff":      true,
+		"serve":          true,
+		"clone":          true,
+		"lock":           true,
+		"show":           true,
+		"update":         true,
+		"clean":          true,
+		"prune":          true,
+		"outdated":       true,
+		"init":           true,
+		"why":            true,
+		"unused":         true,
+	}
+
+	// only print the deps declared in the config, set with graph --roots
+	graphRoots = false
+
+	// how many paths graph lists to a target, set with --max-paths
+	graphMaxPaths = DefaultMaxPaths
+
+	// the format graph --export writes
+	graphExport = ""
+
+	// print the deepest chain of deps, set with graph --longest-path
+	graphLongestPath = false
 )
 
 func main() {
@@ -38,67 +81,566 @@ func main() {
 		os.Exit(0)
 	}
 
+	action := ""
+	if len(os.Args) > 1 {
+		action = os.Args[1]
+	}
+
+	args := []string{}
+	if actions[action] {
+		args = parseFlags(action, os.Args[2:])
+	}
+	if err := setVerbosity(); err != nil {
+		fail(err)
+	}
+
+	// before the vendor dir the config may set is looked up
+	if err := setConfigFile(); err != nil {
+		fail(err)
+	}
+
 	// localize GOPATH
 	setupEnv()
+	cacheDir = defaultCacheDir()
+
+	if printEnv {
+		PrintFetchEnv(os.Stdout)
+	}
+
+	// go only needs the GOPATH, the deps are left as they are
+	if passesThrough(action) && !goInstallsFirst() {
+		runGo(os.Args[1:]...)
+		os.Exit(0)
+	}
+
+	// init writes the config every other command needs
+	if action == "init" {
+		if err := initProject(pwd, args, os.Stdout); err != nil {
+			fail(err)
+		}
+		os.Exit(0)
+	}
+
+	// verify is read only so it checks the vendor tree before anything is fetched
+	if action == "verify" {
+		verify()
+		os.Exit(0)
+	}
+
+	// deps-diff compares locks without touching the vendor tree
+	if action == "deps-diff" {
+		depsDiff(args)
+		os.Exit(0)
+	}
+
+	// outdated only asks the remotes, nothing is fetched
+	if action == "outdated" {
+		lock, err := ReadLock()
+		if err != nil {
+			fail(err)
+		}
+		PrintOutdated(os.Stdout, Outdated(configuredDeps(), lock))
+		os.Exit(0)
+	}
+
+	// clone fetches an import whether or not it's in the config
+	if action == "clone" {
+		if err := cloneDep(args); err != nil {
+			failf("%s\n", err)
+		}
+		os.Exit(0)
+	}
+
+	// clean removes the vendor tree without loading the config
+	if action == "clean" {
+		if err := clean(); err != nil {
+			failf("%s\n", err)
+		}
+		os.Exit(0)
+	}
+
+	// update only fetches the deps it's asked to
+	if action == "update" {
+		// version ranges are resolved again too
+		relock = true
+		config, deps, err := loadConfiguration(".")
+		if err != nil {
+			failOn(err)
+		}
+		if deps == nil {
+			fail("Error loading dependency info")
+		}
+		if err := updateDeps(config, deps, args); err != nil {
+			failf("%s\n", err)
+		}
+		os.Exit(0)
+	}
+
+	// lock resolves every dep again to regenerate the lock
+	if action == "lock" {
+		relock = true
+	}
+
+	// unused lists the unused deps once the graph is complete
+	if action == "unused" {
+		listUnused = true
+	}
+
+	if vendorFrom != "" {
+		source, err := OpenVendorSource(vendorFrom)
+		if err != nil {
+			fail(err)
+		}
+		reuseFrom = source
+	}
+
+	if sinceLockPath != "" {
+		lock, err := OpenSinceLock(sinceLockPath)
+		if err != nil {
+			fail(err)
+		}
+		sinceLock = lock
+	}
 
-	p, err := AnalyzeSourceTree(".")
+	if checkpointDeps || resumeDeps {
+		c, err := OpenCheckpoint(resumeDeps)
+		if err != nil {
+			fail(err)
+		}
+		checkpoint = c
+	}
+
+	if showScheduling {
+		scheduling = NewSchedulingReport()
+	}
+
+	if action == "installdeps" && dryRun {
+		// a dry run resolves like --graph-only, then prints a plan
+		graphOnly = true
+	}
+
+	if action == "installdeps" && !graphOnly && verbosity > Quiet {
+		installSummary = NewInstallSummary()
+	}
+
+	stopProfiling, err := startProfiling()
 	if err != nil {
 		fail(err)
 	}
 
-	config, deps := loadDependencies(".", p)
+	var p *ProjectStats
+	if needsStats(action) {
+		if p, err = AnalyzeSourceTree("."); err != nil {
+			fail(err)
+		}
+	}
+
+	config, deps, err := loadDependencies(".", p)
+	stopProfiling()
+	if err != nil {
+		failOn(err)
+	}
 
 	if deps == nil {
 		fail("Error loading dependency info")
 	}
 
-	action := ""
-	if len(os.Args) > 1 {
-		action = os.Args[1]
+	if scheduling != nil {
+		scheduling.Print(os.Stdout)
 	}
 
 	switch action {
 	case "dependencytree":
-		deps.PrintDependencyTree()
+		if treeDot {
+			if err := (DotExporter{}).Export(os.Stdout, deps.GraphJSON()); err != nil {
+				fail(err)
+			}
+		} else if printJSON {
+			if err := deps.WriteDependencyTreeJSON(os.Stdout); err != nil {
+				fail(err)
+			}
+		} else {
+			deps.PrintDependencyTree()
+		}
+		os.Exit(0)
+	case "graph":
+		if graphExport != "" {
+			exporter, ok := Exporters[graphExport]
+			if !ok {
+				failf("Unknown export format `%s`, use one of %s\n", graphExport, ExportFormats())
+			}
+			if err := exporter.Export(os.Stdout, deps.GraphJSON()); err != nil {
+				fail(err)
+			}
+		} else if graphDuplicates {
+			deps.PrintDuplicates(os.Stdout)
+		} else if graphOrphans {
+			deps.PrintOrphans(os.Stdout, p, config.Repository)
+		} else if graphLongestPath {
+			if err := deps.PrintLongestPath(os.Stdout); err != nil {
+				fail(err)
+			}
+		} else if graphRoots {
+			deps.PrintRoots(os.Stdout)
+		} else if len(args) > 0 {
+			if err := deps.PrintPaths(os.Stdout, args[0], graphMaxPaths); err != nil {
+				fail(err)
+			}
+		} else {
+			deps.PrintDependencyTree()
+		}
+		os.Exit(0)
+	case "unused":
+		unused := deps.Unused(p)
+		PrintUnused(os.Stdout, unused)
+		if unusedStrict && len(unused) > 0 {
+			os.Exit(1)
+		}
 		os.Exit(0)
+	case "why":
+		if len(args) == 0 {
+			failf("Usage: gp why <import>\n")
+		}
+		if err := deps.PrintWhy(os.Stdout, config.Repository, args[0], graphMaxPaths); err != nil {
+			fail(err)
+		}
+		os.Exit(0)
+	case "serve":
+		server := NewGraphServer(deps, func() (*Dependencies, error) { return resolveGraph(".") })
+		if err := server.ListenAndServe(serveAddr); err != nil {
+			fail(err)
+		}
 	case "stats":
+		if printJSON {
+			statsFormat = "json"
+		}
+		if statsFormat != "text" && statsFormat != "json" && statsFormat != "csv" {
+			failf("Unknown stats format `%s`, use one of text, json or csv\n", statsFormat)
+		}
+		if statsWatch {
+			p.Watch(".")
+		}
 		p.PrintSummary()
 		os.Exit(0)
+	case "prune":
+		if err := prune(deps); err != nil {
+			fail(err)
+		}
+		os.Exit(0)
+	case "show":
+		if len(args) == 0 {
+			failf("Usage: gp show <import>\n")
+		}
+		if err := showDep(deps, args[0]); err != nil {
+			fail(err)
+		}
+		os.Exit(0)
+	case "lock":
+		fmtcolor(Green, "       Locked: the resolved revisions are in %s\n", GopackLock)
+		os.Exit(0)
 	case "installdeps":
-		deps.Install(config.Repository)
+		if dryRun {
+			fmtcolor(Green, "      Dry run: nothing was fetched, checked out or written\n")
+			os.Exit(0)
+		}
+		if graphOnly {
+			deps.PrintDependencyTree()
+			os.Exit(0)
+		}
+		if audit || auditFail {
+			if config.Advisories == "" {
+				failf("Nothing to audit against, set advisories in gopack.config\n")
+			}
+			affected, err := auditDeps(config)
+			if err != nil {
+				fail(err)
+			}
+			failWith(affected)
+		}
+		if err := updateGitignore(); err != nil {
+			fail(err)
+		}
+		if err := deps.Install(config.Repository); err != nil {
+			fail(err)
+		}
+		installSummary.Print(os.Stdout)
+		if len(offlineDeps) > 0 {
+			PrintOfflineSummary()
+			os.Exit(OfflineFallbackExit)
+		}
 		os.Exit(0)
 	default:
+		if plugin := findPlugin(action); plugin != "" {
+			status, err := runPlugin(plugin, os.Args[2:]...)
+			if err != nil {
+				fail(err)
+			}
+			os.Exit(status)
+		}
 		// fallback to default go command with updated path
 		runGo(os.Args[1:]...)
 	}
 
 }
 
-func loadDependencies(root string, p *ProjectStats) (*Config, *Dependencies) {
-	config, dependencies := loadConfiguration(root)
+func parseFlags(action string, args []string) []string {
+	flags := flag.NewFlagSet(action, flag.ExitOnError)
+	flags.BoolVar(&quietOutput, "quiet", false, "only print errors")
+	flags.BoolVar(&verboseOutput, "verbose", false, "print the scm commands before they run")
+	flags.BoolVar(&unusedStrict, "strict", false, "exit non-zero when gp unused finds unused deps")
+	flags.StringVar(&configFile, "config", "", "the gopack.config to use instead of the one in the project dir")
+	flags.BoolVar(&refreshRemote, "refresh-remote", false, "ignore cached ls-remote results")
+	flags.BoolVar(&printEnv, "print-env", false, "print the environment used to fetch dependencies")
+	flags.Var(&enabledFeatures, "enable", "enable a feature, may be repeated")
+	flags.BoolVar(&graphRoots, "roots", false, "only show the deps declared in gopack.config")
+	flags.StringVar(&vendorFrom, "from", "", "copy matching deps from another gopack project")
+	flags.BoolVar(&followRedirects, "follow-redirects", false, "fetch moved repositories from their new source")
+	flags.BoolVar(&noSelfLink, "no-self-link", false, "don't link the repository into the vendor tree")
+	flags.IntVar(&graphMaxPaths, "max-paths", DefaultMaxPaths, "how many dependency paths to a target to list")
+	flags.StringVar(&sinceLockPath, "since", "", "only fetch the deps that changed since this lock")
+	flags.StringVar(&serveAddr, "addr", serveAddr, "address gp serve listens on")
+	flags.BoolVar(&allowDirty, "allow-dirty", false, "fetch deps even when they have local modifications")
+	flags.StringVar(&graphExport, "export", "", "export the graph as "+ExportFormats())
+	flags.BoolVar(&statsWatch, "watch", false, "print the stats again whenever a file changes")
+	flags.BoolVar(&offlineFallback, "offline-fallback", false, "use the vendored copy of deps that can't be fetched")
+	flags.StringVar(&profileCPU, "profile-cpu", "", "write a cpu profile of resolving and fetching to this file")
+	flags.StringVar(&traceFile, "trace", "", "write an execution trace of resolving and fetching to this file")
+	flags.BoolVar(&graphDuplicates, "duplicates", false, "list the deps vendored at more than one import path")
+	flags.BoolVar(&graphOrphans, "orphan-deps", false, "list the deps neither the code nor other deps use")
+	flags.BoolVar(&graphLongestPath, "longest-path", false, "print the deepest chain of dependencies")
+	flags.BoolVar(&checkpointDeps, "checkpoint", false, "record every installed dep so an interrupted install can be resumed")
+	flags.BoolVar(&resumeDeps, "resume", false, "skip the deps an interrupted --checkpoint install already installed")
+	flags.BoolVar(&envSnapshot, "env-snapshot", false, "record the go, gopack and scm versions and the environment next to the lock")
+	flags.BoolVar(&graphOnly, "graph-only", false, "resolve the dependency graph without vendoring anything")
+	flags.BoolVar(&audit, "audit", false, "check the locked deps against the advisories in gopack.config")
+	flags.BoolVar(&auditFail, "audit-fail", false, "fail when an advisory affects a locked dep")
+	flags.BoolVar(&showScheduling, "show-scheduling", false, "print how long every dep waited for its turn and a host slot, and took to fetch")
+	flags.BoolVar(&assumeYes, "yes", false, "update locked deps without asking")
+	flags.BoolVar(&forceConfirm, "confirm", false, "ask before updating locked deps even when not in a terminal")
+	flags.BoolVar(&writeGitignore, "write-gitignore", false, "list the vendor dir in .gitignore")
+	flags.BoolVar(&commitVendor, "commit-vendor", false, "take the vendor dir out of .gitignore")
+	flags.IntVar(&fetchJobs, "j", runtime.GOMAXPROCS(0), "how many deps to download at once")
+	flags.IntVar(&maxPerHost, "max-parallel-per-host", 0, "how many of the deps downloaded at once may come from the same host")
+	flags.BoolVar(&treeDot, "dot", false, "print gp dependencytree as a graphviz digraph")
+	flags.BoolVar(&printJSON, "json", false, "print gp show, gp dependencytree and gp stats as json")
+	flags.StringVar(&statsFormat, "format", "text", "print gp stats as text, json or csv")
+	flags.BoolVar(&allowConflicts, "allow-conflicts", false, "only warn when deps ask for the same import at different versions")
+	flags.BoolVar(&initForce, "force", false, "let gp init overwrite an existing gopack.config")
+	flags.BoolVar(&dryRun, "dry-run", false, "print what gp installdeps, gp clean and gp prune would do without doing it")
+	flags.BoolVar(&verifyDeep, "deep", false, "also build the project against the vendored deps")
+	flags.BoolVar(&offlineMode, "offline", false, "only use the deps in the cache, never the network")
+	flags.BoolVar(&allowHooks, "allow-hooks", false, "run the validate_command, generate and postinstall commands of deps")
+	flags.BoolVar(&verifyHashes, "verify-hashes", false, "fail installdeps when a dep at its locked revision doesn't match its hash")
+	flags.BoolVar(&verifyVet, "vet", false, "also vet the project with verify --deep")
+	flags.Parse(args)
+	return flags.Args()
+}
+
+// A flag that can be repeated or given a comma separated list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+func loadDependencies(root string, p *ProjectStats) (*Config, *Dependencies, error) {
+	config, dependencies, err := loadConfiguration(root)
+	if err != nil {
+		return nil, nil, err
+	}
 	if dependencies != nil {
-		failWith(dependencies.Validate(p))
+		// without the stats, the imports of the project aren't checked
+		if p != nil {
+			if err := projectErrors(withoutUnused(dependencies.Validate(p))); err != nil {
+				return nil, nil, err
+			}
+		}
+		if relock {
+			for _, dep := range dependencies.DepList {
+				dep.Fetch(true)
+			}
+		}
+		rangeLock = nil
+		if !relock {
+			if rangeLock, err = ReadLock(); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := dependencies.resolveTagRanges(rangeLock); err != nil {
+			return nil, nil, err
+		}
+		lock, err := config.lockToPin()
+		if err != nil {
+			return nil, nil, err
+		}
+		pinnedLock = lock
+		dependencies.pinLocked(pinnedLock)
+		if !graphOnly {
+			confirmed, err := dependencies.confirmUpdates(os.Stdout)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !confirmed {
+				return nil, nil, fmt.Errorf("Aborted, no deps were updated")
+			}
+		}
 		// prepare dependencies
-		loadTransitiveDependencies(dependencies)
-		config.WriteChecksum()
+		if err := loadTransitiveDependencies(dependencies); err != nil {
+			return nil, nil, err
+		}
+		if err := projectErrors(dependencies.cycleErrors()); err != nil {
+			return nil, nil, err
+		}
+		if err := projectErrors(checkConflicts(dependencies.ImportGraph)); err != nil {
+			return nil, nil, err
+		}
+		if p != nil && !listUnused {
+			warnUnused(dependencies.Unused(p))
+		}
+		// nothing was vendored, so there's nothing to lock
+		if graphOnly {
+			return config, dependencies, nil
+		}
+		if verifyHashes {
+			lock, err := ReadLock()
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := projectErrors(lock.VerifyFetched(dependencies.ImportGraph, config.Repository)); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := writeLock(config, dependencies); err != nil {
+			return nil, nil, err
+		}
+		if envSnapshot {
+			if err := TakeEnvSnapshot(dependencies.Scms()).Write(); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := clearCheckpoint(); err != nil {
+			return nil, nil, err
+		}
+		if err := config.WriteChecksum(); err != nil {
+			return nil, nil, err
+		}
 	}
-	return config, dependencies
+	return config, dependencies, nil
 }
 
-func loadConfiguration(dir string) (*Config, *Dependencies) {
+// The lock is written anew so deps no longer required drop out of it,
+// those that weren't fetched keep the revision they were locked at.
+func writeLock(config *Config, dependencies *Dependencies) error {
+	previous, err := ReadLock()
+	if err != nil {
+		return err
+	}
+	lock := NewLock()
+	for _, importPath := range dependencies.requiredImports() {
+		if locked, ok := previous.Deps[importPath]; ok {
+			lock.Deps[importPath] = locked
+		}
+	}
+	if err := lock.Update(dependencies.ImportGraph, config.Repository); err != nil {
+		return err
+	}
+	lock.Checksum = string(config.checksum())
+	return lock.Write()
+}
+
+func loadConfiguration(dir string) (*Config, *Dependencies, error) {
 	importGraph := NewGraph()
-	config := NewConfig(dir)
-	config.InitRepo(importGraph)
+	config, err := NewConfigFile(rootConfig(dir), dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := config.InitRepo(importGraph); err != nil {
+		return nil, nil, err
+	}
+	if err := config.SetupKnownHosts(); err != nil {
+		return nil, nil, err
+	}
 
 	dependencies, err := config.LoadDependencyModel(importGraph)
 	if err != nil {
-		failf(err.Error())
+		return nil, nil, err
+	}
+	if err := projectErrors(dependencies.ImportPathErrors()); err != nil {
+		return nil, nil, err
+	}
+	return config, dependencies, nil
+}
+
+func verify() {
+	lock, err := ReadLock()
+	if err != nil {
+		fail(err)
+	}
+	errors := lock.Verify()
+	errors = append(errors, lock.VerifyCheckouts(configuredDeps())...)
+	failWith(errors)
+	fmtcolor(Green, "     Verified: %d dependencies match %s\n", len(lock.Deps), GopackLock)
+
+	if verifyDeep {
+		failWith(buildVendored(pwd, verifyVet))
+		fmtcolor(Green, "     Verified: the project builds against %s\n", VendorDir)
 	}
-	return config, dependencies
+}
+
+// The deps of the config as written, without resolving or fetching any.
+func configuredDeps() []*Dep {
+	if _, err := os.Stat(rootConfig(pwd)); os.IsNotExist(err) {
+		return nil
+	}
+	config, err := NewConfigFile(rootConfig(pwd), pwd)
+	if err != nil {
+		fail(err)
+	}
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		fail(err)
+	}
+	return deps.DepList
+}
+
+func depsDiff(args []string) {
+	if len(args) == 0 {
+		failf("Usage: gp deps-diff <old gopack.lock>\n")
+	}
+	from, err := readLockFile(args[0])
+	if err != nil {
+		fail(err)
+	}
+	to, err := ReadLock()
+	if err != nil {
+		fail(err)
+	}
+	PrintDepDiff(os.Stdout, DiffLocks(from, to))
+}
+
+// Whether the command is handed over to go rather than run by gopack or
+// a plugin.
+func passesThrough(action string) bool {
+	return !actions[action] && findPlugin(action) == ""
+}
+
+// Whether the deps are installed and the imports checked before handing
+// over to go, set with GOPACK_INSTALLDEPS=1.
+func goInstallsFirst() bool {
+	return os.Getenv("GOPACK_INSTALLDEPS") == "1"
 }
 
 func runGo(args ...string) {
-	cmd := exec.Command("go", args...)
+	cmd := goCommand(args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err := cmd.Run()
@@ -107,29 +649,181 @@ func runGo(args ...string) {
 	}
 }
 
-func loadTransitiveDependencies(dependencies *Dependencies) {
-	dependencies.VisitDeps(
-		func(dep *Dep) {
+func loadTransitiveDependencies(dependencies *Dependencies) error {
+	installSummary.Before(dependencies.DepList)
+	dependencies.prefetch()
+	return dependencies.VisitDeps(
+		func(dep *Dep) error {
+			if dep.fetch && sinceLock != nil && sinceLock.Unchanged(dep) {
+				fmtcolor(Gray, "    Unchanged: `%s` since %s\n", dep.Import, sinceLockPath)
+				dep.fetch = false
+				dep.Revision = sinceLock.Deps[dep.Import].Revision
+				installSummary.Record(dep, false)
+			}
+
 			fmtcolor(Gray, "     Updating: `%s`\n", dep.Import)
-			dep.Get()
 
-			if dep.CheckoutType() != "" {
-				fmtcolor(Gray, "      Updated: `%s` at %s %s\n", dep.Import, dep.CheckoutType(), dep.CheckoutSpec)
-				dep.switchToBranchOrTag()
+			if graphOnly {
+				dep.resolveRemote()
+				if dryRun {
+					dep.printPlan(os.Stdout)
+				}
+			} else if dep.fetch && checkpoint != nil && checkpoint.Done(dep) {
+				fmtcolor(Gray, "      Resumed: `%s` was installed before the interruption\n", dep.Import)
+				dep.Revision = checkpoint.Deps[dep.Import].Revision
+				installSummary.Record(dep, false)
+			} else {
+				var err error
+				scheduling.Time(dep.Import, func() { err = updateDependency(dependencies, dep) })
+				if err != nil {
+					return err
+				}
 			}
 
-			if dep.fetch {
-				transitive, err := dep.LoadTransitiveDeps(dependencies.ImportGraph)
-				if err != nil {
-					failf(err.Error())
+			// without fetching, only the deps of vendored deps are known
+			if dep.fetch || graphOnly {
+				if dep.Transitive != TransitiveNone {
+					transitive, err := dep.LoadTransitiveDeps(dependencies.ImportGraph, dependencies.Excludes)
+					if err != nil {
+						return err
+					}
+					if transitive != nil {
+						for _, child := range transitive.Imports {
+							dependencies.ImportGraph.AddEdge(dep.Import, child)
+						}
+						transitive.StripVcs = dependencies.StripVcs
+						transitive.Ancestors = append(append([]string{}, dependencies.Ancestors...), dep.Import)
+						for _, child := range transitive.DepList {
+							child.Parent = dep.Import
+						}
+						transitive.skipAncestors()
+						transitive.Overrides = dependencies.Overrides
+						// the rules of the root config win over the dep's own
+						transitive.Rewrites = append(append([]*Rewrite{}, dependencies.Rewrites...), transitive.Rewrites...)
+						transitive.applyOverrides()
+						if err := transitive.applyRewrites(); err != nil {
+							return err
+						}
+						if err := transitive.resolveTagRanges(rangeLock); err != nil {
+							return err
+						}
+						transitive.pinLocked(pinnedLock)
+						transitive.limitTransitive(dep)
+						if err := loadTransitiveDependencies(transitive); err != nil {
+							return err
+						}
+					}
+				}
+				if dependencies.StripVcs && !graphOnly {
+					if err := dep.StripVcs(); err != nil {
+						return err
+					}
 				}
-				if transitive != nil {
-					loadTransitiveDependencies(transitive)
+			}
+
+			if dep.fetch && checkpoint != nil && !graphOnly {
+				if err := checkpoint.Record(dep); err != nil {
+					return err
 				}
 			}
+			return nil
 		})
 }
 
+// Fetch a dep and check out the branch, tag or commit it asks for.
+func updateDependency(dependencies *Dependencies, dep *Dep) error {
+	// a local checkout is linked as it is, never checked out
+	if dep.Path != "" {
+		if err := dep.Get(); err != nil {
+			return err
+		}
+		dep.Revision, _ = dep.CurrentRevision()
+		installSummary.Record(dep, false)
+		return nil
+	}
+
+	if dep.fetch {
+		if err := dep.checkClean(); err != nil {
+			return err
+		}
+	}
+
+	snapshot := ""
+	if dep.fetch && dep.ValidateCommand != "" && allowHooks {
+		var err error
+		if snapshot, err = dep.snapshot(); err != nil {
+			return err
+		}
+	}
+
+	if dep.fetch && dependencies.StripVcs {
+		if err := dep.resetStripped(); err != nil {
+			return err
+		}
+	}
+
+	copied := false
+	if dep.fetch && reuseFrom != nil {
+		var err error
+		if copied, err = reuseFrom.Copy(dep); err != nil {
+			return err
+		}
+		if copied {
+			fmtcolor(Gray, "       Copied: `%s` from %s\n", dep.Import, reuseFrom.Dir)
+		}
+	}
+
+	failed := false
+	if !copied {
+		offline := false
+		if err := dep.Get(); err != nil {
+			if offline = dep.fallBackOffline(err); !offline {
+				return err
+			}
+			failed = true
+		}
+
+		if dep.CheckoutType() != "" && !offline {
+			if dep.Locked != "" {
+				fmtcolor(Gray, "       Locked: `%s` at %s %s (%s)\n", dep.Import, dep.CheckoutType(), dep.CheckoutSpec, dep.Locked)
+			} else if dep.Tag != "" {
+				fmtcolor(Gray, "      Updated: `%s` at %s %s (%s)\n", dep.Import, dep.CheckoutType(), dep.CheckoutSpec, dep.Tag)
+			} else {
+				fmtcolor(Gray, "      Updated: `%s` at %s %s\n", dep.Import, dep.CheckoutType(), dep.CheckoutSpec)
+			}
+			if err := dep.switchToBranchOrTag(); err != nil {
+				fmtcolor(Yellow, "      Warning: `%s` couldn't be checked out at %s %s, it keeps its locked revision\n", dep.Import, dep.CheckoutType(), dep.CheckoutSpec)
+				dep.checkoutFailed = true
+				failed = true
+			}
+		}
+
+		if dep.fetch && !dep.checkoutFailed {
+			dep.Revision, _ = dep.CurrentRevision()
+		}
+
+		if dep.fetch && !offline && !dep.checkoutFailed && dep.GenerateCommand != "" {
+			if err := dep.generate(); err != nil {
+				return err
+			}
+		}
+
+		if dep.fetch && !offline && !dep.checkoutFailed {
+			if err := dep.postInstall(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if dep.fetch && dep.ValidateCommand != "" {
+		if err := dep.validateCheckout(snapshot); err != nil {
+			return err
+		}
+	}
+	installSummary.Record(dep, failed)
+	return nil
+}
+
 // Set the working directory.
 // It's the current directory by default.
 // It can be overriden setting the environment variable GOPACK_APP_CONFIG.
@@ -151,39 +845,42 @@ func setPwd() {
 // set GOPATH to the local vendor dir
 func setupEnv() {
 	setPwd()
-
-	if goPath := os.Getenv("GOPATH"); goPath != "" {
-		s := filepath.SplitList(goPath)
-		dir, err := filepath.Rel(pwd, s[0])
-		if err == nil {
-			VendorDir = dir
-			return
-		}
-	}
-
-	err := os.Setenv("GOPATH", filepath.Join(pwd, VendorDir))
-	if err != nil {
+	if err := setupVendorDir(); err != nil {
 		fail(err)
 	}
 }
 
 func fmtcolor(c uint8, s string, args ...interface{}) {
+	if !printed(c) {
+		return
+	}
+	fcolor(os.Stdout, c, s, args...)
+}
+
+func fcolor(w io.Writer, c uint8, s string, args ...interface{}) {
+	// deps fetched concurrently print one line at a time
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
 	if showColors {
-		fmt.Printf("\033[%dm", c)
+		fmt.Fprintf(w, "\033[%dm", c)
 	}
 
 	if len(args) > 0 {
-		fmt.Printf(s, args...)
+		fmt.Fprintf(w, s, args...)
 	} else {
-		fmt.Printf(s)
+		fmt.Fprint(w, s)
 	}
 
 	if showColors {
-		fmt.Printf(EndColor)
+		fmt.Fprint(w, EndColor)
 	}
 }
 
 func logcolor(c uint8, s string, args ...interface{}) {
+	if !printed(c) {
+		return
+	}
 	log.Printf("\033[%dm", c)
 	if len(args) > 0 {
 		log.Printf(s, args...)
@@ -201,7 +898,7 @@ func failf(s string, args ...interface{}) {
 
 func fail(a ...interface{}) {
 	fmt.Printf("\033[%dm", Red)
-	fmt.Print(a)
+	fmt.Print(a...)
 	fmt.Printf(EndColor)
 	fmt.Println("")
 	os.Exit(1)
@@ -218,3 +915,11 @@ func failWith(errors []*ProjectError) {
 		os.Exit(len(errors))
 	}
 }
+
+// Exit with err, project errors exit with how many there are.
+func failOn(err error) {
+	if errors, ok := err.(ProjectErrors); ok {
+		failWith(errors)
+	}
+	fail(err)
+}
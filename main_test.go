@@ -1,8 +1,13 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"path"
+	"strings"
 	"testing"
 )
 
@@ -23,3 +28,180 @@ func TestSetPwdAppConfig(t *testing.T) {
 		t.Errorf("Expected pwd to be %s but it was %s.\n", dir, pwd)
 	}
 }
+
+// Fake clones that vendor a gopack.config declaring the given deps.
+func stubGitWithDeps(cloned *[]string, deps map[string][]string) func() {
+	configs := make(map[string]string)
+	for source, imports := range deps {
+		for i, importPath := range imports {
+			configs[source] += fmt.Sprintf("[deps.dep%d]\nimport = %q\nscm = \"git\"\nsource = \"https://%s.git\"\n", i, importPath, importPath)
+		}
+	}
+	return stubGitWithConfigs(cloned, configs)
+}
+
+// Fake clones that vendor the given gopack.config, and record every
+// other git command.
+func stubGitWithConfigs(ran *[]string, configs map[string]string) func() {
+	return stubCommands(commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			source, dir := cmd.Args[2], cmd.Args[3]
+			*ran = append(*ran, source)
+			createPath(path.Join(dir, HiddenGit))
+			createFixtureConfig(dir, configs[source])
+			return nil, nil
+		},
+		"checkout": func(cmd *exec.Cmd) ([]byte, error) {
+			*ran = append(*ran, strings.Join(cmd.Args[1:], " "))
+			return nil, nil
+		},
+	})
+}
+
+func TestTransitiveDepthPerDep(t *testing.T) {
+	setupTestPwd()
+	defer stubVendorDir(".gopack/vendor")()
+
+	cloned := []string{}
+	defer stubGitWithDeps(&cloned, map[string][]string{
+		"https://github.com/gopack-test/none.git":       {"github.com/gopack-test/none-child"},
+		"https://github.com/gopack-test/one.git":        {"github.com/gopack-test/one-child"},
+		"https://github.com/gopack-test/one-child.git":  {"github.com/gopack-test/one-grandchild"},
+		"https://github.com/gopack-test/full.git":       {"github.com/gopack-test/full-child"},
+		"https://github.com/gopack-test/full-child.git": {"github.com/gopack-test/full-grandchild"},
+	})()
+
+	none := gitDep("github.com/gopack-test/none", "", "")
+	none.Transitive = TransitiveNone
+	one := gitDep("github.com/gopack-test/one", "", "")
+	one.Transitive = 1
+	full := gitDep("github.com/gopack-test/full", "", "")
+
+	graph := NewGraph()
+	deps := &Dependencies{ImportGraph: graph, DepList: []*Dep{none, one, full}}
+	for _, dep := range deps.DepList {
+		graph.Insert(dep)
+	}
+
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"https://github.com/gopack-test/none.git",
+		"https://github.com/gopack-test/one.git",
+		"https://github.com/gopack-test/one-child.git",
+		"https://github.com/gopack-test/full.git",
+		"https://github.com/gopack-test/full-child.git",
+		"https://github.com/gopack-test/full-grandchild.git",
+	}
+	if strings.Join(cloned, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected to clone %v, cloned %v\n", expected, cloned)
+	}
+
+	if edges := graph.Edges["github.com/gopack-test/full"]; len(edges) != 1 || edges[0] != "github.com/gopack-test/full-child" {
+		t.Errorf("Expected an edge from full to full-child, found %v\n", edges)
+	}
+
+	if graph.Search("github.com/gopack-test/none-child") != nil {
+		t.Errorf("Expected the deps of a transitive = \"none\" dep to be left out of the graph\n")
+	}
+}
+
+func TestOverrideTransitiveDep(t *testing.T) {
+	config := setupTestConfig(`
+[deps.app]
+  import = "github.com/gopack-test/override-app"
+  scm = "git"
+  source = "https://github.com/gopack-test/override-app.git"
+
+[override.lib]
+  import = "github.com/gopack-test/override-lib"
+  scm = "git"
+  source = "https://github.com/gopack-test/override-lib.git"
+  tag = "v2.0"
+`)
+
+	ran := []string{}
+	defer stubGitWithConfigs(&ran, map[string]string{
+		"https://github.com/gopack-test/override-app.git": `
+[deps.lib]
+  import = "github.com/gopack-test/override-lib"
+  scm = "git"
+  source = "https://github.com/gopack-test/override-lib.git"
+  tag = "v1.0"
+`,
+	})()
+
+	// checking out moves to the pwd of the test
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	lib := deps.ImportGraph.Search("github.com/gopack-test/override-lib")
+	if lib == nil || lib.Dependency.Checkout() != "tag v2.0" {
+		t.Fatalf("Expected the transitive dep to be overridden to tag v2.0\n")
+	}
+
+	if ran[len(ran)-1] != "checkout v2.0" {
+		t.Errorf("Expected the overridden tag to be checked out, ran %v\n", ran)
+	}
+}
+
+func TestConflictingOverrides(t *testing.T) {
+	config := setupTestConfig(`
+[override.lib]
+  import = "github.com/gopack-test/override-lib"
+  tag = "v2.0"
+
+[override.other]
+  import = "github.com/gopack-test/override-lib"
+  tag = "v3.0"
+`)
+
+	if _, err := config.LoadDependencyModel(NewGraph()); err == nil {
+		t.Errorf("Expected two overrides of the same import to be rejected\n")
+	}
+}
+
+func TestGoCommandsSkipAnalysisUnlessInstallingFirst(t *testing.T) {
+	defer os.Setenv("GOPACK_INSTALLDEPS", os.Getenv("GOPACK_INSTALLDEPS"))
+
+	os.Setenv("GOPACK_INSTALLDEPS", "")
+	if !passesThrough("build") || goInstallsFirst() || needsStats("build") {
+		t.Errorf("Expected gp build to run go straight away\n")
+	}
+	for _, action := range []string{"installdeps", "stats"} {
+		if passesThrough(action) || !needsStats(action) {
+			t.Errorf("Expected %s to analyze the project\n", action)
+		}
+	}
+
+	os.Setenv("GOPACK_INSTALLDEPS", "1")
+	if !goInstallsFirst() || !needsStats("test") {
+		t.Errorf("Expected gp test to install and check the deps first with GOPACK_INSTALLDEPS=1\n")
+	}
+}
+
+func TestFailedFetchIsReturned(t *testing.T) {
+	setupTestPwd()
+	defer stubCommands(commandStubs{"clone": func(cmd *exec.Cmd) ([]byte, error) {
+		return []byte("remote: Repository not found.\nfatal: repository not found"), errors.New("exit status 128")
+	}})()
+
+	dep := gitDep("github.com/gopack-test/missing", "branch", "master")
+	deps := &Dependencies{ImportGraph: NewGraph(), DepList: []*Dep{dep}}
+	deps.ImportGraph.Insert(dep)
+	var err error
+	captureStdout(func() { err = loadTransitiveDependencies(deps) })
+	if err == nil || !strings.Contains(err.Error(), "Error downloading dependency github.com/gopack-test/missing") {
+		t.Errorf("Expected the failed fetch to be returned, got %v\n", err)
+	}
+}
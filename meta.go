@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+var (
+	// use the source a moved repository points to, set with --follow-redirects
+	followRedirects = false
+
+	goImportURL = func(importPath string) string {
+		return "https://" + importPath + "?go-get=1"
+	}
+
+	goImportMeta = regexp.MustCompile(`<meta\s+name=["']go-import["']\s+content=["']([^"']+)["']`)
+)
+
+// A go-import meta tag, https://golang.org/cmd/go/#hdr-Remote_import_paths
+type GoImport struct {
+	Prefix string
+	Vcs    string
+	Repo   string
+}
+
+// Look up where the repository for importPath is hosted now.
+func DiscoverGoImport(importPath string) (*GoImport, error) {
+	resp, err := httpGet(goImportURL(importPath))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range goImportMeta.FindAllStringSubmatch(string(body), -1) {
+		fields := strings.Fields(m[1])
+		if len(fields) == 3 && (importPath == fields[0] || strings.HasPrefix(importPath, fields[0]+"/")) {
+			return &GoImport{fields[0], fields[1], fields[2]}, nil
+		}
+	}
+	return nil, fmt.Errorf("no go-import meta tag for %s", importPath)
+}
+
+// When every source of the dep is gone, find out where it moved to and
+// either suggest the new source or, with --follow-redirects, fetch it.
+func (d *Dep) followGoImport(fetchErr *FetchError) error {
+	meta, err := DiscoverGoImport(d.Import)
+	if err != nil || d.isSource(meta.Repo) {
+		return fetchErr
+	}
+
+	if _, known := Scms[meta.Vcs]; !known {
+		return fetchErr
+	}
+
+	if !followRedirects {
+		return fmt.Errorf("%s\n`%s` moved to %s, update its source in gopack.config or run with --follow-redirects", fetchErr, d.Import, meta.Repo)
+	}
+
+	fmtcolor(Gray, "    Following: `%s` to %s\n", d.Import, meta.Repo)
+	d.Scm = meta.Vcs
+	d.Source = meta.Repo
+	d.Mirrors = nil
+	return Scms[meta.Vcs].Init(d)
+}
+
+func (d *Dep) isSource(repo string) bool {
+	for _, source := range d.Sources() {
+		if strings.TrimSuffix(source, ".git") == strings.TrimSuffix(repo, ".git") {
+			return true
+		}
+	}
+	return false
+}
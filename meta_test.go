@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+const movedSource = "https://github.com/gopack-test/moved.git"
+
+func stubGoImport(repo string) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><head>
+<meta name="go-import" content="example.com/gopack-test/moved git %s">
+</head></html>`, repo)
+	}))
+
+	orig := goImportURL
+	goImportURL = func(importPath string) string { return server.URL + "/" + importPath + "?go-get=1" }
+	return func() {
+		goImportURL = orig
+		server.Close()
+	}
+}
+
+// Fake clones where only the moved repository exists.
+func stubMovedClones(cloned *[]string) func() {
+	origSleep := sleep
+	sleep = func(time.Duration) {}
+	restore := stubCommands(commandStubs{"clone": func(cmd *exec.Cmd) ([]byte, error) {
+		*cloned = append(*cloned, cmd.Args[2])
+		if cmd.Args[2] != movedSource {
+			return []byte("remote: Repository not found.\nfatal: repository not found"), errors.New("exit status 128")
+		}
+		return cloneCheckout(cmd)
+	}})
+	return func() { restore(); sleep = origSleep }
+}
+
+func movedDep() *Dep {
+	return &Dep{Import: "example.com/gopack-test/moved", Scm: GitTag, Source: "https://example.com/gopack-test/old.git", fetch: true}
+}
+
+func TestDiscoverGoImport(t *testing.T) {
+	defer stubGoImport(movedSource)()
+
+	meta, err := DiscoverGoImport("example.com/gopack-test/moved/subpkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Prefix != "example.com/gopack-test/moved" || meta.Vcs != GitTag || meta.Repo != movedSource {
+		t.Errorf("Expected the go-import meta tag to be parsed, was %v\n", meta)
+	}
+}
+
+func TestSuggestMovedSource(t *testing.T) {
+	setupTestPwd()
+	defer stubVendorDir(".gopack/vendor")()
+	defer stubGoImport(movedSource)()
+
+	cloned := []string{}
+	defer stubMovedClones(&cloned)()
+
+	err := movedDep().Get()
+	if err == nil || !strings.Contains(err.Error(), "moved to "+movedSource) {
+		t.Errorf("Expected a suggestion to use %s, got %v\n", movedSource, err)
+	}
+
+	for _, source := range cloned {
+		if source == movedSource {
+			t.Error("Expected the moved source to not be fetched without --follow-redirects")
+		}
+	}
+}
+
+func TestFollowMovedSource(t *testing.T) {
+	setupTestPwd()
+	defer stubVendorDir(".gopack/vendor")()
+	defer stubGoImport(movedSource)()
+
+	cloned := []string{}
+	defer stubMovedClones(&cloned)()
+
+	followRedirects = true
+	defer func() { followRedirects = false }()
+
+	dep := movedDep()
+	if err := dep.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	if dep.Source != movedSource || cloned[len(cloned)-1] != movedSource {
+		t.Errorf("Expected the dep to be fetched from %s, cloned %v\n", movedSource, cloned)
+	}
+}
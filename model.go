@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/pelletier/go-toml"
@@ -19,20 +21,25 @@ const (
 	BranchFlag = 1 << 0
 	CommitFlag = 1 << 1
 	TagFlag    = 1 << 2
+
+	TransitiveFull = -1
+	TransitiveNone = 0
 )
 
 var (
 	Scms = map[string]Scm{
-		GitTag: Git{},
-		HgTag:  Hg{},
-		SvnTag: Svn{},
-		BzrTag: Bzr{}}
+		GitTag:    Git{},
+		HgTag:     Hg{},
+		SvnTag:    Svn{},
+		BzrTag:    Bzr{},
+		FossilTag: Fossil{}}
 
 	HiddenDirs = map[string]string{
-		GitTag: HiddenGit,
-		HgTag:  HiddenHg,
-		SvnTag: HiddenSvn,
-		BzrTag: HiddenBzr}
+		GitTag:    HiddenGit,
+		HgTag:     HiddenHg,
+		SvnTag:    HiddenSvn,
+		BzrTag:    HiddenBzr,
+		FossilTag: HiddenFossil}
 )
 
 type Dependencies struct {
@@ -40,6 +47,16 @@ type Dependencies struct {
 	Keys        []string
 	DepList     []*Dep
 	ImportGraph *Graph
+	// strip the vcs metadata after checkout
+	StripVcs bool
+	// transitive deps pinned by the root config, by import path
+	Overrides map[string]*Dep
+	// source rewriting rules of the root config
+	Rewrites []*Rewrite
+	// the deps whose configs pulled these in, from the root config down
+	Ancestors []string
+	// imports of the root config that are never fetched, with what's below them
+	Excludes []string
 }
 
 type Dep struct {
@@ -51,15 +68,64 @@ type Dep struct {
 
 	// does this dep need to be fetched
 	fetch bool
+	// downloaded ahead of its turn, with what it failed with
+	prefetched  bool
+	prefetchErr error
+	// the checkout failed, HEAD isn't what the config or lock asks for
+	checkoutFailed bool
 
 	// what is the scm for this dep (hg, git, bzr etc)
 	Scm string
+	// fetches the dep instead of the one Scm names, tests set stubs
+	scm Scm
 	// whence the Scm should clone/checkout
 	Source string
+	// a local checkout linked into the vendor tree instead of cloning
+	Path string
+	// cloned from as is instead of source, git@github.com:org/repo.git for
+	// instance, rewrites don't apply to it
+	ScmURL string
+	// fallbacks for Source, tried in order
+	Mirrors []string
+	// only include this dep when the feature is enabled
+	Feature string
+	// declared in dev-deps rather than deps
+	Dev bool
+	// the revision checked out by the last fetch
+	Revision string
+	// how many levels of its own deps to load, TransitiveFull for all of them
+	Transitive int
+	// check out the branch as it was on this date
+	AsOf string
+	// how many commits to clone, the whole history when 0
+	Depth int
+	// update the git submodules of the checkout too
+	Submodules bool
+	// the revision of the lock checked out instead of the branch or tag
+	Locked string
+	// the tag a version range like ^1.4 resolved to
+	Tag string
+	// checked out when no tag matches the major version of a gopkg.in dep
+	gopkgBranch string
+	// rejects the checkout when it exits non-zero
+	ValidateCommand string
+	// runs after every fetch, what it generates is vendored with the source
+	GenerateCommand string
+	// runs after a checkout moved the dep to another revision, with --allow-hooks
+	PostInstallCommand string
+	// skip verifying the content hash of the vendored copy
+	Trusted bool
+	// deps with a higher priority are fetched first
+	Priority int
+	// the dep whose gopack.config declares this one, empty for the project's
+	Parent string
+	// its sources that took a var from the environment, to what they are
+	// as written
+	envSources map[string]string
 }
 
 func NewDependency(repo string) *Dep {
-	return &Dep{Import: repo}
+	return &Dep{Import: repo, Transitive: TransitiveFull}
 }
 
 func (d *Dependencies) IncludesDependency(importPath string) (*Node, bool) {
@@ -68,21 +134,43 @@ func (d *Dependencies) IncludesDependency(importPath string) (*Node, bool) {
 }
 
 func (d *Dep) Fetch(all bool) bool {
-	d.fetch = all || (d.CheckoutFlag != CommitFlag && d.CheckoutFlag != TagFlag)
+	d.fetch = all || d.Path != "" || (d.CheckoutFlag != CommitFlag && d.CheckoutFlag != TagFlag && d.AsOf == "")
 	return d.fetch
 }
 
-func (d *Dep) Get() {
+func (d *Dep) Get() error {
+	if d.prefetched {
+		d.prefetched = false
+		return d.prefetchErr
+	}
+	if d.Path != "" {
+		return d.linkLocal()
+	}
 	if d.fetch {
 		scm, err := NewScm(d)
 		if err != nil {
-			fail(err)
+			return err
 		}
 		err = scm.Init(d)
-		if err != nil {
-			fail(err)
+		if fetchErr, ok := err.(*FetchError); ok && fetchErr.NotFound() {
+			return d.followGoImport(fetchErr)
 		}
+		return err
 	}
+	return nil
+}
+
+// The source followed by its mirrors, in the order they are tried. The
+// order is always the one in the config so the first listed source that
+// works wins on every run, repeated sources are only tried once.
+func (d *Dep) Sources() []string {
+	sources := []string{d.Source}
+	for _, mirror := range d.Mirrors {
+		if !containsString(sources, mirror) {
+			sources = append(sources, mirror)
+		}
+	}
+	return sources
 }
 
 func (d *Dep) setCheckout(t *toml.TomlTree, key string, flag uint8) {
@@ -109,6 +197,76 @@ func (d *Dep) setSource(t *toml.TomlTree) {
 	} else {
 		d.Source = ""
 	}
+	if scmURL, ok := t.Get("scm_url").(string); ok {
+		d.ScmURL = scmURL
+		if source == nil {
+			d.Source = scmURL
+		}
+	}
+}
+
+func (d *Dep) setMirrors(t *toml.TomlTree) {
+	if mirrors, ok := t.Get("mirrors").([]interface{}); ok {
+		for _, m := range mirrors {
+			if mirror, ok := m.(string); ok {
+				d.Mirrors = append(d.Mirrors, mirror)
+			}
+		}
+	}
+}
+
+// transitive is "full", "none" or the number of levels to load.
+func (d *Dep) setTransitive(t *toml.TomlTree) error {
+	switch transitive := t.Get("transitive").(type) {
+	case nil:
+	case int64:
+		if transitive < 0 {
+			return fmt.Errorf("%s - transitive depth can't be negative", d.Import)
+		}
+		d.Transitive = int(transitive)
+	case string:
+		switch transitive {
+		case "full":
+			d.Transitive = TransitiveFull
+		case "none":
+			d.Transitive = TransitiveNone
+		default:
+			return fmt.Errorf("%s - transitive must be \"full\", \"none\" or a depth", d.Import)
+		}
+	default:
+		return fmt.Errorf("%s - transitive must be \"full\", \"none\" or a depth", d.Import)
+	}
+	return nil
+}
+
+// Keep the deps loaded for a parent within what's left of its depth.
+func (d *Dependencies) limitTransitive(parent *Dep) {
+	if parent.Transitive == TransitiveFull {
+		return
+	}
+	for _, dep := range d.DepList {
+		if dep.Transitive == TransitiveFull || dep.Transitive > parent.Transitive-1 {
+			dep.Transitive = parent.Transitive - 1
+		}
+	}
+}
+
+func (d *Dep) setPriority(t *toml.TomlTree) {
+	if priority, ok := t.Get("priority").(int64); ok {
+		d.Priority = int(priority)
+	}
+}
+
+func (d *Dep) setTrusted(t *toml.TomlTree) {
+	if trusted, ok := t.Get("trusted").(bool); ok {
+		d.Trusted = trusted
+	}
+}
+
+func (d *Dep) setFeature(t *toml.TomlTree) {
+	if feature, ok := t.Get("feature").(string); ok {
+		d.Feature = feature
+	}
 }
 
 func (d *Dep) Validate() (err error) {
@@ -124,13 +282,57 @@ func (d *Dep) Validate() (err error) {
 	if d.Scm == "go" && d.Source != "" {
 		err = fmt.Errorf("%s - Source set, but no scm", d.Import)
 	}
+
+	if d.ScmURL != "" && d.Source != d.ScmURL {
+		err = fmt.Errorf("%s - only one of source/scm_url may be specified", d.Import)
+	}
+
+	if d.Scm == "go" && len(d.Mirrors) > 0 {
+		err = fmt.Errorf("%s - Mirrors set, but no scm", d.Import)
+	}
+
+	if asOfErr := d.validateAsOf(); asOfErr != nil {
+		err = asOfErr
+	}
+
+	if depthErr := d.validateDepth(); depthErr != nil {
+		err = depthErr
+	}
+
+	if pathErr := d.validatePath(); pathErr != nil {
+		err = pathErr
+	}
+
+	if submodulesErr := d.validateSubmodules(); submodulesErr != nil {
+		err = submodulesErr
+	}
+
+	if scmErr := d.validateScm(); scmErr != nil {
+		err = scmErr
+	}
+
+	if rangeErr := d.validateTagRange(); rangeErr != nil {
+		err = rangeErr
+	}
+
+	for _, source := range append([]string{d.Source}, d.Mirrors...) {
+		if _, env := d.envSources[source]; hasCredentials(source) && !env {
+			fmtcolor(Yellow, "      Warning: `%s` has a password in %s, use a credential helper, ~/.netrc or ssh keys instead\n", d.Import, maskCredentials(source))
+		}
+	}
 	return err
 }
 
-func (d *Dependencies) VisitDeps(fn func(dep *Dep)) {
-	for _, dep := range d.DepList {
-		fn(dep)
+// Visit the deps in the order they are scheduled to be fetched.
+// Visit the deps in the order they're scheduled, stopping at the first
+// error.
+func (d *Dependencies) VisitDeps(fn func(dep *Dep) error) error {
+	for _, dep := range d.Schedule() {
+		if err := fn(dep); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (d *Dependencies) AnyDepsNeedFetching() bool {
@@ -173,16 +375,97 @@ func (d *Dependencies) PrintDependencyTree() {
 		})
 }
 
-func (d *Dependencies) Install(repo string) {
+// Print the deps declared in the config, leaving out transitive ones.
+func (d *Dependencies) PrintRoots(w io.Writer) {
+	for _, dev := range []bool{false, true} {
+		roots := []*Dep{}
+		for _, dep := range d.DepList {
+			if dep.Dev == dev {
+				roots = append(roots, dep)
+			}
+		}
+		sort.Sort(byImport(roots))
+
+		for _, dep := range roots {
+			kind := "deps"
+			if dep.Dev {
+				kind = "dev-deps"
+			}
+			if dep.CheckoutType() != "" {
+				fmt.Fprintf(w, "%s\t%s @ %s %s\n", kind, dep.Import, dep.CheckoutType(), dep.CheckoutSpec)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\n", kind, dep.Import)
+			}
+		}
+	}
+}
+
+// Print every chain of deps that pulls in target, up to max of them.
+func (d *Dependencies) PrintPaths(w io.Writer, target string, max int) error {
+	return d.printPaths(w, nil, target, max)
+}
+
+// Like PrintPaths, with every chain starting at the project when it has
+// a repo, gp why.
+func (d *Dependencies) PrintWhy(w io.Writer, repo, target string, max int) error {
+	from := []string{}
+	if repo != "" {
+		from = append(from, repo)
+	}
+	return d.printPaths(w, from, target, max)
+}
+
+func (d *Dependencies) printPaths(w io.Writer, from []string, target string, max int) error {
+	node := d.ImportGraph.Search(target)
+	if node == nil || node.Dependency == nil {
+		return fmt.Errorf("%s is not a dependency", target)
+	}
+
+	paths := d.ImportGraph.Paths(d.Imports, node.Dependency.Import, max+1)
+	for i, path := range paths {
+		if i == max {
+			fmt.Fprintf(w, "... more than %d paths, raise --max-paths to see them\n", max)
+			break
+		}
+		fmt.Fprintln(w, strings.Join(append(from, path...), " -> "))
+	}
+	return nil
+}
+
+func (d *Dependencies) PrintLongestPath(w io.Writer) error {
+	path, err := d.ImportGraph.LongestPath(d.Imports)
+	if err != nil {
+		return err
+	}
+	if len(path) > 0 {
+		fmt.Fprintln(w, strings.Join(path, " -> "))
+	}
+	fmt.Fprintf(w, "%d deps deep\n", len(path))
+	return nil
+}
+
+type byImport []*Dep
+
+func (s byImport) Len() int           { return len(s) }
+func (s byImport) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byImport) Less(i, j int) bool { return s[i].Import < s[j].Import }
+
+func (d *Dependencies) Install(repo string) error {
 	var importName string
 
 	for e := d.ImportGraph.Leafs.Front(); e != nil; e = e.Next() {
 		importName = e.Value.(string)
 
 		if importName != repo {
-			runGo("install", importName)
+			cmd := goCommand("install", importName)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
 func (d *Dep) String() string {
@@ -193,6 +476,17 @@ func (d *Dep) String() string {
 	}
 }
 
+// The checkout type and spec, "branch master" for instance.
+func (d *Dep) Checkout() string {
+	if d.CheckoutType() == "" {
+		return ""
+	}
+	if d.AsOf != "" {
+		return d.CheckoutType() + " " + d.CheckoutSpec + " as of " + d.AsOf
+	}
+	return d.CheckoutType() + " " + d.CheckoutSpec
+}
+
 func (d *Dep) CheckoutType() string {
 	switch d.CheckoutFlag {
 	case BranchFlag:
@@ -209,10 +503,20 @@ func (d *Dep) Src() string {
 	return filepath.Join(pwd, VendorDir, "src", d.Import)
 }
 
-// switch the dep to the appropriate branch or tag
+// The url the remote refs of this dep can be listed from.
+func (d *Dep) RemoteURL() string {
+	if d.Source != "" {
+		return d.Source
+	}
+	return "https://" + d.Import
+}
+
+// switch the dep to the appropriate branch or tag, the scm runs in the
+// dep's dir without changing the working directory of gopack
 func (d *Dep) switchToBranchOrTag() error {
-	err := d.cdSrc()
-	if err != nil {
+	if _, err := os.Stat(d.Src()); err != nil {
+		log.Print(err)
+		log.Printf("couldn't find the src dir for %s\n", d.Import)
 		return err
 	}
 
@@ -227,8 +531,7 @@ func (d *Dep) switchToBranchOrTag() error {
 			log.Printf("error checking out %s on %s\n", d.CheckoutSpec, d.Import)
 		}
 	}
-
-	return cdHome()
+	return err
 }
 
 // Tell the scm where the dependency is hosted.
@@ -238,57 +541,100 @@ func (d *Dep) scmPath(scmPath string) bool {
 		return false
 	}
 
-	return stat.IsDir()
+	return stat.IsDir() || path.Base(scmPath) == HiddenFossil
 }
 
-func (d *Dep) cdSrc() error {
-	err := os.Chdir(d.Src())
-	if err != nil {
-		log.Print(err)
-		log.Printf("couldn't cd to src dir for %s\n", d.Import)
-		return err
+// Find the vcs metadata of this dep's checkout, walking up from Src
+// the same way scmInSource does.
+func (d *Dep) vcsDir() string {
+	dir := d.Src()
+	for _, _ = range strings.Split(d.Import, "/") {
+		for _, hidden := range HiddenDirs {
+			if d.scmPath(path.Join(dir, hidden)) {
+				return path.Join(dir, hidden)
+			}
+		}
+		dir = path.Join(dir, "..")
+	}
+	return ""
+}
+
+// The revision currently checked out in the vendor tree.
+func (d *Dep) CurrentRevision() (string, error) {
+	dir := d.vcsDir()
+	for tag, hidden := range HiddenDirs {
+		if dir != "" && path.Base(dir) == hidden {
+			return Scms[tag].Revision(path.Dir(dir))
+		}
+	}
+	return "", fmt.Errorf("%s - no checkout found in %s", d.Import, d.Src())
+}
+
+// Remove the vcs metadata so only the source is vendored.
+func (d *Dep) StripVcs() error {
+	if d.Path != "" {
+		return nil
+	}
+	if dir := d.vcsDir(); dir != "" {
+		if path.Base(dir) == HiddenFossil {
+			os.Remove(path.Join(path.Dir(dir), FossilCheckout))
+		}
+		return os.RemoveAll(dir)
 	}
 	return nil
 }
 
-func cdHome() error {
-	return os.Chdir(pwd)
+// A stripped checkout can't be updated in place, so start over.
+func (d *Dep) resetStripped() error {
+	if d.Path != "" {
+		return nil
+	}
+	if _, err := os.Stat(d.Src()); err == nil && d.vcsDir() == "" {
+		return os.RemoveAll(d.Src())
+	}
+	return nil
 }
 
-func (d *Dep) LoadTransitiveDeps(importGraph *Graph) (*Dependencies, error) {
+// The root config's excludes apply to every dep's config.
+func (d *Dep) LoadTransitiveDeps(importGraph *Graph, excludes []string) (*Dependencies, error) {
 	configPath := path.Join(d.Src(), "gopack.config")
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return nil, nil
 	}
-	config := NewConfig(d.Src())
+	config, err := NewDepConfig(d.Src())
+	if err != nil {
+		return nil, err
+	}
+	config.Excludes = excludes
+	config.Parent = d.Import
 	return config.LoadDependencyModel(importGraph)
 }
 
 func (d *Dependencies) Validate(p *ProjectStats) []*ProjectError {
 	errors := []*ProjectError{}
-	includedDeps := make(map[string]*Dep)
 
+	// report a validation error with the locations in source where the
+	// packages of a repository are imported but no dep governs them,
+	// stdlib imports have no host and are never remote
+	unmanaged := make(map[string][]*ImportStats)
+	roots := []string{}
 	for path, s := range p.ImportStatsByPath {
-		node, found := d.IncludesDependency(path)
-		if s.Remote {
-			if found {
-				includedDeps[node.Dependency.Import] = node.Dependency
-			} else {
-				// XXX ignore for now
-
-				// report a validation error with the locations in source
-				// where an import is used but unmanaged in gopack.config
-				errors = append(errors, UnmanagedImportError(s))
+		if _, found := d.IncludesDependency(path); s.Remote && !found && !d.warnExcluded(s) {
+			root := repoRootOf(path)
+			if unmanaged[root] == nil {
+				roots = append(roots, root)
 			}
+			unmanaged[root] = append(unmanaged[root], s)
 		}
 	}
+	sort.Strings(roots)
+	for _, root := range roots {
+		sort.Sort(byImportPath(unmanaged[root]))
+		errors = append(errors, UnmanagedImportError(root, unmanaged[root]))
+	}
 
-	for _, dep := range d.DepList {
-		_, found := includedDeps[dep.Import]
-		if !found && !p.IsImportUsed(dep.Import) {
-			fmt.Printf("      Warning: `%s` not used\n", dep.Import)
-			//			errors = append(errors, UnusedDependencyError(dep.Import))
-		}
+	for _, dep := range d.Unused(p) {
+		errors = append(errors, UnusedDependencyError(dep.Import))
 	}
 	return errors
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path"
@@ -92,9 +93,11 @@ func TestTransitiveDependencies(t *testing.T) {
 `
 	createFixtureConfig(pwd, fixture)
 
-	config := NewConfig(pwd)
+	config := loadTestConfig(pwd)
 	dependencies, _ := config.LoadDependencyModel(NewGraph())
-	loadTransitiveDependencies(dependencies)
+	if err := loadTransitiveDependencies(dependencies); err != nil {
+		t.Fatal(err)
+	}
 
 	dep := path.Join(pwd, VendorDir, "src", "github.com", "calavera", "testGoPack")
 	if _, err := os.Stat(dep); os.IsNotExist(err) {
@@ -122,7 +125,7 @@ import = "github.com/nu7hatch/gouuid"
   branch = "master"
 `
 	createFixtureConfig(pwd, fixture)
-	config := NewConfig(pwd)
+	config := loadTestConfig(pwd)
 	dependencies, _ := config.LoadDependencyModel(NewGraph())
 	if len(dependencies.DepList) > 2 {
 		t.Fatalf("WHOA buddy, shoulda had 2 deps, had %d instead", len(dependencies.DepList))
@@ -134,7 +137,9 @@ import = "github.com/nu7hatch/gouuid"
 		t.Fatalf("Scm should have been go, was %s", dependencies.DepList[1])
 	}
 
-	loadTransitiveDependencies(dependencies)
+	if err := loadTransitiveDependencies(dependencies); err != nil {
+		t.Fatal(err)
+	}
 	dep := path.Join(pwd, VendorDir, "src", "github.com", "calavera", "testGoPack")
 	if _, err := os.Stat(dep); os.IsNotExist(err) {
 		t.Errorf("Expected dependency github.com/calavera/testGoPack to be in vendor %s\n", pwd)
@@ -159,10 +164,33 @@ func TestScmAndSourceRequired(t *testing.T) {
 
 	for _, fixture := range fixtures {
 		createFixtureConfig(pwd, fixture)
-		config := NewConfig(pwd)
+		config := loadTestConfig(pwd)
 		dependencies, err := config.LoadDependencyModel(NewGraph())
 		if err == nil {
 			t.Fatalf("Supposed to have failed due to lacking Source or Scm - %s", dependencies.DepList[0])
 		}
 	}
 }
+
+func TestPrintRootsExcludesTransitiveDeps(t *testing.T) {
+	config := setupTestConfig(`
+[deps.testgopack]
+  import = "github.com/calavera/testGoPack"
+  branch = "master"
+[dev-deps.mux]
+  import = "github.com/gorilla/mux"
+  tag = "v1.0"
+`)
+
+	deps, _ := config.LoadDependencyModel(NewGraph())
+	deps.ImportGraph.Insert(NewDependency("github.com/d2fn/gopack"))
+
+	var buf bytes.Buffer
+	deps.PrintRoots(&buf)
+
+	expected := "deps\tgithub.com/calavera/testGoPack @ branch master\n" +
+		"dev-deps\tgithub.com/gorilla/mux @ tag v1.0\n"
+	if buf.String() != expected {
+		t.Errorf("Expected roots to be\n%s\nbut were\n%s", expected, buf.String())
+	}
+}
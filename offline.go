@@ -0,0 +1,45 @@
+package main
+
+import "os"
+
+// installdeps exits with this when some deps couldn't be fetched and
+// their vendored copy was used instead.
+const OfflineFallbackExit = 3
+
+var (
+	// use the vendored copy of deps that can't be fetched, set with --offline-fallback
+	offlineFallback = false
+	offlineDeps     = []string{}
+)
+
+// Whether the dep can be built from the copy already in the vendor tree
+// after failing to fetch it. A repository that's gone isn't a network
+// problem so it still fails.
+func (d *Dep) fallBackOffline(err error) bool {
+	if !offlineFallback {
+		return false
+	}
+	if fetchErr, ok := err.(*FetchError); ok && fetchErr.NotFound() {
+		return false
+	}
+	if scmErr, ok := err.(*ScmError); ok && scmErr.NotFound() {
+		return false
+	}
+	if d.vcsDir() == "" {
+		return false
+	}
+	if _, statErr := os.Stat(d.Src()); statErr != nil {
+		return false
+	}
+
+	fmtcolor(Yellow, "      Offline: `%s` couldn't be fetched, using the vendored copy: %s\n", d.Import, err)
+	offlineDeps = append(offlineDeps, d.Import)
+	return true
+}
+
+// Warn about the deps that fell back to their vendored copy.
+func PrintOfflineSummary() {
+	if len(offlineDeps) > 0 {
+		fmtcolor(Yellow, "      Warning: %d dependencies may be stale, fell back to the vendor tree for %v\n", len(offlineDeps), offlineDeps)
+	}
+}
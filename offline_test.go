@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"testing"
+	"time"
+)
+
+// Fake git without network access.
+func stubOffline(output string) func() {
+	origSleep := sleep
+	sleep = func(time.Duration) {}
+	unreachable := func(cmd *exec.Cmd) ([]byte, error) {
+		return []byte(output), errors.New("exit status 128")
+	}
+	restore := stubCommands(commandStubs{"fetch": unreachable, "clone": unreachable})
+	return func() {
+		restore()
+		sleep = origSleep
+	}
+}
+
+func TestOfflineFallbackUsesVendorTree(t *testing.T) {
+	setupTestPwd()
+	defer stubOffline("fatal: unable to access: Could not resolve host: github.com")()
+
+	offlineFallback = true
+	defer func() { offlineFallback, offlineDeps = false, []string{} }()
+
+	dep := vendoredGitDep("github.com/gopack-test/offline", "branch", "master")
+	deps := &Dependencies{ImportGraph: NewGraph(), DepList: []*Dep{dep}}
+	deps.ImportGraph.Insert(dep)
+
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(offlineDeps) != 1 || offlineDeps[0] != dep.Import {
+		t.Errorf("Expected %s to fall back to the vendor tree, fell back for %v\n", dep.Import, offlineDeps)
+	}
+
+	dat, err := ioutil.ReadFile(path.Join(dep.Src(), "main.go"))
+	if err != nil || string(dat) != "package main\n" {
+		t.Errorf("Expected the vendored copy to be kept\n")
+	}
+}
+
+func TestOfflineFallbackNeedsVendoredCopy(t *testing.T) {
+	setupTestPwd()
+	defer stubOffline("fatal: unable to access: Could not resolve host: github.com")()
+
+	offlineFallback = true
+	defer func() { offlineFallback, offlineDeps = false, []string{} }()
+
+	dep := gitDep("github.com/gopack-test/offline-missing", "branch", "master")
+	if err := dep.Get(); err == nil || dep.fallBackOffline(err) {
+		t.Errorf("Expected a dep that isn't vendored to fail without network\n")
+	}
+}
+
+func TestOfflineFallbackIgnoresMissingRepository(t *testing.T) {
+	setupTestPwd()
+	defer stubOffline("remote: Repository not found.")()
+
+	offlineFallback = true
+	defer func() { offlineFallback, offlineDeps = false, []string{} }()
+
+	dep := vendoredGitDep("github.com/gopack-test/offline-gone", "branch", "master")
+	if err := dep.Get(); err == nil || dep.fallBackOffline(err) {
+		t.Errorf("Expected a repository that's gone to fail even with --offline-fallback\n")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// list the deps nothing uses, set with graph --orphan-deps
+var graphOrphans = false
+
+// The deps in the graph that neither the project's code imports nor a
+// dep it uses depends on, directly or through other deps.
+func (d *Dependencies) Orphans(p *ProjectStats, repo string) []*Dep {
+	used := d.usedImports(p)
+
+	orphans := []*Dep{}
+	seen := make(map[string]bool)
+	for e := d.ImportGraph.Leafs.Front(); e != nil; e = e.Next() {
+		importPath := e.Value.(string)
+		node := d.ImportGraph.Search(importPath)
+		if importPath == repo || seen[importPath] || node == nil || node.Dependency == nil {
+			continue
+		}
+		seen[importPath] = true
+		if !used[importPath] {
+			orphans = append(orphans, node.Dependency)
+		}
+	}
+	sort.Sort(byImport(orphans))
+	return orphans
+}
+
+// The deps the project's code imports, and every dep they depend on.
+func (d *Dependencies) usedImports(p *ProjectStats) map[string]bool {
+	used := make(map[string]bool)
+	var use func(importPath string)
+	use = func(importPath string) {
+		if used[importPath] {
+			return
+		}
+		used[importPath] = true
+		for _, child := range d.ImportGraph.Edges[importPath] {
+			use(child)
+		}
+	}
+
+	for path, s := range p.ImportStatsByPath {
+		if node, found := d.IncludesDependency(path); s.Remote && found && node.Dependency != nil {
+			use(node.Dependency.Import)
+		}
+	}
+	return used
+}
+
+func (d *Dependencies) PrintOrphans(w io.Writer, p *ProjectStats, repo string) {
+	orphans := d.Orphans(p, repo)
+	for _, dep := range orphans {
+		fmt.Fprintln(w, dep.Import)
+	}
+	fmt.Fprintf(w, "%d deps could be removed\n", len(orphans))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOrphanDeps(t *testing.T) {
+	setupTestPwd()
+	createSourceFixture(pwd, "main.go", `package main
+
+import (
+	"fmt"
+
+	"github.com/gopack-test/orphans-app/server"
+)
+`)
+	p, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// app -> lib is only used through app, dead -> dead-child is never used
+	deps := &Dependencies{ImportGraph: NewGraph()}
+	for _, importPath := range []string{
+		"github.com/gopack-test/orphans-self",
+		"github.com/gopack-test/orphans-app",
+		"github.com/gopack-test/orphans-lib",
+		"github.com/gopack-test/orphans-dead",
+		"github.com/gopack-test/orphans-dead-child",
+	} {
+		deps.ImportGraph.Insert(NewDependency(importPath))
+	}
+	deps.ImportGraph.AddEdge("github.com/gopack-test/orphans-app", "github.com/gopack-test/orphans-lib")
+	deps.ImportGraph.AddEdge("github.com/gopack-test/orphans-dead", "github.com/gopack-test/orphans-dead-child")
+
+	var out bytes.Buffer
+	deps.PrintOrphans(&out, p, "github.com/gopack-test/orphans-self")
+
+	expected := "github.com/gopack-test/orphans-dead\n" +
+		"github.com/gopack-test/orphans-dead-child\n" +
+		"2 deps could be removed\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\nbut printed\n%s\n", expected, out.String())
+	}
+}
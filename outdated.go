@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+const UnknownRevision = "unknown"
+
+// Scms that can tell what the remote of a dep has without fetching it.
+type RemoteScm interface {
+	// the commit at the head of the tracked branch, or of the default one
+	RemoteHead(d *Dep) (string, error)
+	RemoteTags(d *Dep) ([]string, error)
+}
+
+// A dep and what its remote has to offer, gp outdated.
+type OutdatedDep struct {
+	Import  string
+	Current string
+	Latest  string
+	// the remote has something newer than the current revision or tag
+	Behind bool
+}
+
+// Compare every dep with its remote. Tag deps are compared with the
+// highest semver tag, the others with the head of their branch. Deps
+// whose scm can't be asked, or all of them --offline, are unknown.
+func Outdated(deps []*Dep, lock *Lock) []*OutdatedDep {
+	outdated := []*OutdatedDep{}
+	for _, dep := range deps {
+		o := &OutdatedDep{Import: dep.Import, Current: currentOf(dep, lock), Latest: UnknownRevision}
+		outdated = append(outdated, o)
+
+		scm, ok := Scms[dep.Scm].(RemoteScm)
+		if !ok || offlineMode {
+			continue
+		}
+
+		var err error
+		if dep.CheckoutFlag == TagFlag {
+			err = o.latestTag(dep, scm)
+		} else {
+			err = o.latestHead(dep, scm, lock)
+		}
+		if err != nil {
+			fmtcolor(Yellow, "      Warning: can't tell the latest revision of `%s`: %s\n", dep.Import, err)
+		}
+	}
+	sort.Sort(byOutdatedImport(outdated))
+	return outdated
+}
+
+// The locked tag or revision, what the config asks for when it's not
+// locked.
+func currentOf(d *Dep, lock *Lock) string {
+	locked := lock.Deps[d.Import]
+	switch {
+	case d.CheckoutFlag == TagFlag && locked != nil && locked.Tag != "":
+		return locked.Tag
+	case d.CheckoutFlag == TagFlag && !isVersionRange(d.CheckoutSpec):
+		return d.CheckoutSpec
+	case locked != nil && locked.Revision != "":
+		return shortRevision(locked.Revision)
+	case d.CheckoutFlag == CommitFlag:
+		return shortRevision(d.CheckoutSpec)
+	}
+	return UnknownRevision
+}
+
+func (o *OutdatedDep) latestTag(d *Dep, scm RemoteScm) error {
+	tags, err := scm.RemoteTags(d)
+	if err != nil {
+		return err
+	}
+	latest := highestMatch(tags, nil)
+	if latest == "" {
+		return nil
+	}
+	o.Latest = latest
+
+	current, _, ok := parseSemver(o.Current)
+	highest, _, _ := parseSemver(latest)
+	o.Behind = !ok || highest.compare(current) > 0
+	return nil
+}
+
+func (o *OutdatedDep) latestHead(d *Dep, scm RemoteScm, lock *Lock) error {
+	head, err := scm.RemoteHead(d)
+	if err != nil || head == "" {
+		return err
+	}
+	o.Latest = shortRevision(head)
+
+	locked := lock.Deps[d.Import]
+	o.Behind = locked == nil || locked.Revision != head
+	return nil
+}
+
+func shortRevision(revision string) string {
+	if len(revision) > 7 {
+		return revision[:7]
+	}
+	return revision
+}
+
+// A table of import path, current and latest, the deps that are behind
+// marked with a *.
+func PrintOutdated(w io.Writer, outdated []*OutdatedDep) {
+	behind := 0
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "import\tcurrent\tlatest")
+	for _, o := range outdated {
+		mark := ""
+		if o.Behind {
+			mark = " *"
+			behind++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s%s\n", o.Import, o.Current, o.Latest, mark)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d of %d deps have something newer\n", behind, len(outdated))
+}
+
+func (g Git) RemoteHead(d *Dep) (string, error) {
+	refs, err := LsRemote(d.RemoteURL())
+	if err != nil {
+		return "", err
+	}
+	name := "HEAD"
+	if d.CheckoutFlag == BranchFlag {
+		name = "refs/heads/" + d.CheckoutSpec
+	}
+	for _, ref := range refs {
+		if ref.Name == name {
+			return ref.Hash, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no %s", maskCredentials(d.RemoteURL()), name)
+}
+
+func (g Git) RemoteTags(d *Dep) ([]string, error) {
+	return remoteTags(d)
+}
+
+type byOutdatedImport []*OutdatedDep
+
+func (s byOutdatedImport) Len() int           { return len(s) }
+func (s byOutdatedImport) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byOutdatedImport) Less(i, j int) bool { return s[i].Import < s[j].Import }
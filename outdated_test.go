@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// Fake ls-remote where master has moved on and v1.3.0 is out.
+func stubOutdatedRemote(calls *int) func() {
+	return stubCommands(commandStubs{
+		"ls-remote": func(cmd *exec.Cmd) ([]byte, error) {
+			*calls++
+			refs := "" +
+				movedSha + "\tHEAD\n" +
+				movedSha + "\trefs/heads/master\n" +
+				lockedSha + "\trefs/heads/stable\n" +
+				lockedSha + "\trefs/tags/v1.2.0\n" +
+				movedSha + "\trefs/tags/v1.3.0\n" +
+				movedSha + "\trefs/tags/nightly\n"
+			return []byte(refs), nil
+		},
+	})
+}
+
+func outdatedLock(deps ...*Dep) *Lock {
+	lock := NewLock()
+	for _, dep := range deps {
+		lock.Deps[dep.Import] = &LockedDep{Import: dep.Import, Checkout: dep.Checkout(), Revision: lockedSha, Tag: dep.Tag}
+	}
+	return lock
+}
+
+func TestOutdatedComparesWithRemote(t *testing.T) {
+	setupTestPwd()
+	calls := 0
+	defer stubOutdatedRemote(&calls)()
+
+	moved := gitDep("github.com/gopack-test/outdated-moved", "branch", "master")
+	stable := gitDep("github.com/gopack-test/outdated-stable", "branch", "stable")
+	tagged := gitDep("github.com/gopack-test/outdated-tagged", "tag", "v1.2.0")
+	ranged := gitDep("github.com/gopack-test/outdated-ranged", "tag", "~1.3")
+	ranged.Tag = "v1.3.0"
+	pinned := gitDep("github.com/gopack-test/outdated-pinned", "commit", "182cae2")
+
+	outdated := Outdated([]*Dep{moved, stable, tagged, ranged, pinned}, outdatedLock(moved, stable, tagged, ranged, pinned))
+
+	expected := map[string]OutdatedDep{
+		moved.Import:  {moved.Import, "182cae2", "9d57c89", true},
+		stable.Import: {stable.Import, "182cae2", "182cae2", false},
+		tagged.Import: {tagged.Import, "v1.2.0", "v1.3.0", true},
+		ranged.Import: {ranged.Import, "v1.3.0", "v1.3.0", false},
+		pinned.Import: {pinned.Import, "182cae2", "9d57c89", true},
+	}
+	if len(outdated) != len(expected) {
+		t.Fatalf("Expected %d deps, got %d\n", len(expected), len(outdated))
+	}
+	for _, o := range outdated {
+		if *o != expected[o.Import] {
+			t.Errorf("Expected %v, got %v\n", expected[o.Import], *o)
+		}
+	}
+	if outdated[0].Import != moved.Import {
+		t.Errorf("Expected the deps sorted by import, first was %s\n", outdated[0].Import)
+	}
+
+	var out bytes.Buffer
+	PrintOutdated(&out, outdated)
+	if !strings.Contains(out.String(), "github.com/gopack-test/outdated-tagged v1.2.0  v1.3.0 *\n") {
+		t.Errorf("Expected the tagged dep to be marked as behind, printed\n%s\n", out.String())
+	}
+	if !strings.HasSuffix(out.String(), "3 of 5 deps have something newer\n") {
+		t.Errorf("Expected a summary of the deps behind, printed\n%s\n", out.String())
+	}
+}
+
+func TestOutdatedOfflineIsUnknown(t *testing.T) {
+	setupTestPwd()
+	calls := 0
+	defer stubOutdatedRemote(&calls)()
+	defer func() { offlineMode = false }()
+	offlineMode = true
+
+	dep := gitDep("github.com/gopack-test/outdated-offline", "branch", "master")
+	hg := &Dep{Import: "github.com/gopack-test/outdated-hg", Scm: HgTag}
+	outdated := Outdated([]*Dep{dep, hg}, NewLock())
+
+	for _, o := range outdated {
+		if o.Latest != UnknownRevision || o.Behind {
+			t.Errorf("Expected %s to be unknown, got %v\n", o.Import, *o)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("Expected no remote to be asked --offline, ls-remote ran %d times\n", calls)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// Read the [override] section, pinning transitive deps by import path.
+func (c *Config) loadOverrides() (map[string]*Dep, error) {
+	overrides := make(map[string]*Dep)
+	if c.OverridesTree == nil {
+		return overrides, nil
+	}
+
+	for _, k := range c.OverridesTree.Keys() {
+		tree, ok := c.OverridesTree.Get(k).(*toml.TomlTree)
+		if !ok {
+			return nil, fmt.Errorf("override.%s - expected a dependency table", k)
+		}
+		importPath, ok := tree.Get("import").(string)
+		if !ok || importPath == "" {
+			return nil, fmt.Errorf("override.%s - missing import", k)
+		}
+		if _, found := overrides[importPath]; found {
+			return nil, fmt.Errorf("override.%s - %s is overridden more than once", k, importPath)
+		}
+
+		d := NewDependency(importPath)
+		d.setScm(tree)
+		d.setSource(tree)
+		d.setMirrors(tree)
+		d.envSources = c.envSources[tree]
+		d.setCheckout(tree, "branch", BranchFlag)
+		d.setCheckout(tree, "commit", CommitFlag)
+		d.setCheckout(tree, "tag", TagFlag)
+		if err := d.Validate(); err != nil {
+			return nil, err
+		}
+		overrides[importPath] = d
+	}
+	return overrides, nil
+}
+
+// Pin the transitive deps that are overridden, whatever their own
+// config asked for.
+func (d *Dependencies) applyOverrides() {
+	for _, dep := range d.DepList {
+		override := d.Overrides[dep.Import]
+		if override == nil {
+			continue
+		}
+
+		requested := dep.Checkout()
+		dep.Scm = override.Scm
+		dep.Source = override.Source
+		dep.Mirrors = override.Mirrors
+		dep.CheckoutFlag = override.CheckoutFlag
+		dep.CheckoutSpec = override.CheckoutSpec
+		dep.Fetch(true)
+
+		if requested == "" {
+			requested = "no checkout"
+		}
+		fmtcolor(Yellow, "     Override: `%s` at %s instead of %s\n", dep.Import, dep.Checkout(), requested)
+	}
+}
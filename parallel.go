@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// how many deps are downloaded at once, set with -j
+	fetchJobs = 1
+	// how many of them from the same host, set with
+	// --max-parallel-per-host, any number when 0
+	maxPerHost = 0
+)
+
+// A slot per download from a host, limiting how many run at once.
+type hostSlots struct {
+	sync.Mutex
+	limit int
+	slots map[string]chan bool
+}
+
+func newHostSlots(limit int) *hostSlots {
+	return &hostSlots{limit: limit, slots: make(map[string]chan bool)}
+}
+
+// Wait for a slot of the host, returning how long it took and the func
+// giving the slot back.
+func (h *hostSlots) acquire(host string) (time.Duration, func()) {
+	if h.limit < 1 {
+		return 0, func() {}
+	}
+	h.Lock()
+	slot, ok := h.slots[host]
+	if !ok {
+		slot = make(chan bool, h.limit)
+		h.slots[host] = slot
+	}
+	h.Unlock()
+
+	started := timeNow()
+	slot <- true
+	return timeNow().Sub(started), func() { <-slot }
+}
+
+// Download the deps about to be updated fetchJobs at a time, ahead of
+// checking them out and loading their own deps in order. Only the
+// download runs concurrently, the graph is still built one dep at a
+// time in the order they are scheduled.
+func (d *Dependencies) prefetch() {
+	if fetchJobs < 2 || graphOnly || reuseFrom != nil {
+		return
+	}
+
+	// a dirty dep is refused in its turn, before anything touches it,
+	// svn would merge the download into the local modifications
+	queue := []*Dep{}
+	for _, dep := range d.Schedule() {
+		if dep.canPrefetch() && dep.checkClean() == nil {
+			queue = append(queue, dep)
+		}
+	}
+
+	slots := newHostSlots(maxPerHost)
+	jobs := make(chan *Dep)
+	var wg sync.WaitGroup
+	for i := 0; i < fetchJobs && i < len(queue); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dep := range jobs {
+				waited, release := slots.acquire(dep.host())
+				scheduling.Prefetch(dep.Import, waited, func() { dep.prefetchErr = dep.Get() })
+				release()
+				dep.prefetched = true
+			}
+		}()
+	}
+	for _, dep := range queue {
+		jobs <- dep
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Deps whose vendored copy needs checking or resetting before it's
+// fetched, or that go get fetches into the shared GOPATH, are left to
+// be fetched in order.
+func (d *Dep) canPrefetch() bool {
+	if !d.fetch || d.Scm == "go" || d.ValidateCommand != "" {
+		return false
+	}
+	if sinceLock != nil && sinceLock.Unchanged(d) {
+		return false
+	}
+	if checkpoint != nil && checkpoint.Done(d) {
+		return false
+	}
+	if _, err := os.Stat(d.Src()); err == nil && d.vcsDir() == "" {
+		return false
+	}
+	return true
+}
+
+// The host the dep is downloaded from, the first element of the import
+// path when it has no source.
+func (d *Dep) host() string {
+	if strings.Contains(d.Source, "://") {
+		if u, err := url.Parse(d.Source); err == nil {
+			return u.Host
+		}
+	}
+	if m := scpLikeSource.FindStringSubmatch(d.Source); m != nil {
+		return m[1]
+	}
+	source := d.Source
+	if source == "" {
+		source = d.Import
+	}
+	return strings.SplitN(source, "/", 2)[0]
+}
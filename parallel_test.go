@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Fake git whose clones take a while, recording how many ran at once and
+// the order deps were checked out in.
+func stubSlowGit(inFlight *int, checkedOut *[]string) func() {
+	var mu sync.Mutex
+	running := 0
+	return stubCommands(commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			mu.Lock()
+			running++
+			if running > *inFlight {
+				*inFlight = running
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+			defer func() {
+				mu.Lock()
+				running--
+				mu.Unlock()
+			}()
+			return cloneCheckout(cmd)
+		},
+		"checkout": func(cmd *exec.Cmd) ([]byte, error) {
+			*checkedOut = append(*checkedOut, strings.Join(cmd.Args[1:], " "))
+			return nil, nil
+		},
+	})
+}
+
+func TestPrefetchDownloadsConcurrently(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	fetchJobs = 3
+	defer func() { fetchJobs = 1 }()
+
+	inFlight := 0
+	checkedOut := []string{}
+	defer stubSlowGit(&inFlight, &checkedOut)()
+
+	graph := NewGraph()
+	deps := &Dependencies{ImportGraph: graph}
+	for _, name := range []string{"c", "a", "b", "d"} {
+		dep := gitDep("github.com/gopack-test/parallel-"+name, "branch", name)
+		deps.DepList = append(deps.DepList, dep)
+		graph.Insert(dep)
+	}
+
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	if inFlight < 2 || inFlight > 3 {
+		t.Errorf("Expected up to 3 clones at once, %d ran at once\n", inFlight)
+	}
+	expected := "checkout c,checkout a,checkout b,checkout d"
+	if actual := strings.Join(checkedOut, ","); actual != expected {
+		t.Errorf("Expected the deps to be checked out in order %s but were %s\n", expected, actual)
+	}
+	for _, dep := range deps.DepList {
+		if graph.Search(dep.Import) == nil {
+			t.Errorf("Expected %s to be in the graph\n", dep.Import)
+		}
+	}
+}
+
+func TestDirtyDepsAreNotPrefetched(t *testing.T) {
+	setupTestPwd()
+	fetchJobs = 2
+	defer func() { fetchJobs = 1 }()
+
+	fetched := []string{}
+	defer stubCommands(commandStubs{
+		"status": func(cmd *exec.Cmd) ([]byte, error) {
+			if strings.HasSuffix(cmd.Dir, "parallel-dirty") {
+				return []byte(" M main.go\n"), nil
+			}
+			return nil, nil
+		},
+		"*": func(cmd *exec.Cmd) ([]byte, error) {
+			fetched = append(fetched, path.Base(cmd.Dir)+" "+cmd.Args[1])
+			return nil, nil
+		},
+	})()
+
+	dirty := vendoredGitDep("github.com/gopack-test/parallel-dirty", "branch", "master")
+	clean := vendoredGitDep("github.com/gopack-test/parallel-clean", "branch", "master")
+	deps := &Dependencies{ImportGraph: NewGraph(), DepList: []*Dep{dirty, clean}}
+	deps.prefetch()
+
+	if dirty.prefetched || !clean.prefetched {
+		t.Errorf("Expected only the clean dep to be prefetched, prefetched dirty %v and clean %v\n", dirty.prefetched, clean.prefetched)
+	}
+	for _, command := range fetched {
+		if strings.HasPrefix(command, "parallel-dirty") {
+			t.Errorf("Expected nothing to touch the dirty dep before its turn, ran %s\n", command)
+		}
+	}
+}
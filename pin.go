@@ -0,0 +1,43 @@
+package main
+
+var (
+	// resolve every dep again instead of checking out the locked revisions, set by gp lock
+	relock = false
+	// the lock deps are pinned to while the config is the one it was written for
+	pinnedLock *Lock
+	// the lock version ranges keep the tag they resolved to from, nil
+	// when they're resolved again
+	rangeLock *Lock
+)
+
+// The lock to check out deps from, nil when the config changed since
+// it was written or it's being regenerated.
+func (c *Config) lockToPin() (*Lock, error) {
+	if relock {
+		return nil, nil
+	}
+	lock, err := ReadLock()
+	if err != nil || lock.Checksum == "" || lock.Checksum != string(c.checksum()) {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// Check out the locked revision of the branch and tag deps about to be
+// fetched instead of whatever the branch or tag points to now, as long
+// as they still ask for what was locked.
+func (d *Dependencies) pinLocked(lock *Lock) {
+	if lock == nil {
+		return
+	}
+	for _, dep := range d.DepList {
+		locked := lock.Deps[dep.Import]
+		if !dep.fetch || locked == nil || locked.Revision == "" || locked.Checkout != dep.Checkout() {
+			continue
+		}
+		if dep.CheckoutFlag == CommitFlag || (dep.Scm != GitTag && dep.Scm != HgTag && dep.Scm != "go") {
+			continue
+		}
+		dep.Locked = locked.Revision
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+const pinnedConfig = `
+[deps.pinned]
+  import = "github.com/gopack-test/pinned-branch"
+  scm = "git"
+  source = "https://github.com/gopack-test/pinned-branch.git"
+  branch = "master"
+`
+
+func writePinnedLock(t *testing.T, config *Config, checkout string) {
+	lock := NewLock()
+	lock.Checksum = string(config.checksum())
+	lock.Deps["github.com/gopack-test/pinned-branch"] = &LockedDep{
+		Import:   "github.com/gopack-test/pinned-branch",
+		Checkout: checkout,
+		Revision: lockedSha,
+	}
+	if err := lock.Write(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstallChecksOutLockedRevision(t *testing.T) {
+	config := setupTestConfig(pinnedConfig)
+	writePinnedLock(t, config, "branch master")
+
+	ran := []string{}
+	defer stubGitWithConfigs(&ran, nil)()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := config.lockToPin()
+	if err != nil || lock == nil {
+		t.Fatalf("Expected the lock of an unchanged config to be pinned, got %v\n", err)
+	}
+	deps.pinLocked(lock)
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	if !containsString(ran, "checkout "+lockedSha) || containsString(ran, "checkout master") {
+		t.Errorf("Expected the locked revision to be checked out instead of master, ran %v\n", ran)
+	}
+}
+
+func TestChangedCheckoutIsNotPinned(t *testing.T) {
+	config := setupTestConfig(pinnedConfig)
+	writePinnedLock(t, config, "branch develop")
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, _ := config.lockToPin()
+	deps.pinLocked(lock)
+
+	if locked := deps.DepList[0].Locked; locked != "" {
+		t.Errorf("Expected a dep asking for another branch to not be pinned, got %s\n", locked)
+	}
+}
+
+func TestLockIsNotPinnedWhenRegenerated(t *testing.T) {
+	config := setupTestConfig(pinnedConfig)
+	writePinnedLock(t, config, "branch master")
+
+	relock = true
+	defer func() { relock = false }()
+
+	if lock, err := config.lockToPin(); lock != nil || err != nil {
+		t.Errorf("Expected gp lock to resolve the deps again, got %v %v\n", lock, err)
+	}
+}
+
+func TestLockIsNotPinnedWhenConfigChanged(t *testing.T) {
+	config := setupTestConfig(pinnedConfig)
+	writePinnedLock(t, config, "branch master")
+
+	changed := setupTestConfig(pinnedConfig + "\n[deps.other]\n  import = \"github.com/gopack-test/other\"\n")
+	if lock, err := changed.lockToPin(); lock != nil || err != nil {
+		t.Errorf("Expected the lock of another config to be ignored, got %v %v\n", lock, err)
+	}
+}
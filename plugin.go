@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// Like git, `gp foo` runs a gopack-foo executable found on PATH before
+// handing the command over to go.
+func findPlugin(action string) string {
+	if action == "" {
+		return ""
+	}
+	path, err := exec.LookPath("gopack-" + action)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// Run the plugin with the remaining args and the gopack environment:
+// GOPATH pointing at the vendor tree, GOPACK_DIR, GOPACK_VENDOR_DIR and
+// GOPACK_VERSION. Returns the exit status of the plugin.
+func runPlugin(path string, args ...string) (int, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"GOPACK_DIR="+pwd,
+		"GOPACK_VENDOR_DIR="+filepath.Join(pwd, VendorDir),
+		"GOPACK_VERSION="+GopackVersion)
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), nil
+		}
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
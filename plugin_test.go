@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func createPlugin(t *testing.T, name, script string) string {
+	dir, _ := ioutil.TempDir("", "gopack-plugins-")
+	check(ioutil.WriteFile(path.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755))
+
+	origPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+origPath)
+	return origPath
+}
+
+func TestPluginIsInvoked(t *testing.T) {
+	setupTestPwd()
+	out := path.Join(pwd, "hello.out")
+	defer os.Setenv("PATH", createPlugin(t, "gopack-hello", `echo "$@ $GOPACK_DIR $GOPACK_VENDOR_DIR" > `+out+"\n"))
+
+	plugin := findPlugin("hello")
+	if plugin == "" {
+		t.Fatal("Expected gopack-hello to be found on PATH")
+	}
+
+	status, err := runPlugin(plugin, "world", "--loud")
+	if err != nil || status != 0 {
+		t.Fatalf("Expected the plugin to succeed, got %d %v\n", status, err)
+	}
+
+	dat, _ := ioutil.ReadFile(out)
+	expected := "world --loud " + pwd + " " + path.Join(pwd, VendorDir)
+	if strings.TrimSpace(string(dat)) != expected {
+		t.Errorf("Expected the plugin to get the args and environment %s, got %s\n", expected, dat)
+	}
+}
+
+func TestPluginExitStatusIsPropagated(t *testing.T) {
+	defer os.Setenv("PATH", createPlugin(t, "gopack-broken", "exit 7\n"))
+
+	if status, _ := runPlugin(findPlugin("broken")); status != 7 {
+		t.Errorf("Expected the exit status of the plugin, got %d\n", status)
+	}
+}
+
+func TestMissingPluginFallsBackToGo(t *testing.T) {
+	if plugin := findPlugin("gopack-test-missing"); plugin != "" {
+		t.Errorf("Expected no plugin to be found, found %s\n", plugin)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var (
+	// where to write a pprof cpu profile, set with --profile-cpu
+	profileCPU = ""
+	// where to write an execution trace, set with --trace
+	traceFile = ""
+)
+
+// Start the profiles asked for, the returned func stops and writes them.
+func startProfiling() (func(), error) {
+	stops := []func(){}
+	stop := func() {
+		for _, s := range stops {
+			s()
+		}
+	}
+
+	if profileCPU != "" {
+		f, err := os.Create(profileCPU)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stop()
+			return nil, err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	return stop, nil
+}
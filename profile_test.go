@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestProfilesAreWritten(t *testing.T) {
+	setupTestPwd()
+
+	profileCPU, traceFile = path.Join(pwd, "cpu.pprof"), path.Join(pwd, "gopack.trace")
+	defer func() { profileCPU, traceFile = "", "" }()
+
+	stop, err := startProfiling()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createSourceFixture(pwd, "main.go", "package main\nimport \"github.com/gopack-test/profiled\"\n")
+	if _, err := AnalyzeSourceTree(pwd); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+
+	// pprof profiles are gzipped protocol buffers
+	dat, _ := ioutil.ReadFile(profileCPU)
+	r, err := gzip.NewReader(bytes.NewReader(dat))
+	if err != nil {
+		t.Fatalf("Expected a gzipped pprof profile: %s\n", err)
+	}
+	if profile, err := ioutil.ReadAll(r); err != nil || len(profile) == 0 {
+		t.Errorf("Expected the cpu profile to have content\n")
+	}
+
+	dat, _ = ioutil.ReadFile(traceFile)
+	if !strings.HasPrefix(string(dat), "go 1.") {
+		t.Errorf("Expected an execution trace in %s\n", traceFile)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The import paths the vendor tree keeps: the deps required, those in
+// the lock, and the repositories go get pulled in for the go deps, which
+// are never in the graph.
+func (d *Dependencies) keptImports() ([]string, error) {
+	lock, err := ReadLock()
+	if err != nil {
+		return nil, err
+	}
+	required := d.requiredImports()
+	kept := append(append([]string{}, required...), lock.Imports()...)
+
+	queue := []string{}
+	for _, importPath := range required {
+		if node := d.ImportGraph.Search(importPath); node != nil && node.Dependency != nil && node.Dependency.Scm == "go" {
+			queue = append(queue, importPath)
+		}
+	}
+	// go get fetched what the go deps import, and what that imports
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		importPath := queue[0]
+		queue = queue[1:]
+		if seen[importPath] {
+			continue
+		}
+		seen[importPath] = true
+		kept = append(kept, importPath)
+
+		dir := dependencyPath(importPath)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		p, err := AnalyzeSourceTree(dir)
+		if err != nil {
+			return nil, err
+		}
+		for path, s := range p.ImportStatsByPath {
+			if s.Remote {
+				queue = append(queue, repoRootOf(path))
+			}
+		}
+	}
+	return kept, nil
+}
+
+// The dirs below the vendor src dir that nothing kept lives in, left
+// behind by deps removed from the config. The project's own link or copy
+// is never one of them.
+func (d *Dependencies) PruneCandidates() ([]string, error) {
+	imports, err := d.keptImports()
+	if err != nil {
+		return nil, err
+	}
+
+	src := filepath.Join(pwd, VendorDir, "src")
+	candidates := []string{}
+	filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == src || !info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return nil
+		}
+		importPath := filepath.ToSlash(rel)
+
+		if containsString(imports, importPath) || isProjectCopy(path) {
+			return filepath.SkipDir
+		}
+		for _, i := range imports {
+			if strings.HasPrefix(i, importPath+"/") {
+				return nil
+			}
+		}
+		// look inside the dirs holding the project's link or copy
+		if len(projectLinks(path)) > 0 {
+			return nil
+		}
+		candidates = append(candidates, importPath)
+		return filepath.SkipDir
+	})
+	sort.Strings(candidates)
+	return candidates, nil
+}
+
+// Remove the orphaned deps from the vendor tree, and the dirs they leave
+// empty. The install before dropped them from the lock.
+func prune(deps *Dependencies) error {
+	candidates, err := deps.PruneCandidates()
+	if err != nil {
+		return err
+	}
+	src := filepath.Join(pwd, VendorDir, "src")
+
+	if dryRun {
+		for _, importPath := range candidates {
+			fmtcolor(Gray, "  Would prune: %s\n", filepath.Join(src, importPath))
+		}
+		return nil
+	}
+
+	for _, importPath := range candidates {
+		path := filepath.Join(src, importPath)
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		for dir := filepath.Dir(path); dir != src; dir = filepath.Dir(dir) {
+			// fails on the first dir that isn't empty
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+		fmtcolor(Gray, "       Pruned: %s\n", path)
+	}
+	return nil
+}
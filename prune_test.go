@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func pruneFixture() *Dependencies {
+	setupTestPwd()
+	for _, importPath := range []string{"github.com/gopack-test/prune-kept", "github.com/gopack-test/prune-kept/sub",
+		"github.com/gopack-test/prune-child", "github.com/gopack-test/prune-removed", "bitbucket.org/gopack-test/prune-gone"} {
+		createSourceFixture(dependencyPath(importPath), "main.go", "package main\n")
+	}
+	// linked without being in the graph, a --no-self-link run for instance
+	os.MkdirAll(filepath.Dir(dependencyPath("example.com/gopack-test/prune-app")), 0755)
+	os.Symlink(pwd, dependencyPath("example.com/gopack-test/prune-app"))
+
+	graph := NewGraph()
+	graph.Insert(&Dep{Import: "github.com/gopack-test/prune-kept"})
+	graph.Insert(&Dep{Import: "github.com/gopack-test/prune-child"})
+	return &Dependencies{Imports: []string{"github.com/gopack-test/prune-kept"}, ImportGraph: graph}
+}
+
+func TestPruneCandidates(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	deps := pruneFixture()
+
+	candidates, err := deps.PruneCandidates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if candidates := strings.Join(candidates, " "); candidates != "bitbucket.org github.com/gopack-test/prune-removed" {
+		t.Errorf("Expected the orphaned dirs to be pruned but found %s\n", candidates)
+	}
+}
+
+func TestPruneRemovesOrphansAndKeepsTheProject(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	deps := pruneFixture()
+	// locked by the install before, even though it isn't in the graph
+	createSourceFixture(dependencyPath("github.com/gopack-test/prune-locked"), "main.go", "package main\n")
+	lock := NewLock()
+	for _, importPath := range []string{"github.com/gopack-test/prune-locked", "github.com/gopack-test/prune-kept"} {
+		lock.Deps[importPath] = &LockedDep{Import: importPath}
+	}
+	check(lock.Write())
+
+	dryRun = true
+	out := captureStdout(func() {
+		if err := prune(deps); err != nil {
+			t.Fatal(err)
+		}
+	})
+	dryRun = false
+	if !strings.Contains(out, "Would prune: "+dependencyPath("github.com/gopack-test/prune-removed")) {
+		t.Errorf("Expected --dry-run to list the orphans, printed %s\n", out)
+	}
+	if _, err := os.Stat(dependencyPath("github.com/gopack-test/prune-removed")); err != nil {
+		t.Errorf("Expected --dry-run to remove nothing\n")
+	}
+
+	captureStdout(func() {
+		if err := prune(deps); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	for _, importPath := range []string{"github.com/gopack-test/prune-removed", "bitbucket.org"} {
+		if _, err := os.Stat(dependencyPath(importPath)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be pruned\n", importPath)
+		}
+	}
+	for _, importPath := range []string{"github.com/gopack-test/prune-kept/sub", "github.com/gopack-test/prune-child", "github.com/gopack-test/prune-locked"} {
+		if _, err := os.Stat(dependencyPath(importPath)); err != nil {
+			t.Errorf("Expected %s to be kept, got %v\n", importPath, err)
+		}
+	}
+	if target, err := os.Readlink(dependencyPath("example.com/gopack-test/prune-app")); err != nil || target != pwd {
+		t.Errorf("Expected the link to the project to be kept, got %v\n", err)
+	}
+}
+
+func TestPruneKeepsDepsOfDepsThatWerentFetched(t *testing.T) {
+	defer stubVendorDir(".gopack/vendor")()
+	setupTestPwd()
+	createFixtureConfig(pwd, `
+[deps.pinned]
+  import = "github.com/gopack-test/prune-pinned"
+  tag = "v1.0"
+[deps.goget]
+  import = "github.com/gopack-test/prune-goget"
+  scm = "go"
+`)
+	createSourceFixture(dependencyPath("github.com/gopack-test/prune-pinned"), "gopack.config", `
+[deps.child]
+  import = "github.com/gopack-test/prune-pinned-child"
+  tag = "v1.0"
+`)
+	createSourceFixture(dependencyPath("github.com/gopack-test/prune-pinned-child"), "main.go", "package child\n")
+	createSourceFixture(dependencyPath("github.com/gopack-test/prune-goget"), "main.go", "package goget\n\nimport _ \"github.com/gopack-test/prune-pulled/pkg\"\n")
+	createSourceFixture(dependencyPath("github.com/gopack-test/prune-pulled/pkg"), "pkg.go", "package pkg\n\nimport _ \"github.com/gopack-test/prune-pulled-too\"\n")
+	createSourceFixture(dependencyPath("github.com/gopack-test/prune-pulled-too"), "too.go", "package too\n")
+	createSourceFixture(dependencyPath("github.com/gopack-test/prune-pulled/other"), "other.go", "package other\n")
+	createSourceFixture(dependencyPath("github.com/gopack-test/prune-orphan"), "main.go", "package orphan\n")
+
+	// a second run, nothing changed so the pinned dep isn't fetched and
+	// its deps aren't loaded
+	check(loadTestConfig(pwd).WriteChecksum())
+	deps, err := loadTestConfig(pwd).LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deps.DepList[0].fetch {
+		t.Fatalf("Expected the pinned dep not to be fetched while the config is unchanged\n")
+	}
+
+	candidates, err := deps.PruneCandidates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if candidates := strings.Join(candidates, " "); candidates != "github.com/gopack-test/prune-orphan" {
+		t.Errorf("Expected only the orphan to be pruned, found %s\n", candidates)
+	}
+}
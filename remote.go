@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GopackRemoteCache = ".gopack/remote"
+	DefaultRemoteTTL  = 10 * time.Minute
+)
+
+var (
+	// skip the on-disk ls-remote cache, set with --refresh-remote
+	refreshRemote = false
+	timeNow       = time.Now
+
+	// every listing made during this run, by cache path
+	runRemoteRefs = make(map[string][]RemoteRef)
+	// a lock per cache path, a remote is listed once while others are
+	// listed alongside it
+	runRemoteLocks  = make(map[string]*sync.Mutex)
+	runRemoteRefsMu sync.Mutex
+)
+
+type RemoteRef struct {
+	Name string
+	Hash string
+}
+
+type remoteCacheEntry struct {
+	// without its credentials, the path is the hash of the source
+	Source  string
+	Fetched time.Time
+	Refs    []RemoteRef
+}
+
+// List the refs of a remote repository, reusing a cached listing
+// when it's younger than GOPACK_REMOTE_CACHE_TTL. A remote is only listed
+// once per run, --refresh-remote included, however many deps ask.
+func LsRemote(source string) ([]RemoteRef, error) {
+	path := remoteCachePath(source)
+
+	lock := remoteLock(path)
+	lock.Lock()
+	defer lock.Unlock()
+	if refs, ok := listedRefs(path); ok {
+		return refs, nil
+	}
+
+	if !refreshRemote {
+		if entry := readRemoteCache(path); entry != nil && timeNow().Sub(entry.Fetched) < remoteCacheTTL() {
+			recordRefs(path, entry.Refs)
+			return entry.Refs, nil
+		}
+	}
+
+	refs, err := lsRemote(source)
+	if err != nil {
+		return nil, err
+	}
+
+	writeRemoteCache(path, &remoteCacheEntry{maskCredentials(source), timeNow(), refs})
+	recordRefs(path, refs)
+	return refs, nil
+}
+
+func remoteLock(path string) *sync.Mutex {
+	runRemoteRefsMu.Lock()
+	defer runRemoteRefsMu.Unlock()
+	lock, ok := runRemoteLocks[path]
+	if !ok {
+		lock = new(sync.Mutex)
+		runRemoteLocks[path] = lock
+	}
+	return lock
+}
+
+func listedRefs(path string) ([]RemoteRef, bool) {
+	runRemoteRefsMu.Lock()
+	defer runRemoteRefsMu.Unlock()
+	refs, ok := runRemoteRefs[path]
+	return refs, ok
+}
+
+func recordRefs(path string, refs []RemoteRef) {
+	runRemoteRefsMu.Lock()
+	runRemoteRefs[path] = refs
+	runRemoteRefsMu.Unlock()
+}
+
+func lsRemote(source string) ([]RemoteRef, error) {
+	out, err := runFetch(scmCommand("git", "ls-remote", source))
+	if err != nil {
+		return nil, fmt.Errorf("Error listing remote refs for %s: %s", maskCredentials(source), err)
+	}
+	return parseLsRemote(out), nil
+}
+
+func parseLsRemote(out []byte) []RemoteRef {
+	refs := []RemoteRef{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			refs = append(refs, RemoteRef{Name: fields[1], Hash: fields[0]})
+		}
+	}
+	return refs
+}
+
+// The TTL is either a go duration ("90s", "1h") or a number of seconds.
+func remoteCacheTTL() time.Duration {
+	ttl := os.Getenv("GOPACK_REMOTE_CACHE_TTL")
+	if ttl == "" {
+		return DefaultRemoteTTL
+	}
+	if d, err := time.ParseDuration(ttl); err == nil {
+		return d
+	}
+	if secs, err := strconv.Atoi(ttl); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	fmtcolor(Yellow, "      Warning: invalid GOPACK_REMOTE_CACHE_TTL `%s`, using %s\n", ttl, DefaultRemoteTTL)
+	return DefaultRemoteTTL
+}
+
+func remoteCachePath(source string) string {
+	h := sha1.New()
+	h.Write([]byte(source))
+	return filepath.Join(pwd, GopackRemoteCache, hex.EncodeToString(h.Sum(nil)))
+}
+
+func readRemoteCache(path string) *remoteCacheEntry {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	entry := new(remoteCacheEntry)
+	if err := json.Unmarshal(dat, entry); err != nil {
+		return nil
+	}
+	return entry
+}
+
+// The cache is only an optimization so failing to write it is not an error.
+func writeRemoteCache(path string, entry *remoteCacheEntry) {
+	dat, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	ioutil.WriteFile(path, dat, 0644)
+}
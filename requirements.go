@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+const GopackDeps = "gopack.deps"
+
+// Load a flat requirements file with one "import [branch]" or
+// "import branch|tag|commit ref" per line, # starts a comment. The deps
+// are loaded as an include so gopack.config takes precedence.
+func LoadRequirements(dir string) (*Include, error) {
+	path := filepath.Join(dir, GopackDeps)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	deps, err := parseRequirements(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", path, err)
+	}
+	return &Include{Path: path, DepsTree: deps}, nil
+}
+
+func parseRequirements(data string) (*toml.TomlTree, error) {
+	deps, _ := toml.Load("")
+	lines := make(map[string]int)
+
+	for i, line := range strings.Split(data, "\n") {
+		if c := strings.Index(line, "#"); c >= 0 {
+			line = line[:c]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		importPath, kind, ref := fields[0], "branch", ""
+		switch len(fields) {
+		case 1:
+		case 2:
+			ref = fields[1]
+		case 3:
+			kind, ref = fields[1], fields[2]
+			if kind != "branch" && kind != "tag" && kind != "commit" {
+				return nil, fmt.Errorf("line %d - expected branch, tag or commit, got %q", i+1, kind)
+			}
+		default:
+			return nil, fmt.Errorf("line %d - expected an import and a branch or tag, got %q", i+1, strings.TrimSpace(line))
+		}
+
+		if previous, ok := lines[importPath]; ok {
+			return nil, fmt.Errorf("line %d - %s is already required on line %d", i+1, importPath, previous)
+		}
+		lines[importPath] = i + 1
+
+		dep, _ := toml.Load("")
+		dep.Set("import", importPath)
+		if ref != "" {
+			dep.Set(kind, ref)
+		}
+		deps.Set(requirementKey(deps, importPath), dep)
+	}
+	return deps, nil
+}
+
+// Toml keys are dotted paths, so the import can't be used as is. Imports
+// differing only in a . or / map to the same key, a taken key is numbered.
+func requirementKey(deps *toml.TomlTree, importPath string) string {
+	base := strings.NewReplacer(".", "_", "/", "_").Replace(importPath)
+	key := base
+	for i := 2; deps.Get(key) != nil; i++ {
+		key = fmt.Sprintf("%s_%d", base, i)
+	}
+	return key
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The deps as "import checkout" lines, sorted so the order keys come
+// back in doesn't matter.
+func describeDeps(t *testing.T, config *Config) string {
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{}
+	for _, dep := range deps.DepList {
+		lines = append(lines, dep.Import+" "+dep.Checkout())
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func TestRequirementsMatchTheTomlForm(t *testing.T) {
+	config := setupTestConfig(`
+[deps.mux]
+  import = "github.com/gopack-test/mux"
+[deps.context]
+  import = "github.com/gopack-test/context"
+  branch = "master"
+[deps.toml]
+  import = "github.com/gopack-test/go-toml"
+  tag = "v0.2.0"
+[deps.pinned]
+  import = "github.com/gopack-test/pinned"
+  commit = "182cae2ee3926a960223d8db4998aa9d57c89788"
+`)
+	expected := describeDeps(t, config)
+
+	setupTestPwd()
+	createSourceFixture(pwd, GopackDeps, `# generated by the release scripts
+github.com/gopack-test/mux
+
+github.com/gopack-test/context master
+github.com/gopack-test/go-toml tag v0.2.0   # the last compatible release
+github.com/gopack-test/pinned commit 182cae2ee3926a960223d8db4998aa9d57c89788
+`)
+	if actual := describeDeps(t, loadTestConfig(pwd)); actual != expected {
+		t.Errorf("Expected gopack.deps to load\n%s\ngot\n%s\n", expected, actual)
+	}
+}
+
+func TestTomlTakesPrecedenceOverRequirements(t *testing.T) {
+	config := setupTestConfig(`
+[deps.context]
+  import = "github.com/gopack-test/context"
+  tag = "v1.1"
+`)
+	createSourceFixture(pwd, GopackDeps, `
+github.com/gopack-test/context master
+github.com/gopack-test/extra v2
+`)
+	config = loadTestConfig(pwd)
+
+	expected := "github.com/gopack-test/context tag v1.1\ngithub.com/gopack-test/extra branch v2"
+	if actual := describeDeps(t, config); actual != expected {
+		t.Errorf("Expected the merged deps\n%s\ngot\n%s\n", expected, actual)
+	}
+}
+
+func TestMalformedRequirementsReportTheLine(t *testing.T) {
+	for data, expected := range map[string]string{
+		"github.com/gopack-test/a\n\ngithub.com/gopack-test/b tag v1 extra\n": "line 3 - expected an import",
+		"# deps\ngithub.com/gopack-test/a revision 1234\n":                    "line 2 - expected branch, tag or commit",
+		"github.com/gopack-test/a master\ngithub.com/gopack-test/a tag v1\n":  "line 2 - github.com/gopack-test/a is already required on line 1",
+	} {
+		_, err := parseRequirements(data)
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("Expected %q, got %v\n", expected, err)
+		}
+	}
+}
+
+func TestRequirementsDifferingInASeparatorAreKept(t *testing.T) {
+	deps, err := parseRequirements("github.com/gopack-test/sep.pkg\ngithub.com/gopack-test/sep/pkg\ngithub.com/gopack-test/sep_pkg_2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := []string{}
+	for _, k := range deps.Keys() {
+		imports = append(imports, deps.Get(k+".import").(string))
+	}
+	sort.Strings(imports)
+	if actual := strings.Join(imports, " "); actual != "github.com/gopack-test/sep.pkg github.com/gopack-test/sep/pkg github.com/gopack-test/sep_pkg_2" {
+		t.Errorf("Expected every import to get its own key, found %s\n", actual)
+	}
+}
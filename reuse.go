@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+var (
+	// another gopack project to copy vendored deps from, set with --from
+	vendorFrom = ""
+	reuseFrom  *VendorSource
+)
+
+// The vendor tree of another gopack project and the lock describing it.
+type VendorSource struct {
+	Dir  string
+	Lock *Lock
+	// the vendor dir of the other project, relative to Dir
+	Vendor string
+}
+
+func OpenVendorSource(dir string) (*VendorSource, error) {
+	lock, err := readLockFile(filepath.Join(dir, GopackLock))
+	if err != nil {
+		return nil, err
+	}
+	if len(lock.Deps) == 0 {
+		return nil, fmt.Errorf("%s has no %s to copy dependencies from", dir, GopackLock)
+	}
+	vendor, err := sourceVendorDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &VendorSource{dir, lock, vendor}, nil
+}
+
+// The other project's vendor key is read from its own gopack.config,
+// --config only applies to this project.
+func sourceVendorDir(dir string) (string, error) {
+	path := filepath.Join(dir, "gopack.config")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return filepath.Join(GopackDir, "vendor"), nil
+	}
+	t, err := toml.LoadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	vendor, err := vendorDirOf(t, dir, dir)
+	if err != nil {
+		return "", fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	if vendor == "" {
+		vendor = filepath.Join(GopackDir, "vendor")
+	}
+	return vendor, nil
+}
+
+func (v *VendorSource) src(importPath string) string {
+	return filepath.Join(v.Dir, v.Vendor, "src", importPath)
+}
+
+// Whether the other project vendors the dep the way this config asks for
+// and its copy still matches the hash in its lock.
+func (v *VendorSource) Matches(d *Dep) bool {
+	locked := v.Lock.Deps[d.Import]
+	if locked == nil {
+		return false
+	}
+
+	if d.CheckoutFlag == CommitFlag {
+		if locked.Revision == "" || !strings.HasPrefix(locked.Revision, d.CheckoutSpec) {
+			return false
+		}
+	} else if locked.Checkout != d.Checkout() {
+		return false
+	}
+
+	hash, err := contentHash(v.src(d.Import))
+	return err == nil && hash == locked.Hash
+}
+
+// Copy the dep into this vendor tree, returning false when it has to be
+// fetched instead.
+func (v *VendorSource) Copy(d *Dep) (bool, error) {
+	if !v.Matches(d) {
+		return false, nil
+	}
+
+	if err := os.RemoveAll(d.Src()); err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.Src()), 0755); err != nil {
+		return false, err
+	}
+	if err := copyDir(v.src(d.Import), d.Src()); err != nil {
+		return false, fmt.Errorf("Error copying %s from %s: %s", d.Import, v.Dir, err)
+	}
+
+	d.Revision = v.Lock.Deps[d.Import].Revision
+	return true, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func createVendorSource(t *testing.T, locked ...*LockedDep) string {
+	dir, _ := ioutil.TempDir("", "gopack-source-")
+	lock := NewLock()
+
+	for _, l := range locked {
+		src := path.Join(dir, GopackDir, "vendor", "src", l.Import)
+		createPath(path.Join(src, HiddenGit))
+		createSourceFixture(src, "main.go", "package main\n")
+
+		hash, err := contentHash(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Hash = hash
+		lock.Deps[l.Import] = l
+	}
+
+	createPath(path.Join(dir, GopackDir))
+	check(ioutil.WriteFile(path.Join(dir, GopackLock), lock.Bytes(), 0644))
+	return dir
+}
+
+func gitDep(importPath, checkoutType, spec string) *Dep {
+	dep := &Dep{Import: importPath, Scm: GitTag, Source: "https://" + importPath + ".git", fetch: true, Transitive: TransitiveFull}
+	switch checkoutType {
+	case "branch":
+		dep.CheckoutFlag = BranchFlag
+	case "tag":
+		dep.CheckoutFlag = TagFlag
+	case "commit":
+		dep.CheckoutFlag = CommitFlag
+	}
+	dep.CheckoutSpec = spec
+	return dep
+}
+
+// Stand-ins for the scm commands, keyed by subcommand.
+type commandStubs map[string]func(cmd *exec.Cmd) ([]byte, error)
+
+// Stub runCommand, dispatching on the subcommand in cmd.Args[1]. A
+// command without a stub runs the stub for "*" when there is one,
+// otherwise it succeeds without output, except a clone which checks out
+// an empty git repository.
+func stubCommands(stubs commandStubs) func() {
+	return recordCommands(nil, stubs)
+}
+
+// Stub the commands like stubCommands, recording every command run.
+func recordCommands(ran *[]string, stubs commandStubs) func() {
+	orig := runCommand
+	runCommand = func(cmd *exec.Cmd) ([]byte, error) {
+		if ran != nil {
+			*ran = append(*ran, strings.Join(cmd.Args, " "))
+		}
+		subcommand := ""
+		if len(cmd.Args) > 1 {
+			subcommand = cmd.Args[1]
+		}
+		if stub, ok := stubs[subcommand]; ok {
+			return stub(cmd)
+		}
+		if stub, ok := stubs["*"]; ok {
+			return stub(cmd)
+		}
+		if subcommand == "clone" {
+			return cloneCheckout(cmd)
+		}
+		return nil, nil
+	}
+	return func() { runCommand = orig }
+}
+
+// A command that succeeds without output, a clone without a checkout.
+func succeed(cmd *exec.Cmd) ([]byte, error) {
+	return nil, nil
+}
+
+// Create the checkout a git clone of cmd.Args[2] into cmd.Args[3] would.
+func cloneCheckout(cmd *exec.Cmd) ([]byte, error) {
+	if len(cmd.Args) < 4 {
+		return nil, fmt.Errorf("expected a clone source and target, ran %v", cmd.Args)
+	}
+	createPath(path.Join(cmd.Args[3], HiddenGit))
+	return nil, nil
+}
+
+func stubGit(cloned *[]string) func() {
+	return stubCommands(commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			*cloned = append(*cloned, cmd.Args[2])
+			return cloneCheckout(cmd)
+		},
+		"rev-parse": func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte("182cae2ee3926a960223d8db4998aa9d57c89788\n"), nil
+		},
+	})
+}
+
+func TestCopyFromVendorSource(t *testing.T) {
+	setupTestPwd()
+
+	dir := createVendorSource(t,
+		&LockedDep{Import: "github.com/gopack-test/shared", Checkout: "branch master", Revision: "abc123"},
+		&LockedDep{Import: "github.com/gopack-test/pinned", Checkout: "commit 182cae2", Revision: "182cae2ee3926a960223d8db4998aa9d57c89788"},
+		&LockedDep{Import: "github.com/gopack-test/stale", Checkout: "tag v1.0", Revision: "def456"})
+
+	source, err := OpenVendorSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reuseFrom = source
+	defer func() { reuseFrom = nil }()
+
+	graph := NewGraph()
+	deps := &Dependencies{ImportGraph: graph}
+	for _, dep := range []*Dep{
+		gitDep("github.com/gopack-test/shared", "branch", "master"),
+		gitDep("github.com/gopack-test/pinned", "commit", "182cae2"),
+		gitDep("github.com/gopack-test/stale", "tag", "v2.0"),
+		gitDep("github.com/gopack-test/missing", "branch", "master"),
+	} {
+		deps.DepList = append(deps.DepList, dep)
+		graph.Insert(dep)
+	}
+
+	cloned := []string{}
+	defer stubGit(&cloned)()
+
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cloned) != 2 || cloned[0] != "https://github.com/gopack-test/stale.git" || cloned[1] != "https://github.com/gopack-test/missing.git" {
+		t.Errorf("Expected only the stale and missing deps to be cloned, cloned %v\n", cloned)
+	}
+
+	for _, dep := range deps.DepList[:2] {
+		if _, err := os.Stat(path.Join(dep.Src(), "main.go")); err != nil {
+			t.Errorf("Expected %s to be copied into %s\n", dep.Import, dep.Src())
+		}
+	}
+
+	if deps.DepList[0].Revision != "abc123" {
+		t.Errorf("Expected the copied dep to keep the revision of the source lock, was %s\n", deps.DepList[0].Revision)
+	}
+}
+
+func TestVendorSourceRejectsModifiedCopy(t *testing.T) {
+	setupTestPwd()
+
+	dir := createVendorSource(t, &LockedDep{Import: "github.com/gopack-test/shared", Checkout: "branch master"})
+	createSourceFixture(path.Join(dir, GopackDir, "vendor", "src", "github.com/gopack-test/shared"), "main.go", "package patched\n")
+
+	source, err := OpenVendorSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if source.Matches(gitDep("github.com/gopack-test/shared", "branch", "master")) {
+		t.Error("Expected a copy that no longer matches its lock to be fetched instead")
+	}
+}
+
+func TestVendorSourceUsesItsConfiguredVendorDir(t *testing.T) {
+	setupTestPwd()
+
+	dir, _ := ioutil.TempDir("", "gopack-source-")
+	createFixtureConfig(dir, "vendor = \"_vendor\"\n")
+	src := path.Join(dir, "_vendor", "src", "github.com/gopack-test/shared")
+	createSourceFixture(src, "main.go", "package main\n")
+	hash, err := contentHash(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := NewLock()
+	lock.Deps["github.com/gopack-test/shared"] = &LockedDep{Import: "github.com/gopack-test/shared", Checkout: "branch master", Hash: hash}
+	check(lock.writeFile(path.Join(dir, GopackLock)))
+
+	source, err := OpenVendorSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !source.Matches(gitDep("github.com/gopack-test/shared", "branch", "master")) {
+		t.Errorf("Expected the dep to be found in the vendor dir of the other project's config\n")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// Redirects the sources starting with Prefix, or matching Regexp, to To.
+type Rewrite struct {
+	Prefix string
+	Regexp *regexp.Regexp
+	To     string
+}
+
+func (r *Rewrite) Apply(source string) (string, bool) {
+	if r.Regexp != nil {
+		if !r.Regexp.MatchString(source) {
+			return source, false
+		}
+		return r.Regexp.ReplaceAllString(source, r.To), true
+	}
+	if !strings.HasPrefix(source, r.Prefix) {
+		return source, false
+	}
+	return r.To + strings.TrimPrefix(source, r.Prefix), true
+}
+
+// Read the [rewrites.name] rules in the order they are declared.
+func (c *Config) loadRewrites() ([]*Rewrite, error) {
+	rewrites := []*Rewrite{}
+	if c.RewritesTree == nil {
+		return rewrites, nil
+	}
+	for _, name := range orderedKeys(c.RewritesTree, c.rewriteOrder) {
+		tree, ok := c.RewritesTree.Get(name).(*toml.TomlTree)
+		if !ok {
+			return nil, fmt.Errorf("rewrites.%s - expected a table", name)
+		}
+		r := &Rewrite{}
+		r.Prefix, _ = tree.Get("prefix").(string)
+		r.To, _ = tree.Get("to").(string)
+		pattern, _ := tree.Get("regex").(string)
+
+		switch {
+		case r.Prefix == "" && pattern == "":
+			return nil, fmt.Errorf("rewrites.%s - expected a prefix or a regex", name)
+		case r.Prefix != "" && pattern != "":
+			return nil, fmt.Errorf("rewrites.%s - only one of prefix/regex may be specified", name)
+		case r.To == "":
+			return nil, fmt.Errorf("rewrites.%s - missing to", name)
+		}
+
+		if pattern != "" {
+			var err error
+			if r.Regexp, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("rewrites.%s - invalid regex: %s", name, err)
+			}
+		}
+		rewrites = append(rewrites, r)
+	}
+	return rewrites, nil
+}
+
+// Follow the rules until none matches the source anymore, so a source
+// rewritten to a fork that is itself rewritten ends up at the last
+// fork. The first rule matching wins, and every rule applies at most
+// once so a rule whose target it matches again doesn't loop forever.
+func rewriteSource(rewrites []*Rewrite, source string) (string, error) {
+	chain := []string{source}
+	used := make([]bool, len(rewrites))
+	for {
+		rewritten := source
+		for i, r := range rewrites {
+			if used[i] {
+				continue
+			}
+			if s, ok := r.Apply(source); ok {
+				used[i] = true
+				if s != source {
+					rewritten = s
+					break
+				}
+			}
+		}
+		if rewritten == source {
+			return source, nil
+		}
+
+		chain = append(chain, rewritten)
+		if containsString(chain[:len(chain)-1], rewritten) {
+			return "", fmt.Errorf("Rewrites of %s form a cycle: %s", maskCredentials(chain[0]), maskURLCredentials(strings.Join(chain, " -> ")))
+		}
+		source = rewritten
+	}
+}
+
+// Point the sources and mirrors of every dep at where the rules say
+// they are fetched from.
+func (d *Dependencies) applyRewrites() error {
+	if len(d.Rewrites) == 0 {
+		return nil
+	}
+	for _, dep := range d.DepList {
+		if dep.Source != "" && dep.ScmURL == "" {
+			source, err := dep.rewrite(d.Rewrites, dep.Source)
+			if err != nil {
+				return err
+			}
+			dep.Source = source
+		}
+		for i, mirror := range dep.Mirrors {
+			source, err := dep.rewrite(d.Rewrites, mirror)
+			if err != nil {
+				return err
+			}
+			dep.Mirrors[i] = source
+		}
+	}
+	return nil
+}
+
+func (d *Dep) rewrite(rewrites []*Rewrite, source string) (string, error) {
+	rewritten, err := rewriteSource(rewrites, source)
+	if err != nil {
+		return "", err
+	}
+	if rewritten != source {
+		fmtcolor(Gray, "    Rewritten: `%s` from %s to %s\n", d.Import, maskCredentials(source), maskCredentials(rewritten))
+	}
+	return rewritten, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPrefixRewriteRedirectsMatchingDeps(t *testing.T) {
+	config := setupTestConfig(`
+[deps.app]
+  import = "github.com/gopack-test/rewrite-app"
+  scm = "git"
+  source = "https://github.com/gopack-test/rewrite-app.git"
+
+[deps.other]
+  import = "bitbucket.org/gopack-test/rewrite-other"
+  scm = "git"
+  source = "https://bitbucket.org/gopack-test/rewrite-other.git"
+
+[rewrites.github]
+  prefix = "https://github.com/"
+  to = "https://git.internal/mirror/github.com/"
+`)
+
+	ran := []string{}
+	defer stubGitWithConfigs(&ran, map[string]string{
+		"https://git.internal/mirror/github.com/gopack-test/rewrite-app.git": `
+[deps.lib]
+  import = "github.com/gopack-test/rewrite-lib"
+  scm = "git"
+  source = "https://github.com/gopack-test/rewrite-lib.git"
+`,
+	})()
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"https://git.internal/mirror/github.com/gopack-test/rewrite-app.git",
+		"https://bitbucket.org/gopack-test/rewrite-other.git",
+		"https://git.internal/mirror/github.com/gopack-test/rewrite-lib.git",
+	} {
+		if !containsString(ran, expected) {
+			t.Errorf("Expected %s to be cloned, ran %v\n", expected, ran)
+		}
+	}
+}
+
+func TestFirstMatchingRewriteWins(t *testing.T) {
+	config := setupTestConfig(`
+[rewrites.regex]
+  regex = "^https://github.com/gopack-test/(\\w+)$"
+  to = "https://git.internal/gopack-test-$1.git"
+
+[rewrites.github]
+  prefix = "https://github.com/"
+  to = "https://git.internal/mirror/github.com/"
+`)
+
+	rewrites, err := config.loadRewrites()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for source, expected := range map[string]string{
+		"https://github.com/gopack-test/first":     "https://git.internal/gopack-test-first.git",
+		"https://github.com/gopack-test/first.git": "https://git.internal/mirror/github.com/gopack-test/first.git",
+		"git@github.com:gopack-test/first.git":     "git@github.com:gopack-test/first.git",
+	} {
+		if actual, _ := rewriteSource(rewrites, source); actual != expected {
+			t.Errorf("Expected %s to be rewritten to %s, got %s\n", source, expected, actual)
+		}
+	}
+}
+
+func TestInvalidRewrites(t *testing.T) {
+	for fixture, expected := range map[string]string{
+		"[rewrites.broken]\n  to = \"https://git.internal/\"\n":                                    "rewrites.broken - expected a prefix or a regex",
+		"[rewrites.broken]\n  prefix = \"https://github.com/\"\n":                                  "rewrites.broken - missing to",
+		"[rewrites.broken]\n  regex = \"(\"\n  to = \"https://git.internal/\"\n":                   "rewrites.broken - invalid regex",
+		"[rewrites.broken]\n  prefix = \"a\"\n  regex = \"b\"\n  to = \"https://git.internal/\"\n": "rewrites.broken - only one of prefix/regex",
+	} {
+		_, err := setupTestConfig(fixture).LoadDependencyModel(NewGraph())
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("Expected %q, got %v\n", expected, err)
+		}
+	}
+
+	createFixtureConfig(pwd, "[[rewrites]]\n  prefix = \"https://github.com/\"\n  to = \"https://git.internal/\"\n")
+	if _, err := NewConfig(pwd); err == nil || !strings.Contains(err.Error(), "[[rewrites]] entries aren't supported") {
+		t.Errorf("Expected [[rewrites]] to be rejected rather than ignored, got %v\n", err)
+	}
+}
+
+func TestRewritesFollowChains(t *testing.T) {
+	config := setupTestConfig(`
+[deps.layered]
+  import = "github.com/gopack-test/layered"
+  scm = "git"
+  source = "https://github.com/gopack-test/layered.git"
+
+[rewrites.fork]
+  prefix = "https://github.com/gopack-test/layered-fork.git"
+  to = "https://git.internal/gopack-test/layered-patched.git"
+
+[rewrites.layered]
+  prefix = "https://github.com/gopack-test/layered.git"
+  to = "https://github.com/gopack-test/layered-fork.git"
+`)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "https://git.internal/gopack-test/layered-patched.git"
+	if source := deps.DepList[0].Source; source != expected {
+		t.Errorf("Expected the source to be rewritten to %s, got %s\n", expected, source)
+	}
+}
+
+func TestRewriteCyclesAreRejected(t *testing.T) {
+	config := setupTestConfig(`
+[deps.cyclic]
+  import = "github.com/gopack-test/cyclic"
+  scm = "git"
+  source = "https://github.com/gopack-test/cyclic.git"
+
+[rewrites.cyclic]
+  prefix = "https://github.com/gopack-test/cyclic.git"
+  to = "https://github.com/gopack-test/cyclic-fork.git"
+
+[rewrites.fork]
+  prefix = "https://github.com/gopack-test/cyclic-fork.git"
+  to = "https://github.com/gopack-test/cyclic.git"
+`)
+
+	_, err := config.LoadDependencyModel(NewGraph())
+	expected := "Rewrites of https://github.com/gopack-test/cyclic.git form a cycle: " +
+		"https://github.com/gopack-test/cyclic.git -> https://github.com/gopack-test/cyclic-fork.git -> https://github.com/gopack-test/cyclic.git"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v\n", expected, err)
+	}
+}
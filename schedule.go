@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// The order deps are fetched in: the highest priority first, then the
+// ones with the biggest known subtree so the longest fetches start
+// early, otherwise the order of the config.
+func (d *Dependencies) Schedule() []*Dep {
+	scheduled := make([]*Dep, len(d.DepList))
+	copy(scheduled, d.DepList)
+
+	subtrees := make(map[*Dep]int)
+	for _, dep := range scheduled {
+		subtrees[dep] = dep.knownSubtree(d.ImportGraph)
+	}
+
+	sort.Stable(byPriority{scheduled, subtrees})
+	return scheduled
+}
+
+type byPriority struct {
+	deps     []*Dep
+	subtrees map[*Dep]int
+}
+
+func (s byPriority) Len() int      { return len(s.deps) }
+func (s byPriority) Swap(i, j int) { s.deps[i], s.deps[j] = s.deps[j], s.deps[i] }
+func (s byPriority) Less(i, j int) bool {
+	a, b := s.deps[i], s.deps[j]
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return s.subtrees[a] > s.subtrees[b]
+}
+
+// How many deps are known to be below this one, from the edges loaded
+// so far or else from the configs in the vendor tree of a previous run.
+func (d *Dep) knownSubtree(graph *Graph) int {
+	seen := make(map[string]bool)
+
+	var visit func(importPath string)
+	visit = func(importPath string) {
+		for _, child := range knownChildren(graph, importPath) {
+			if !seen[child] {
+				seen[child] = true
+				visit(child)
+			}
+		}
+	}
+	visit(d.Import)
+	delete(seen, d.Import)
+	return len(seen)
+}
+
+func knownChildren(graph *Graph, importPath string) []string {
+	if children, ok := graph.Edges[importPath]; ok {
+		return children
+	}
+
+	t, err := toml.LoadFile(filepath.Join(NewDependency(importPath).Src(), "gopack.config"))
+	if err != nil {
+		return nil
+	}
+	children := []string{}
+	if deps, _, err := loadDepsSection(t, "deps", nil); err == nil && deps != nil {
+		for _, k := range deps.Keys() {
+			if depTree, ok := deps.Get(k).(*toml.TomlTree); ok {
+				if child, ok := depTree.Get("import").(string); ok {
+					children = append(children, child)
+				}
+			}
+		}
+	}
+	return children
+}
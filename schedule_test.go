@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func scheduledImports(deps *Dependencies) string {
+	imports := []string{}
+	for _, dep := range deps.Schedule() {
+		imports = append(imports, strings.TrimPrefix(dep.Import, "github.com/gopack-test/"))
+	}
+	return strings.Join(imports, " ")
+}
+
+func TestScheduleByPriority(t *testing.T) {
+	setupTestPwd()
+
+	low := gitDep("github.com/gopack-test/low", "", "")
+	low.Priority = -1
+	plain := gitDep("github.com/gopack-test/plain", "", "")
+	high := gitDep("github.com/gopack-test/high", "", "")
+	high.Priority = 10
+
+	deps := &Dependencies{ImportGraph: NewGraph(), DepList: []*Dep{low, plain, high}}
+	if order := scheduledImports(deps); order != "high plain low" {
+		t.Errorf("Expected the higher priority deps first, scheduled %s\n", order)
+	}
+}
+
+func TestScheduleLargerSubtreesFirst(t *testing.T) {
+	setupTestPwd()
+
+	small := gitDep("github.com/gopack-test/small", "", "")
+	// a subtree known from a previous run's vendor tree
+	large := vendoredGitDep("github.com/gopack-test/large", "", "")
+	createFixtureConfig(large.Src(), `
+[deps.a]
+  import = "github.com/gopack-test/large-a"
+[deps.b]
+  import = "github.com/gopack-test/large-b"
+`)
+	vendoredGitDep("github.com/gopack-test/large-a", "", "")
+	createFixtureConfig(NewDependency("github.com/gopack-test/large-a").Src(), `
+[deps.c]
+  import = "github.com/gopack-test/large-c"
+`)
+	// and one known from the edges loaded so far
+	medium := gitDep("github.com/gopack-test/medium", "", "")
+	graph := NewGraph()
+	graph.AddEdge(medium.Import, "github.com/gopack-test/medium-a")
+	graph.AddEdge(medium.Import, "github.com/gopack-test/medium-b")
+
+	deps := &Dependencies{ImportGraph: graph, DepList: []*Dep{small, medium, large}}
+	if order := scheduledImports(deps); order != "large medium small" {
+		t.Errorf("Expected the deps with larger subtrees first, scheduled %s\n", order)
+	}
+
+	large.Priority = -1
+	if order := scheduledImports(deps); order != "medium small large" {
+		t.Errorf("Expected the priority to come before the subtree, scheduled %s\n", order)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	// print how long deps waited and fetched, set with --show-scheduling
+	showScheduling = false
+	scheduling     *SchedulingReport
+)
+
+type DepTiming struct {
+	Import string
+	// from the start of the install until the dep's turn came
+	Queued time.Duration
+	// the part of it spent waiting for a slot of the dep's host
+	Waiting time.Duration
+	// fetching and checking out the dep
+	Fetching time.Duration
+}
+
+type SchedulingReport struct {
+	sync.Mutex
+	Start   time.Time
+	Timings []*DepTiming
+	// the downloads made ahead of the deps' turn with -j
+	prefetched map[string]*DepTiming
+}
+
+func NewSchedulingReport() *SchedulingReport {
+	return &SchedulingReport{Start: timeNow(), prefetched: make(map[string]*DepTiming)}
+}
+
+// Time updating a dep, a nil report just updates it. A dep downloaded
+// ahead of its turn was fetching from the start of the download.
+func (r *SchedulingReport) Time(importPath string, update func()) {
+	if r == nil {
+		update()
+		return
+	}
+	started := timeNow()
+	update()
+	timing := &DepTiming{Import: importPath, Queued: started.Sub(r.Start), Fetching: timeNow().Sub(started)}
+	r.Lock()
+	if prefetched := r.prefetched[importPath]; prefetched != nil {
+		timing.Queued = prefetched.Queued
+		timing.Waiting = prefetched.Waiting
+		timing.Fetching += prefetched.Fetching
+	}
+	r.Timings = append(r.Timings, timing)
+	r.Unlock()
+}
+
+// Time downloading a dep ahead of its turn after waiting for a slot of
+// its host, a nil report just downloads it.
+func (r *SchedulingReport) Prefetch(importPath string, waited time.Duration, download func()) {
+	if r == nil {
+		download()
+		return
+	}
+	started := timeNow()
+	download()
+	r.Lock()
+	r.prefetched[importPath] = &DepTiming{importPath, started.Sub(r.Start), waited, timeNow().Sub(started)}
+	r.Unlock()
+}
+
+// The deps that took longest to fetch first.
+func (r *SchedulingReport) Print(w io.Writer) {
+	timings := append([]*DepTiming{}, r.Timings...)
+	sort.Stable(byFetching(timings))
+
+	var fetching, waiting time.Duration
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "dep\tqueued\twaiting for host\tfetching")
+	for _, t := range timings {
+		fetching += t.Fetching
+		waiting += t.Waiting
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", t.Import, t.Queued, t.Waiting, t.Fetching)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d deps fetched in %s, %s waiting for a host, %s in total\n", len(timings), fetching, waiting, timeNow().Sub(r.Start))
+}
+
+type byFetching []*DepTiming
+
+func (s byFetching) Len() int           { return len(s) }
+func (s byFetching) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byFetching) Less(i, j int) bool { return s[i].Fetching > s[j].Fetching }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulingReportRecordsQueueWait(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	// every clock reading is a second later, every clone takes 10 more
+	now := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	defer func() { timeNow = time.Now }()
+
+	defer stubCommands(commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			now = now.Add(10 * time.Second)
+			return cloneCheckout(cmd)
+		},
+		"rev-parse": revParseLocked,
+	})()
+
+	scheduling = NewSchedulingReport()
+	defer func() { scheduling = nil }()
+
+	deps := checkpointedDeps("github.com/gopack-test/scheduled-a", "github.com/gopack-test/scheduled-b")
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	timings := scheduling.Timings
+	if len(timings) != 2 {
+		t.Fatalf("Expected both deps to be timed, found %d\n", len(timings))
+	}
+	if timings[0].Queued != time.Second || timings[0].Fetching < 10*time.Second {
+		t.Errorf("Expected the first dep to be fetched straight away, timed %+v\n", timings[0])
+	}
+	// the second dep waits for the first to be fetched
+	if timings[1].Queued <= timings[0].Queued+timings[0].Fetching {
+		t.Errorf("Expected the second dep to be queued while the first was fetched, timed %+v\n", timings[1])
+	}
+
+	var out bytes.Buffer
+	scheduling.Print(&out)
+	if !strings.HasPrefix(out.String(), "dep ") || !strings.Contains(out.String(), "2 deps fetched in") {
+		t.Errorf("Expected a scheduling report, printed\n%s\n", out.String())
+	}
+}
+
+func TestSchedulingReportCountsPrefetching(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	fetchJobs = 2
+	defer func() { fetchJobs = 1 }()
+
+	var mu sync.Mutex
+	now := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	defer func() { timeNow = time.Now }()
+
+	defer stubCommands(commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			out, err := cloneCheckout(cmd)
+			mu.Lock()
+			now = now.Add(10 * time.Second)
+			mu.Unlock()
+			return out, err
+		},
+		"rev-parse": revParseLocked,
+	})()
+
+	scheduling = NewSchedulingReport()
+	defer func() { scheduling = nil }()
+
+	if err := loadTransitiveDependencies(checkpointedDeps("github.com/gopack-test/prefetched-a", "github.com/gopack-test/prefetched-b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scheduling.Timings) != 2 {
+		t.Fatalf("Expected both deps to be timed, found %d\n", len(scheduling.Timings))
+	}
+	for _, timing := range scheduling.Timings {
+		if timing.Fetching < 10*time.Second {
+			t.Errorf("Expected the download ahead of the turn to be part of the fetching, timed %+v\n", timing)
+		}
+	}
+}
+
+func TestSchedulingReportRecordsWaitingForAHost(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	fetchJobs, maxPerHost = 2, 1
+	defer func() { fetchJobs, maxPerHost = 1, 0 }()
+
+	var mu sync.Mutex
+	cloning, mostCloning := 0, 0
+	defer stubCommands(commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			mu.Lock()
+			cloning++
+			if cloning > mostCloning {
+				mostCloning = cloning
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			out, err := cloneCheckout(cmd)
+			mu.Lock()
+			cloning--
+			mu.Unlock()
+			return out, err
+		},
+		"rev-parse": revParseLocked,
+	})()
+
+	scheduling = NewSchedulingReport()
+	defer func() { scheduling = nil }()
+
+	if err := loadTransitiveDependencies(checkpointedDeps("github.com/gopack-test/host-a", "github.com/gopack-test/host-b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if mostCloning != 1 {
+		t.Errorf("Expected one clone from github.com at a time, %d ran at once\n", mostCloning)
+	}
+	var waiting time.Duration
+	for _, timing := range scheduling.Timings {
+		waiting += timing.Waiting
+	}
+	if waiting < 10*time.Millisecond {
+		t.Errorf("Expected a dep to wait for the other to be fetched from github.com, timed %+v\n", scheduling.Timings)
+	}
+
+	var out bytes.Buffer
+	scheduling.Print(&out)
+	if !strings.Contains(out.String(), "waiting for host") {
+		t.Errorf("Expected the wait for a host to be reported, printed\n%s\n", out.String())
+	}
+}
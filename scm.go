@@ -3,11 +3,12 @@ package main
 // LOL so we're gonna try and avoid THIS situation http://golang.org/src/cmd/go/vcs.go#L331
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 )
 
@@ -16,10 +17,15 @@ const (
 	HgTag     = "hg"
 	SvnTag    = "svn"
 	BzrTag    = "bzr"
+	FossilTag = "fossil"
 	HiddenGit = ".git"
 	HiddenHg  = ".hg"
 	HiddenSvn = ".svn"
 	HiddenBzr = ".bzr"
+	// fossil keeps the repository in a file, cloned into the checkout
+	HiddenFossil = ".fossil"
+	// and what is checked out from it in another one next to it
+	FossilCheckout = ".fslckout"
 )
 
 type Scm interface {
@@ -27,6 +33,21 @@ type Scm interface {
 	Checkout(d *Dep) error
 	Fetch(path string) error
 	DownloadCommand(source, path string) *exec.Cmd
+	Revision(path string) (string, error)
+	Dirty(path string) (bool, error)
+}
+
+// runCommand runs cmd and returns its combined output. Tests swap it
+// out to exercise the scm plumbing without shelling out.
+var runCommand = func(cmd *exec.Cmd) ([]byte, error) {
+	return cmd.CombinedOutput()
+}
+
+// Build a scm command running with the fetch environment.
+func scmCommand(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = fetchEnv()
+	return cmd
 }
 
 func dependencyPath(importPath string) string {
@@ -40,26 +61,51 @@ func scmStageDir(depPath, scmDir string) string {
 func downloadDependency(d *Dep, depPath, scmType string, scm Scm) (err error) {
 	stage, err := os.Stat(scmStageDir(depPath, scmType))
 
-	if stage != nil && stage.IsDir() {
-		err = scm.Fetch(depPath)
+	// a fossil repository is a file
+	if stage != nil {
+		// offline the dep is checked out from what it already has
+		if !offlineMode {
+			_, err = withRetries(func() error { return scm.Fetch(depPath) })
+		}
 	} else if err != nil && !os.IsNotExist(err) {
 		err = fmt.Errorf("Error while examining dependency path for %s: %s", d.Import, err)
+	} else if err = d.checkKnownHosts(); err != nil {
+		return
+	} else if usesCache(d, scm) {
+		err = cloneFromCache(d, depPath, scmType, scm)
+	} else if offlineMode {
+		err = fmt.Errorf("%s isn't cached, can't fetch it --offline", d.Import)
 	} else {
-		fmtcolor(Gray, "  Downloading: `%s` from %s\n", d.Import, d.Source)
-
-		var buf bytes.Buffer
-		cmd := scm.DownloadCommand(d.Source, depPath)
-		cmd.Stdout = &buf
-		cmd.Stderr = &buf
-		if err = cmd.Run(); err != nil {
-			buf.WriteTo(os.Stderr)
-			return fmt.Errorf("Error downloading dependency: %s", err)
-		}
+		err = fetchWithFallback(d.Import, d.Sources(), func(source string) error {
+			fmtcolor(Gray, "  Downloading: `%s` from %s\n", d.Import, maskCredentials(source))
+
+			// start every attempt from an empty dir so a partial clone
+			// doesn't get in the way
+			if err := os.RemoveAll(depPath); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(depPath, 0755); err != nil {
+				return err
+			}
+			return runScm(downloadCommand(d, scm, source, depPath))
+		})
 	}
 
 	return
 }
 
+// Deps pinned to a date or with a depth only clone the history they need.
+func downloadCommand(d *Dep, scm Scm, source, depPath string) *exec.Cmd {
+	cmd := scm.DownloadCommand(source, depPath)
+	if d.AsOf != "" {
+		cmd.Args = append(cmd.Args, "--shallow-since="+d.shallowSince(), "--branch", d.CheckoutSpec)
+	}
+	if d.Depth > 0 {
+		d.shallowCloneArgs(cmd)
+	}
+	return cmd
+}
+
 func initScm(d *Dep, scmType string, scm Scm) error {
 	path := dependencyPath(d.Import)
 
@@ -70,50 +116,126 @@ func initScm(d *Dep, scmType string, scm Scm) error {
 	}
 }
 
-func runInPath(path string, fn func() error) error {
-	err := os.Chdir(path)
+// A failed scm command along with what it printed.
+type ScmError struct {
+	Err    error
+	Output []byte
+}
+
+func (e *ScmError) Error() string {
+	if e.AuthFailed() {
+		return e.Err.Error() + ", authentication failed, set up credentials for the source or clone it over ssh with scm_url"
+	}
+	return e.Err.Error()
+}
+
+var (
+	notFoundOutputs   = []string{"not found", "does not exist", "404", "no such repository"}
+	authFailedOutputs = []string{"authentication failed", "could not read username", "terminal prompts disabled", "permission denied (publickey)", "error: 401", "error: 403"}
+)
+
+// Whether the command failed because the credentials were missing or wrong.
+func (e *ScmError) AuthFailed() bool {
+	out := strings.ToLower(string(e.Output))
+	for _, s := range authFailedOutputs {
+		if strings.Contains(out, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the command failed because the repository doesn't exist.
+func (e *ScmError) NotFound() bool {
+	out := strings.ToLower(string(e.Output))
+	for _, s := range notFoundOutputs {
+		if strings.Contains(out, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run a scm command, showing its output when it fails.
+func runScm(cmd *exec.Cmd) error {
+	out, err := runFetch(cmd)
 	if err != nil {
-		return err
+		os.Stderr.WriteString(maskURLCredentials(string(out)))
+		return &ScmError{err, out}
 	}
-	defer os.Chdir(pwd)
+	return nil
+}
+
+// Run a scm command in dir and return its trimmed output.
+func scmOutput(dir, name string, args ...string) (string, error) {
+	cmd := scmCommand(name, args...)
+	cmd.Dir = dir
+	out, err := execute(cmd)
+	if err != nil {
+		failed := fmt.Sprintf("%s %s failed in %s: %s", name, strings.Join(args, " "), dir, strings.TrimSpace(string(out)))
+		return "", errors.New(maskURLCredentials(failed))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
 
-	return fn()
+// Run a scm command in dir without changing the working directory of
+// gopack, so deps can be fetched concurrently.
+func runScmIn(dir, name string, args ...string) error {
+	cmd := scmCommand(name, args...)
+	cmd.Dir = dir
+	return runScm(cmd)
 }
 
 type Git struct{}
 
 func (g Git) Init(d *Dep) error {
-	return initScm(d, HiddenGit, g)
+	if err := initScm(d, HiddenGit, g); err != nil {
+		return err
+	}
+	return g.updateSubmodules(d, d.Src())
 }
 
 func (g Git) DownloadCommand(source, path string) *exec.Cmd {
-	return exec.Command("git", "clone", source, path)
+	return scmCommand("git", "clone", source, path)
 }
 
 func (g Git) Checkout(d *Dep) error {
-	var buf bytes.Buffer
-	cmd := exec.Command("git", "checkout", d.CheckoutSpec)
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
-		buf.WriteTo(os.Stderr)
+	var err error
+	if d.Depth > 0 {
+		err = g.checkoutShallow(d)
+	} else {
+		err = g.checkout(d)
+	}
+	if err != nil {
 		return err
 	}
-	return nil
+	return g.updateSubmodules(d, d.Src())
+}
+
+func (g Git) checkout(d *Dep) error {
+	if d.Locked != "" {
+		return runScmIn(d.Src(), "git", "checkout", d.Locked)
+	}
+	if d.Tag != "" {
+		return runScmIn(d.Src(), "git", "checkout", d.Tag)
+	}
+	if d.AsOf != "" {
+		return g.checkoutAsOf(d)
+	}
+	return runScmIn(d.Src(), "git", "checkout", d.CheckoutSpec)
 }
 
 func (g Git) Fetch(path string) error {
-	return runInPath(path, func() error {
-		var buf bytes.Buffer
-		cmd := exec.Command("git", "fetch")
-		cmd.Stdout = &buf
-		cmd.Stderr = &buf
-		if err := cmd.Run(); err != nil {
-			buf.WriteTo(os.Stderr)
-			return err
-		}
-		return nil
-	})
+	return runScmIn(path, "git", "fetch")
+}
+
+func (g Git) Revision(path string) (string, error) {
+	return scmOutput(path, "git", "rev-parse", "HEAD")
+}
+
+func (g Git) Dirty(path string) (bool, error) {
+	out, err := scmOutput(path, "git", "status", "--porcelain", "--", ".")
+	return out != "", err
 }
 
 type Hg struct{}
@@ -123,39 +245,29 @@ func (h Hg) Init(d *Dep) error {
 }
 
 func (h Hg) DownloadCommand(source, path string) *exec.Cmd {
-	return exec.Command("hg", "clone", source, path)
+	return scmCommand("hg", "clone", source, path)
 }
 
 func (h Hg) Checkout(d *Dep) error {
-	var cmd *exec.Cmd
-
-	var buf bytes.Buffer
-	if d.CheckoutFlag == CommitFlag {
-		cmd = exec.Command("hg", "update", "-c", d.CheckoutSpec)
-	} else {
-		cmd = exec.Command("hg", "checkout", d.CheckoutSpec)
-	}
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
-		buf.WriteTo(os.Stderr)
-		return err
+	// branches, tags and changesets are all revisions to hg
+	rev := d.CheckoutSpec
+	if d.Locked != "" {
+		rev = d.Locked
 	}
-	return nil
+	return runScmIn(d.Src(), "hg", "update", "-r", rev)
 }
 
 func (h Hg) Fetch(path string) error {
-	return runInPath(path, func() error {
-		var buf bytes.Buffer
-		cmd := exec.Command("hg", "pull")
-		cmd.Stdout = &buf
-		cmd.Stderr = &buf
-		if err := cmd.Run(); err != nil {
-			buf.WriteTo(os.Stderr)
-			return err
-		}
-		return nil
-	})
+	return runScmIn(path, "hg", "pull")
+}
+
+func (h Hg) Revision(path string) (string, error) {
+	return scmOutput(path, "hg", "log", "-r", ".", "--template", "{node}")
+}
+
+func (h Hg) Dirty(path string) (bool, error) {
+	out, err := scmOutput(path, "hg", "status", ".")
+	return out != "", err
 }
 
 type Svn struct {
@@ -166,42 +278,34 @@ func (s Svn) Init(d *Dep) error {
 }
 
 func (s Svn) DownloadCommand(source, path string) *exec.Cmd {
-	return exec.Command("svn", "checkout", source, path)
+	return scmCommand("svn", "checkout", source, path)
 }
 
 func (s Svn) Checkout(d *Dep) error {
-	var cmd *exec.Cmd
+	var args []string
 
-	var buf bytes.Buffer
 	switch d.CheckoutFlag {
 	case CommitFlag:
-		cmd = exec.Command("svn", "up", "-r", d.CheckoutSpec)
+		args = []string{"up", "-r", d.CheckoutSpec}
 	case BranchFlag:
-		cmd = exec.Command("svn", "switch", "^/branches/"+d.CheckoutSpec)
+		args = []string{"switch", "^/branches/" + d.CheckoutSpec}
 	case TagFlag:
-		cmd = exec.Command("svn", "switch", "^/tags/"+d.CheckoutSpec)
+		args = []string{"switch", "^/tags/" + d.CheckoutSpec}
 	}
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
-		buf.WriteTo(os.Stderr)
-		return err
-	}
-	return nil
+	return runScmIn(d.Src(), "svn", args...)
 }
 
 func (s Svn) Fetch(path string) error {
-	return runInPath(path, func() error {
-		var buf bytes.Buffer
-		cmd := exec.Command("svn", "update")
-		cmd.Stdout = &buf
-		cmd.Stderr = &buf
-		if err := cmd.Run(); err != nil {
-			buf.WriteTo(os.Stderr)
-			return err
-		}
-		return nil
-	})
+	return runScmIn(path, "svn", "update")
+}
+
+func (s Svn) Revision(path string) (string, error) {
+	return scmOutput(path, "svnversion")
+}
+
+func (s Svn) Dirty(path string) (bool, error) {
+	out, err := scmOutput(path, "svn", "status")
+	return out != "", err
 }
 
 type Bzr struct {
@@ -212,40 +316,101 @@ func (b Bzr) Init(d *Dep) error {
 }
 
 func (b Bzr) DownloadCommand(source, path string) *exec.Cmd {
-	return exec.Command("bzr", "branch", source, path)
+	return scmCommand("bzr", "branch", source, path)
 }
 
 func (b Bzr) Checkout(d *Dep) error {
-	var cmd *exec.Cmd
+	return runScmIn(d.Src(), "bzr", "update", "-r", b.revision(d))
+}
 
-	var buf bytes.Buffer
-	switch d.CheckoutFlag {
-	case CommitFlag:
-		cmd = exec.Command("bzr", "update", "-r", d.CheckoutSpec)
-	case BranchFlag:
-		cmd = exec.Command("bzr", "update", "-r", "branch:"+d.CheckoutSpec)
-	case TagFlag:
-		cmd = exec.Command("bzr", "update", "-r", "tag:"+d.CheckoutSpec)
+// The revision spec bzr understands for the branch, tag or commit of d.
+// Revisions are numbers, anything else is a revision id.
+func (b Bzr) revision(d *Dep) string {
+	rev := d.CheckoutSpec
+	if d.Locked != "" {
+		rev = d.Locked
 	}
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
-		buf.WriteTo(os.Stderr)
+	switch {
+	case d.Locked == "" && d.CheckoutFlag == TagFlag:
+		return "tag:" + rev
+	case isRevno(rev):
+		return rev
 	}
-	return nil
+	return "revid:" + rev
+}
+
+func isRevno(rev string) bool {
+	_, err := strconv.Atoi(rev)
+	return err == nil
 }
 
 func (b Bzr) Fetch(path string) error {
-	return runInPath(path, func() error {
-		var buf bytes.Buffer
-		cmd := exec.Command("bzr", "pull")
-		cmd.Stdout = &buf
-		cmd.Stderr = &buf
-		if err := cmd.Run(); err != nil {
-			buf.WriteTo(os.Stderr)
-		}
+	return runScmIn(path, "bzr", "pull")
+}
+
+func (b Bzr) Revision(path string) (string, error) {
+	return scmOutput(path, "bzr", "revno")
+}
+
+func (b Bzr) Dirty(path string) (bool, error) {
+	out, err := scmOutput(path, "bzr", "status", ".")
+	return out != "", err
+}
+
+type Fossil struct{}
+
+// The repository is cloned into the dep and opened there, so the
+// checkout can be stripped like the metadata of any other scm.
+func (f Fossil) Init(d *Dep) error {
+	if err := initScm(d, HiddenFossil, f); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path.Join(d.Src(), FossilCheckout)); err == nil {
 		return nil
-	})
+	}
+	return runScmIn(d.Src(), "fossil", "open", "--force", HiddenFossil)
+}
+
+func (f Fossil) DownloadCommand(source, dir string) *exec.Cmd {
+	return scmCommand("fossil", "clone", source, path.Join(dir, HiddenFossil))
+}
+
+func (f Fossil) Checkout(d *Dep) error {
+	return runScmIn(d.Src(), "fossil", "update", f.revision(d))
+}
+
+// Branch names and hashes are versions to fossil, tags are told apart
+// from branches of the same name with tag:.
+func (f Fossil) revision(d *Dep) string {
+	switch {
+	case d.Locked != "":
+		return d.Locked
+	case d.CheckoutFlag == TagFlag:
+		return "tag:" + d.CheckoutSpec
+	}
+	return d.CheckoutSpec
+}
+
+func (f Fossil) Fetch(path string) error {
+	return runScmIn(path, "fossil", "pull")
+}
+
+func (f Fossil) Revision(path string) (string, error) {
+	out, err := scmOutput(path, "fossil", "info")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == "checkout:" {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("fossil info has no checkout in %s", path)
+}
+
+func (f Fossil) Dirty(path string) (bool, error) {
+	out, err := scmOutput(path, "fossil", "changes")
+	return out != "", err
 }
 
 // The Go scm embeds another scm and only implements Init so that
@@ -255,28 +420,27 @@ type Go struct {
 }
 
 func (g Go) Init(d *Dep) error {
-	var buf bytes.Buffer
-	cmd := g.DownloadCommand(d.Import, "")
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
-		buf.WriteTo(os.Stderr)
+	if offlineMode {
+		if _, err := os.Stat(d.Src()); err != nil {
+			return fmt.Errorf("%s isn't cached, can't go get it --offline", d.Import)
+		}
+		return nil
 	}
+	runScm(g.DownloadCommand(d.Import, ""))
 	return nil
 }
 
 func (g Go) DownloadCommand(source, path string) *exec.Cmd {
-	return exec.Command("go", "get", "-d", "-u", source)
+	return scmCommand("go", "get", "-d", "-u", source)
 }
 
 func NewScm(d *Dep) (Scm, error) {
-	switch d.Scm {
-	case GitTag:
-		return Scms[GitTag], nil
-	case HgTag:
-		return Scms[HgTag], nil
-	case SvnTag:
-		return Scms[SvnTag], nil
+	if d.scm != nil {
+		return d.scm, nil
+	}
+	// supporting another scm only takes adding it to Scms
+	if scm, ok := Scms[d.Scm]; ok {
+		return scm, nil
 	}
 
 	scm := scmInSource(d)
@@ -290,6 +454,21 @@ func NewScm(d *Dep) (Scm, error) {
 	return nil, fmt.Errorf("unknown scm for %s", d.Import)
 }
 
+// Catch a scm gopack doesn't know, or a checkout it can't ask that scm
+// for, before anything is fetched.
+func (d *Dep) validateScm() error {
+	if _, known := Scms[d.Scm]; !known && d.Scm != "go" {
+		return fmt.Errorf("%s - unknown scm %s, use git, hg, svn, bzr or fossil", d.Import, d.Scm)
+	}
+	switch {
+	case d.Scm == SvnTag && d.CheckoutFlag == CommitFlag && !isRevno(d.CheckoutSpec):
+		return fmt.Errorf("%s - svn commits are revision numbers, %s isn't one", d.Import, d.CheckoutSpec)
+	case d.Scm == BzrTag && d.CheckoutFlag == BranchFlag:
+		return fmt.Errorf("%s - bzr branches live at their own url, set source to the branch instead", d.Import)
+	}
+	return nil
+}
+
 // Traverse the source tree backwards until
 // it finds the right directory
 // or it arrives to the base of the import.
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Fake hg recording the commands it runs.
+func stubHg(ran *[]string) func() {
+	return recordCommands(ran, commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			createPath(path.Join(cmd.Args[3], HiddenHg))
+			return nil, nil
+		},
+	})
+}
+
+func TestHgDepsAreClonedAndUpdated(t *testing.T) {
+	config := setupTestConfig(`
+[deps.branch]
+  import = "hg.internal/gopack-test/hg-branch"
+  scm = "hg"
+  source = "https://hg.internal/gopack-test/hg-branch"
+  branch = "stable"
+
+[deps.tag]
+  import = "hg.internal/gopack-test/hg-tag"
+  scm = "hg"
+  source = "https://hg.internal/gopack-test/hg-tag"
+  tag = "1.2"
+
+[deps.commit]
+  import = "hg.internal/gopack-test/hg-commit"
+  scm = "hg"
+  source = "https://hg.internal/gopack-test/hg-commit"
+  commit = "a1b2c3d4e5f6"
+`)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dep := range deps.DepList {
+		if scm, err := NewScm(dep); err != nil || scm != Scms[HgTag] {
+			t.Errorf("Expected %s to be fetched with hg, got %v %v\n", dep.Import, scm, err)
+		}
+
+		ran := []string{}
+		restore := stubHg(&ran)
+		dep.Fetch(true)
+		if err := dep.Get(); err != nil {
+			t.Fatal(err)
+		}
+		dep.switchToBranchOrTag()
+		restore()
+
+		expected := []string{
+			"hg clone " + dep.Source + " " + dep.Src(),
+			"hg update -r " + dep.CheckoutSpec,
+		}
+		if strings.Join(ran, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Expected %s to run %v but ran %v\n", dep.Import, expected, ran)
+		}
+	}
+}
+
+// A scm that records what it's asked to do instead of running anything.
+type stubScm struct {
+	ran *[]string
+}
+
+func (s stubScm) Init(d *Dep) error {
+	*s.ran = append(*s.ran, "init "+d.Import)
+	return nil
+}
+
+func (s stubScm) Checkout(d *Dep) error {
+	*s.ran = append(*s.ran, "checkout "+d.CheckoutSpec)
+	return nil
+}
+
+func (s stubScm) Fetch(path string) error                       { return nil }
+func (s stubScm) DownloadCommand(source, path string) *exec.Cmd { return nil }
+func (s stubScm) Revision(path string) (string, error)          { return lockedSha, nil }
+func (s stubScm) Dirty(path string) (bool, error)               { return false, nil }
+
+func TestDepFetchesWithInjectedScm(t *testing.T) {
+	setupTestPwd()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	ran := []string{}
+	dep := gitDep("github.com/gopack-test/injected", "tag", "v1.0.0")
+	dep.scm = stubScm{&ran}
+	createPath(dep.Src())
+
+	if err := updateDependency(&Dependencies{DepList: []*Dep{dep}}, dep); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "init github.com/gopack-test/injected,checkout v1.0.0"
+	if strings.Join(ran, ",") != expected {
+		t.Errorf("Expected the injected scm to run %s but it ran %v\n", expected, ran)
+	}
+}
+
+// Record every command, with the dir it runs in when it has one.
+func stubRunner(ran *[]string) func() {
+	return stubCommands(commandStubs{
+		"*": func(cmd *exec.Cmd) ([]byte, error) {
+			command := strings.Join(cmd.Args, " ")
+			if cmd.Dir != "" {
+				command += " (in " + path.Base(cmd.Dir) + ")"
+			}
+			*ran = append(*ran, command)
+			return nil, nil
+		},
+	})
+}
+
+func TestScmCheckoutCommands(t *testing.T) {
+	tests := []struct {
+		scm      Scm
+		flag     uint8
+		spec     string
+		locked   string
+		expected string
+	}{
+		{Bzr{}, TagFlag, "1.2", "", "bzr update -r tag:1.2 (in scm)"},
+		{Bzr{}, CommitFlag, "42", "", "bzr update -r 42 (in scm)"},
+		{Bzr{}, CommitFlag, "jane@example.com-20140101-abc", "", "bzr update -r revid:jane@example.com-20140101-abc (in scm)"},
+		{Bzr{}, TagFlag, "1.2", "42", "bzr update -r 42 (in scm)"},
+		{Fossil{}, BranchFlag, "trunk", "", "fossil update trunk (in scm)"},
+		{Fossil{}, TagFlag, "1.2", "", "fossil update tag:1.2 (in scm)"},
+		{Fossil{}, CommitFlag, "a1b2c3d4e5", "", "fossil update a1b2c3d4e5 (in scm)"},
+		{Fossil{}, TagFlag, "1.2", "a1b2c3d4e5", "fossil update a1b2c3d4e5 (in scm)"},
+	}
+
+	for _, test := range tests {
+		ran := []string{}
+		restore := stubRunner(&ran)
+		dep := &Dep{Import: "example.org/gopack-test/scm", CheckoutFlag: test.flag, CheckoutSpec: test.spec, Locked: test.locked}
+		err := test.scm.Checkout(dep)
+		restore()
+
+		if err != nil || strings.Join(ran, ",") != test.expected {
+			t.Errorf("Expected %s to run %s but ran %v %v\n", test.spec, test.expected, ran, err)
+		}
+	}
+}
+
+func TestScmDownloadCommands(t *testing.T) {
+	tests := []struct {
+		scm      Scm
+		expected string
+	}{
+		{Bzr{}, "bzr branch https://example.org/repo /vendor/repo"},
+		{Fossil{}, "fossil clone https://example.org/repo /vendor/repo/.fossil"},
+	}
+
+	for _, test := range tests {
+		cmd := test.scm.DownloadCommand("https://example.org/repo", "/vendor/repo")
+		if command := strings.Join(cmd.Args, " "); command != test.expected {
+			t.Errorf("Expected %s but got %s\n", test.expected, command)
+		}
+	}
+}
+
+func TestFossilDepsAreClonedOpenedAndStripped(t *testing.T) {
+	setupTestPwd()
+	dep := &Dep{Import: "fossil.internal/gopack-test/fossil-clone", Scm: FossilTag, Source: "https://fossil.internal/fossil-clone"}
+	os.RemoveAll(dep.Src())
+
+	ran := []string{}
+	defer recordCommands(&ran, commandStubs{
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			createSourceFixture(path.Dir(cmd.Args[3]), HiddenFossil, "repository")
+			return nil, nil
+		},
+		"open": func(cmd *exec.Cmd) ([]byte, error) {
+			createSourceFixture(cmd.Dir, FossilCheckout, "checkout")
+			return nil, nil
+		},
+	})()
+
+	if err := Scms[FossilTag].Init(dep); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"fossil clone " + dep.Source + " " + path.Join(dep.Src(), HiddenFossil),
+		"fossil open --force " + HiddenFossil,
+	}
+	if strings.Join(ran, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %v but ran %v\n", expected, ran)
+	}
+
+	if scm, err := NewScm(&Dep{Import: dep.Import}); err != nil || scm != Scms[FossilTag] {
+		t.Errorf("Expected the checkout to be found as fossil, got %v %v\n", scm, err)
+	}
+
+	if err := dep.StripVcs(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{HiddenFossil, FossilCheckout} {
+		if _, err := os.Stat(path.Join(dep.Src(), name)); err == nil {
+			t.Errorf("Expected %s to be stripped\n", name)
+		}
+	}
+}
+
+func TestUnsupportedScmCheckoutsFailValidation(t *testing.T) {
+	tests := []struct {
+		scm  string
+		flag uint8
+		spec string
+		ok   bool
+	}{
+		{SvnTag, CommitFlag, "1234", true},
+		{SvnTag, CommitFlag, "a1b2c3d4", false},
+		{BzrTag, TagFlag, "1.2", true},
+		{BzrTag, BranchFlag, "stable", false},
+		{FossilTag, BranchFlag, "trunk", true},
+		{"darcs", TagFlag, "1.2", false},
+	}
+
+	for _, test := range tests {
+		dep := &Dep{Import: "example.org/gopack-test/scm", Scm: test.scm, Source: "https://example.org/scm", CheckoutFlag: test.flag, CheckoutSpec: test.spec}
+		if err := dep.Validate(); (err == nil) != test.ok {
+			t.Errorf("Expected %s %s to be valid: %v, got %v\n", test.scm, test.spec, test.ok, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A release version, pre-releases and build metadata aren't supported.
+type semver [3]int
+
+// Parse v1.4.2 or 1.4.2, with the missing parts of 1.4 or 1 as zeroes.
+func parseSemver(s string) (semver, int, bool) {
+	var v semver
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) > 3 {
+		return v, 0, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, 0, false
+		}
+		v[i] = n
+	}
+	return v, len(parts), true
+}
+
+func (v semver) compare(o semver) int {
+	for i := range v {
+		if v[i] != o[i] {
+			if v[i] < o[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// A tag that's a version range rather than a tag name.
+func isVersionRange(spec string) bool {
+	return strings.IndexAny(spec, "^~<>=") == 0
+}
+
+type versionConstraint struct {
+	op      string
+	version semver
+}
+
+func (c versionConstraint) allows(v semver) bool {
+	cmp := v.compare(c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return cmp == 0
+}
+
+// Parse ^1.4, ~1.4.2, or comparisons all versions must satisfy like
+// ">=1.2 <2", into the comparisons they stand for.
+func parseVersionRange(spec string) ([]versionConstraint, error) {
+	constraints := []versionConstraint{}
+	for _, field := range strings.Fields(spec) {
+		op := strings.TrimRight(field, "v0123456789.")
+		v, parts, ok := parseSemver(strings.TrimPrefix(field, op))
+		if !ok {
+			return nil, fmt.Errorf("invalid version range %s", spec)
+		}
+
+		switch op {
+		case "^":
+			// up to the next major, or minor for 0.x versions
+			upper := semver{v[0] + 1, 0, 0}
+			if v[0] == 0 && parts > 1 {
+				upper = semver{0, v[1] + 1, 0}
+			}
+			constraints = append(constraints, versionConstraint{">=", v}, versionConstraint{"<", upper})
+		case "~":
+			// up to the next minor, or major when only the major is given
+			upper := semver{v[0], v[1] + 1, 0}
+			if parts == 1 {
+				upper = semver{v[0] + 1, 0, 0}
+			}
+			constraints = append(constraints, versionConstraint{">=", v}, versionConstraint{"<", upper})
+		case ">", ">=", "<", "<=", "=":
+			constraints = append(constraints, versionConstraint{op, v})
+		default:
+			return nil, fmt.Errorf("invalid version range %s", spec)
+		}
+	}
+	if len(constraints) == 0 {
+		return nil, fmt.Errorf("invalid version range %s", spec)
+	}
+	return constraints, nil
+}
+
+// The highest of the tags satisfying every constraint, empty when none
+// does.
+func highestMatch(tags []string, constraints []versionConstraint) string {
+	best, bestVersion := "", semver{}
+	for _, tag := range tags {
+		v, _, ok := parseSemver(tag)
+		if !ok {
+			continue
+		}
+		matches := true
+		for _, c := range constraints {
+			matches = matches && c.allows(v)
+		}
+		if matches && (best == "" || v.compare(bestVersion) > 0) {
+			best, bestVersion = tag, v
+		}
+	}
+	return best
+}
+
+// The tags of the dep's remote, peeled tags only once.
+func remoteTags(d *Dep) ([]string, error) {
+	refs, err := LsRemote(d.RemoteURL())
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{}
+	for _, ref := range refs {
+		if strings.HasPrefix(ref.Name, "refs/tags/") {
+			tag := strings.TrimSuffix(strings.TrimPrefix(ref.Name, "refs/tags/"), "^{}")
+			if !containsString(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// A tag range is checked when the config is loaded, it's only resolved
+// once the dep is about to be fetched.
+func (d *Dep) validateTagRange() error {
+	if d.CheckoutFlag != TagFlag || !isVersionRange(d.CheckoutSpec) {
+		return nil
+	}
+	if d.Scm != GitTag {
+		return fmt.Errorf("%s - version ranges are only supported for git dependencies", d.Import)
+	}
+	if _, err := parseVersionRange(d.CheckoutSpec); err != nil {
+		return fmt.Errorf("%s - %s", d.Import, err)
+	}
+	return nil
+}
+
+// Resolve a tag range to the highest matching tag of the remote. The tag
+// in the lock is kept as long as it still matches, so the range is only
+// resolved again by gp update or gp lock.
+func (d *Dep) resolveTagRange(lock *Lock) error {
+	if d.CheckoutFlag != TagFlag || !isVersionRange(d.CheckoutSpec) || d.Tag != "" {
+		return nil
+	}
+	if err := d.validateTagRange(); err != nil {
+		return err
+	}
+	constraints, _ := parseVersionRange(d.CheckoutSpec)
+
+	if lock != nil {
+		if locked := lock.Deps[d.Import]; locked != nil && locked.Tag != "" && highestMatch([]string{locked.Tag}, constraints) != "" {
+			d.Tag = locked.Tag
+			return nil
+		}
+	}
+
+	tags, err := remoteTags(d)
+	if err != nil {
+		return fmt.Errorf("%s - %s", d.Import, err)
+	}
+	d.Tag = highestMatch(tags, constraints)
+	switch {
+	case d.Tag == "" && d.gopkgBranch != "":
+		d.CheckoutFlag, d.CheckoutSpec = BranchFlag, d.gopkgBranch
+	case d.Tag == "":
+		return fmt.Errorf("%s - no tag matches %s, available tags: %s", d.Import, d.CheckoutSpec, strings.Join(tags, ", "))
+	}
+	return nil
+}
+
+// Resolve the tag ranges of the deps about to be fetched or resolved,
+// keeping the tags of lock.
+func (d *Dependencies) resolveTagRanges(lock *Lock) error {
+	for _, dep := range d.DepList {
+		if err := dep.resolveTagRange(lock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
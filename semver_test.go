@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+var semverTags = []string{"v1.3.9", "v1.4.0", "v1.4.2", "v1.5.0", "v2.0.0", "v2.1.0-rc1", "latest"}
+
+func TestHighestMatchingTag(t *testing.T) {
+	for spec, expected := range map[string]string{
+		"^1.4":       "v1.5.0",
+		"~1.4":       "v1.4.2",
+		"~1.4.1":     "v1.4.2",
+		"^2":         "v2.0.0",
+		">=1.4 <1.5": "v1.4.2",
+		"<1.4":       "v1.3.9",
+		"=1.4.0":     "v1.4.0",
+		"^3":         "",
+	} {
+		constraints, err := parseVersionRange(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag := highestMatch(semverTags, constraints); tag != expected {
+			t.Errorf("Expected %s to resolve to %q, got %q\n", spec, expected, tag)
+		}
+	}
+}
+
+func TestInvalidVersionRanges(t *testing.T) {
+	for _, spec := range []string{"^", "^1.x", ">=1.2 2.0", "^1.2.3.4"} {
+		if _, err := parseVersionRange(spec); err == nil {
+			t.Errorf("Expected %s to be an invalid range\n", spec)
+		}
+	}
+}
+
+func stubTags(tags ...string) func() {
+	restore := stubCommands(commandStubs{
+		"ls-remote": func(cmd *exec.Cmd) ([]byte, error) {
+			out := ""
+			for _, tag := range tags {
+				out += lockedSha + "\trefs/tags/" + tag + "\n" + movedSha + "\trefs/tags/" + tag + "^{}\n"
+			}
+			return []byte(out), nil
+		},
+	})
+	refreshRemote = true
+	return func() {
+		restore()
+		refreshRemote = false
+	}
+}
+
+func TestTagRangeResolvesToHighestTag(t *testing.T) {
+	setupTestPwd()
+	defer stubTags("v1.4.0", "v1.4.2", "v2.0.0")()
+
+	dep := gitDep("github.com/gopack-test/ranged", "tag", "^1.4")
+	if err := dep.resolveTagRange(nil); err != nil {
+		t.Fatal(err)
+	}
+	if dep.Tag != "v1.4.2" {
+		t.Errorf("Expected ^1.4 to resolve to v1.4.2, got %s\n", dep.Tag)
+	}
+	if revision := remoteRevision(dep); revision != movedSha {
+		t.Errorf("Expected the peeled revision of v1.4.2, got %s\n", revision)
+	}
+}
+
+func TestTagRangeKeepsLockedTag(t *testing.T) {
+	setupTestPwd()
+	defer stubTags("v1.4.0", "v1.4.2")()
+
+	lock := NewLock()
+	lock.Deps["github.com/gopack-test/ranged"] = &LockedDep{Import: "github.com/gopack-test/ranged", Checkout: "tag ^1.4", Tag: "v1.4.0"}
+
+	dep := gitDep("github.com/gopack-test/ranged", "tag", "^1.4")
+	if err := dep.resolveTagRange(lock); err != nil || dep.Tag != "v1.4.0" {
+		t.Errorf("Expected the locked v1.4.0 to be kept, got %s %v\n", dep.Tag, err)
+	}
+
+	// gp lock and gp update resolve without the lock
+	dep = gitDep("github.com/gopack-test/ranged", "tag", "^1.4")
+	if err := dep.resolveTagRange(nil); err != nil || dep.Tag != "v1.4.2" {
+		t.Errorf("Expected gp lock to resolve ^1.4 again to v1.4.2, got %s %v\n", dep.Tag, err)
+	}
+}
+
+func TestUnmatchedTagRangeFails(t *testing.T) {
+	setupTestPwd()
+	defer stubTags("v1.4.0", "v2.0.0")()
+
+	dep := gitDep("github.com/gopack-test/ranged", "tag", "^3.1")
+	err := dep.resolveTagRange(nil)
+	expected := "github.com/gopack-test/ranged - no tag matches ^3.1, available tags: v1.4.0, v2.0.0"
+	if err == nil || !strings.HasPrefix(err.Error(), expected) {
+		t.Errorf("Expected %q, got %v\n", expected, err)
+	}
+}
+
+func TestValidatingTagRangesStaysOffline(t *testing.T) {
+	setupTestPwd()
+	defer stubCommands(commandStubs{
+		"*": func(cmd *exec.Cmd) ([]byte, error) {
+			t.Errorf("Expected validating a tag range not to run %v\n", cmd.Args)
+			return nil, nil
+		},
+	})()
+
+	if err := gitDep("github.com/gopack-test/ranged", "tag", "^1.4").Validate(); err != nil {
+		t.Errorf("Expected ^1.4 to be valid, got %s\n", err)
+	}
+	if err := gitDep("github.com/gopack-test/ranged", "tag", "^x").Validate(); err == nil || !strings.Contains(err.Error(), "invalid version range ^x") {
+		t.Errorf("Expected the invalid range to fail validation, got %v\n", err)
+	}
+}
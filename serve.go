@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sync"
+)
+
+// where gp serve listens, set with --addr. Only locally by default since
+// anyone reaching it can have the remotes queried again.
+var serveAddr = "127.0.0.1:8080"
+
+// Serves the graph resolved at startup, POST /refresh resolves it again.
+type GraphServer struct {
+	sync.Mutex
+	deps    *Dependencies
+	resolve func() (*Dependencies, error)
+	// one refresh at a time
+	refreshing sync.Mutex
+	mux        *http.ServeMux
+}
+
+func NewGraphServer(deps *Dependencies, resolve func() (*Dependencies, error)) *GraphServer {
+	s := &GraphServer{deps: deps, resolve: resolve, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/", s.serveTree)
+	s.mux.HandleFunc("/graph.json", s.serveJSON)
+	s.mux.HandleFunc("/refresh", s.refresh)
+	return s
+}
+
+func (s *GraphServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve until the server fails, it never stops otherwise.
+func (s *GraphServer) ListenAndServe(addr string) error {
+	fmtcolor(Gray, "      Serving: dependency graph on %s\n", addr)
+	return http.ListenAndServe(addr, s)
+}
+
+// Resolve the graph again like --graph-only: the vendor tree, the lock
+// and the checksum are left as they are.
+func resolveGraph(root string) (*Dependencies, error) {
+	defer func(was bool) { graphOnly = was }(graphOnly)
+	graphOnly = true
+
+	p, err := AnalyzeSourceTree(root)
+	if err != nil {
+		return nil, err
+	}
+	_, deps, err := loadDependencies(root, p)
+	return deps, err
+}
+
+func (s *GraphServer) graph() *GraphJSON {
+	s.Lock()
+	defer s.Unlock()
+	return s.deps.GraphJSON()
+}
+
+func (s *GraphServer) serveJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.graph())
+}
+
+func (s *GraphServer) refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "refresh needs a POST", http.StatusMethodNotAllowed)
+		return
+	}
+	s.refreshing.Lock()
+	deps, err := s.resolve()
+	s.refreshing.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.Lock()
+	s.deps = deps
+	s.Unlock()
+
+	s.serveJSON(w, r)
+}
+
+type treeNode struct {
+	Import   string
+	Checkout string
+	Children []*treeNode
+}
+
+// Nest the nodes under the roots following the edges, a dep that's
+// already on the way down isn't repeated so cycles end. The subtree of a
+// dep is built once, deps required in several places share it.
+func (g *GraphJSON) tree() []*treeNode {
+	checkouts := make(map[string]string)
+	for _, n := range g.Nodes {
+		checkouts[n.Import] = n.Checkout
+	}
+	children := make(map[string][]string)
+	for _, e := range g.Edges {
+		children[e.From] = append(children[e.From], e.To)
+	}
+
+	built := make(map[string]*treeNode)
+	path := make(map[string]bool)
+	var build func(importPath string) *treeNode
+	build = func(importPath string) *treeNode {
+		if node, ok := built[importPath]; ok {
+			return node
+		}
+		node := &treeNode{Import: importPath, Checkout: checkouts[importPath]}
+		path[importPath] = true
+		for _, child := range children[importPath] {
+			if !path[child] {
+				node.Children = append(node.Children, build(child))
+			}
+		}
+		delete(path, importPath)
+		built[importPath] = node
+		return node
+	}
+
+	roots := []*treeNode{}
+	for _, n := range g.Nodes {
+		if n.Root {
+			roots = append(roots, build(n.Import))
+		}
+	}
+	return roots
+}
+
+var treeTemplate = template.Must(template.New("tree").Parse(`<!DOCTYPE html>
+<html>
+<head><title>gopack dependencies</title></head>
+<body>
+<h1>Dependencies</h1>
+{{template "deps" .}}
+</body>
+</html>
+{{define "deps"}}<ul>{{range .}}
+<li><code>{{.Import}}</code>{{if .Checkout}} @ {{.Checkout}}{{end}}{{if .Children}}{{template "deps" .Children}}{{end}}</li>{{end}}
+</ul>{{end}}`))
+
+func (s *GraphServer) serveTree(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	treeTemplate.Execute(w, s.graph().tree())
+}
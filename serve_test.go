@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func servedDeps() *Dependencies {
+	root := gitDep("github.com/gopack-test/root", "branch", "master")
+	child := gitDep("github.com/gopack-test/child", "tag", "v1.0")
+	child.Revision = "182cae2ee3926a960223d8db4998aa9d57c89788"
+
+	graph := NewGraph()
+	graph.Insert(root)
+	graph.Insert(child)
+	graph.AddEdge(root.Import, child.Import)
+
+	return &Dependencies{Imports: []string{root.Import}, DepList: []*Dep{root}, ImportGraph: graph}
+}
+
+func getGraph(t *testing.T, server *httptest.Server, method, path string) *GraphJSON {
+	req, _ := http.NewRequest(method, server.URL+path, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	g := new(GraphJSON)
+	if err := json.NewDecoder(resp.Body).Decode(g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestServeGraphJSON(t *testing.T) {
+	server := httptest.NewServer(NewGraphServer(servedDeps(), nil))
+	defer server.Close()
+
+	g := getGraph(t, server, "GET", "/graph.json")
+
+	if len(g.Nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, found %d\n", len(g.Nodes))
+	}
+	child, root := g.Nodes[0], g.Nodes[1]
+	if root.Import != "github.com/gopack-test/root" || !root.Root || root.Checkout != "branch master" {
+		t.Errorf("Expected the root dep to be served, found %+v\n", root)
+	}
+	if child.Import != "github.com/gopack-test/child" || child.Root || child.Revision != "182cae2ee3926a960223d8db4998aa9d57c89788" {
+		t.Errorf("Expected the transitive dep to be served, found %+v\n", child)
+	}
+
+	if len(g.Edges) != 1 || g.Edges[0].From != root.Import || g.Edges[0].To != child.Import {
+		t.Errorf("Expected an edge from the root to the child, found %v\n", g.Edges)
+	}
+}
+
+func TestServeRefreshResolvesAgain(t *testing.T) {
+	resolved := 0
+	server := httptest.NewServer(NewGraphServer(&Dependencies{ImportGraph: NewGraph()}, func() (*Dependencies, error) {
+		resolved++
+		return servedDeps(), nil
+	}))
+	defer server.Close()
+
+	if g := getGraph(t, server, "GET", "/graph.json"); len(g.Nodes) != 0 {
+		t.Errorf("Expected the graph resolved at startup to be served, found %d nodes\n", len(g.Nodes))
+	}
+
+	if g := getGraph(t, server, "POST", "/refresh"); resolved != 1 || len(g.Nodes) != 2 {
+		t.Errorf("Expected a refresh to serve the resolved graph, resolved %d times and found %d nodes\n", resolved, len(g.Nodes))
+	}
+}
+
+func TestServeRefreshFailureKeepsServing(t *testing.T) {
+	server := httptest.NewServer(NewGraphServer(servedDeps(), func() (*Dependencies, error) {
+		return nil, fmt.Errorf("Error loading dependency info")
+	}))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/refresh", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || !strings.Contains(string(body), "Error loading dependency info") {
+		t.Errorf("Expected the failed refresh to be a 500 with its error, got %d %s\n", resp.StatusCode, body)
+	}
+
+	if g := getGraph(t, server, "GET", "/graph.json"); len(g.Nodes) != 2 {
+		t.Errorf("Expected the graph resolved before to still be served, found %d nodes\n", len(g.Nodes))
+	}
+}
+
+func TestServeTreeView(t *testing.T) {
+	server := httptest.NewServer(NewGraphServer(servedDeps(), nil))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	// the child is nested in the root's list
+	html := string(body)
+	if !strings.Contains(html, "<code>github.com/gopack-test/root</code> @ branch master<ul>") ||
+		!strings.Contains(html, "<code>github.com/gopack-test/child</code> @ tag v1.0</li>") {
+		t.Errorf("Expected the tree to be rendered, got\n%s\n", html)
+	}
+}
+
+func TestServeRefreshOnlyResolvesTheGraph(t *testing.T) {
+	setupTestConfig(`
+[deps.refreshed]
+  import = "github.com/gopack-test/refreshed"
+  scm = "git"
+  source = "https://github.com/gopack-test/refreshed.git"
+  branch = "master"
+`)
+	defer stubVendorDir(".gopack/vendor")()
+	ran := []string{}
+	defer recordCommands(&ran, commandStubs{
+		"ls-remote": func(cmd *exec.Cmd) ([]byte, error) {
+			return []byte(movedSha + "\trefs/heads/master\n"), nil
+		},
+	})()
+
+	var deps *Dependencies
+	var err error
+	captureStdout(func() { deps, err = resolveGraph(pwd) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node := deps.ImportGraph.Search("github.com/gopack-test/refreshed"); node == nil || node.Dependency.Revision != movedSha {
+		t.Errorf("Expected the refreshed graph to be resolved from the remote\n")
+	}
+	if len(ran) != 1 || !strings.HasPrefix(ran[0], "git ls-remote") {
+		t.Errorf("Expected a refresh to only query the remote, ran %v\n", ran)
+	}
+	if _, err := os.Stat(lockPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected a refresh not to write %s\n", GopackLock)
+	}
+	if graphOnly {
+		t.Errorf("Expected the refresh to leave --graph-only as it was\n")
+	}
+}
+
+func TestTreeBuildsSharedDepsOnce(t *testing.T) {
+	g := &GraphJSON{
+		Nodes: []*GraphNodeJSON{{Import: "root", Root: true}, {Import: "a"}, {Import: "b"}, {Import: "shared"}},
+		Edges: []*GraphEdgeJSON{{"root", "a"}, {"root", "b"}, {"a", "shared"}, {"b", "shared"}, {"shared", "root"}},
+	}
+
+	roots := g.tree()
+	if len(roots) != 1 || len(roots[0].Children) != 2 {
+		t.Fatalf("Expected the root with its two deps, built %+v\n", roots)
+	}
+	a, b := roots[0].Children[0], roots[0].Children[1]
+	if len(a.Children) != 1 || len(b.Children) != 1 || a.Children[0] != b.Children[0] {
+		t.Errorf("Expected the shared dep to be built once for both\n")
+	}
+	if len(a.Children[0].Children) != 0 {
+		t.Errorf("Expected the cycle back to the root to end\n")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// print as json, set with show, dependencytree or stats --json
+var printJSON = false
+
+// Everything known about one dep, gp show <import>.
+type DepDetail struct {
+	Import   string `json:"import"`
+	Checkout string `json:"checkout,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	// where it's fetched from once rewritten
+	Source string `json:"source"`
+	// declared in the project's own gopack.config
+	Direct  bool     `json:"direct"`
+	Dev     bool     `json:"dev"`
+	Parents []string `json:"parents"`
+	Path    string   `json:"path"`
+	// content hash of the vendored copy in the lock
+	Hash string `json:"hash,omitempty"`
+}
+
+func (d *Dependencies) Detail(importPath string, lock *Lock) (*DepDetail, error) {
+	node, found := d.IncludesDependency(importPath)
+	if !found || node.Dependency == nil {
+		return nil, fmt.Errorf("%s is not a dependency", importPath)
+	}
+
+	dep := node.Dependency
+	detail := &DepDetail{
+		Import:   dep.Import,
+		Checkout: dep.Checkout(),
+		Revision: dep.Revision,
+		Source:   maskCredentials(dep.RemoteURL()),
+		Direct:   containsString(d.Imports, dep.Import),
+		Dev:      dep.Dev,
+		Parents:  []string{},
+		Path:     dep.Src(),
+	}
+
+	if locked := lock.Deps[dep.Import]; locked != nil {
+		detail.Hash = locked.Hash
+		if detail.Revision == "" {
+			detail.Revision = locked.Revision
+		}
+	}
+
+	for parent, children := range d.ImportGraph.Edges {
+		if containsString(children, dep.Import) {
+			detail.Parents = append(detail.Parents, parent)
+		}
+	}
+	sort.Strings(detail.Parents)
+	return detail, nil
+}
+
+func (detail *DepDetail) Print(w io.Writer) error {
+	if printJSON {
+		out, err := json.MarshalIndent(detail, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	}
+
+	kind := "transitive"
+	if detail.Direct {
+		kind = "direct"
+	}
+	if detail.Dev {
+		kind += ", dev"
+	}
+
+	fmt.Fprintf(w, "import:   %s (%s)\n", detail.Import, kind)
+	fmt.Fprintf(w, "checkout: %s\n", orNone(detail.Checkout))
+	fmt.Fprintf(w, "revision: %s\n", orNone(detail.Revision))
+	fmt.Fprintf(w, "source:   %s\n", detail.Source)
+	for _, parent := range detail.Parents {
+		fmt.Fprintf(w, "parent:   %s\n", parent)
+	}
+	fmt.Fprintf(w, "path:     %s\n", detail.Path)
+	fmt.Fprintf(w, "hash:     %s\n", orNone(detail.Hash))
+	return nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+func showDep(deps *Dependencies, importPath string) error {
+	lock, err := ReadLock()
+	if err != nil {
+		return err
+	}
+	detail, err := deps.Detail(importPath, lock)
+	if err != nil {
+		return err
+	}
+	return detail.Print(os.Stdout)
+}
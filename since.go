@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	// a previous lock, only deps resolving differently are fetched, set with --since
+	sinceLockPath = ""
+	sinceLock     *Lock
+)
+
+func OpenSinceLock(path string) (*Lock, error) {
+	lock, err := readLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(lock.Deps) == 0 {
+		return nil, fmt.Errorf("%s has no locked dependencies", path)
+	}
+	return lock, nil
+}
+
+// Whether the dep still resolves to what the lock recorded and the
+// vendored copy is the locked one, so there's nothing to fetch.
+func (l *Lock) Unchanged(d *Dep) bool {
+	locked := l.Deps[d.Import]
+	if locked == nil || locked.Checkout != d.Checkout() {
+		return false
+	}
+
+	if hash, err := contentHash(d.Src()); err != nil || hash != locked.Hash {
+		return false
+	}
+
+	switch {
+	case d.CheckoutFlag == CommitFlag:
+		return locked.Revision != "" && strings.HasPrefix(locked.Revision, d.CheckoutSpec)
+	case d.AsOf != "":
+		return true
+	case d.Scm == GitTag:
+		return locked.Revision != "" && remoteRevision(d) == locked.Revision
+	case d.CheckoutFlag == TagFlag:
+		// tags of other scms are assumed to not move
+		return true
+	}
+	return false
+}
+
+// The commit the branch, tag or default branch of a git dep points to.
+func remoteRevision(d *Dep) string {
+	refs, err := LsRemote(d.RemoteURL())
+	if err != nil {
+		return ""
+	}
+
+	names := []string{"HEAD"}
+	switch d.CheckoutFlag {
+	case BranchFlag:
+		names = []string{"refs/heads/" + d.CheckoutSpec}
+	case TagFlag:
+		tag := d.CheckoutSpec
+		if d.Tag != "" {
+			tag = d.Tag
+		}
+		// annotated tags are peeled to the commit they tag
+		names = []string{"refs/tags/" + tag + "^{}", "refs/tags/" + tag}
+	}
+
+	for _, name := range names {
+		for _, ref := range refs {
+			if ref.Name == name {
+				return ref.Hash
+			}
+		}
+	}
+	return ""
+}
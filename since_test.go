@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os/exec"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	lockedSha = "182cae2ee3926a960223d8db4998aa9d57c89788"
+	movedSha  = "9d57c89788182cae2ee3926a960223d8db4998aa"
+)
+
+func vendoredGitDep(importPath, checkoutType, spec string) *Dep {
+	dep := gitDep(importPath, checkoutType, spec)
+	createPath(path.Join(dep.Src(), HiddenGit))
+	createSourceFixture(dep.Src(), "main.go", "package main\n")
+	dep.Revision = lockedSha
+	return dep
+}
+
+// Fake git where master of the moved dep has a new commit, recording
+// which deps get cloned or fetched.
+func stubSinceGit(fetched *[]string) func() {
+	return stubCommands(commandStubs{
+		"ls-remote": func(cmd *exec.Cmd) ([]byte, error) {
+			sha := lockedSha
+			if cmd.Args[2] == "https://github.com/gopack-test/moved.git" {
+				sha = movedSha
+			}
+			return []byte(sha + "\trefs/heads/master\n"), nil
+		},
+		"clone": func(cmd *exec.Cmd) ([]byte, error) {
+			*fetched = append(*fetched, cmd.Args[2])
+			return cloneCheckout(cmd)
+		},
+		"fetch": func(cmd *exec.Cmd) ([]byte, error) {
+			*fetched = append(*fetched, filepath.Base(cmd.Dir))
+			return nil, nil
+		},
+	})
+}
+
+func TestInstallSinceOnlyFetchesChangedDeps(t *testing.T) {
+	setupTestPwd()
+
+	pinned := vendoredGitDep("github.com/gopack-test/pinned", "commit", "182cae2")
+	stable := vendoredGitDep("github.com/gopack-test/stable", "branch", "master")
+	moved := vendoredGitDep("github.com/gopack-test/moved", "branch", "master")
+	sinceLock = lockDeps(t, pinned, stable, moved)
+	defer func() { sinceLock = nil }()
+
+	added := gitDep("github.com/gopack-test/added", "branch", "master")
+
+	fetched := []string{}
+	defer stubSinceGit(&fetched)()
+
+	graph := NewGraph()
+	deps := &Dependencies{ImportGraph: graph, DepList: []*Dep{pinned, stable, moved, added}}
+	for _, dep := range deps.DepList {
+		graph.Insert(dep)
+	}
+
+	if err := loadTransitiveDependencies(deps); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fetched) != 2 || fetched[0] != "moved" || fetched[1] != "https://github.com/gopack-test/added.git" {
+		t.Errorf("Expected only the moved and added deps to be fetched, fetched %v\n", fetched)
+	}
+
+	if pinned.fetch || stable.fetch || stable.Revision != lockedSha {
+		t.Errorf("Expected the unchanged deps to keep their locked revision\n")
+	}
+}
+
+func TestSinceLockRejectsModifiedVendorCopy(t *testing.T) {
+	setupTestPwd()
+
+	stable := vendoredGitDep("github.com/gopack-test/stable", "branch", "master")
+	lock := lockDeps(t, stable)
+	createSourceFixture(stable.Src(), "main.go", "package patched\n")
+
+	fetched := []string{}
+	defer stubSinceGit(&fetched)()
+
+	if lock.Unchanged(stable) {
+		t.Errorf("Expected a vendored copy that doesn't match the lock to be fetched again\n")
+	}
+}
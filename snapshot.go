@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const GopackEnvSnapshot = ".gopack/environment"
+
+var (
+	// write the environment next to the lock, set with --env-snapshot
+	envSnapshot = false
+
+	// the variables that change how deps are fetched or built
+	relevantEnvPrefixes = []string{"GO", "CGO_", "GIT_", "HG", "SVN_", "BZR_", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+
+	scmVersionArgs = map[string][]string{
+		GitTag: {"--version"},
+		HgTag:  {"--version", "--quiet"},
+		SvnTag: {"--version", "--quiet"},
+		BzrTag: {"--version"},
+	}
+)
+
+// What a lock was resolved with, to tell apart environments a
+// reproduction fails in.
+type EnvSnapshot struct {
+	Gopack string
+	Go     string
+	OS     string
+	Arch   string
+	// versions of the scm clients used, by scm
+	Scms map[string]string
+	// relevant variables, secrets masked
+	Env map[string]string
+}
+
+func TakeEnvSnapshot(scms []string) *EnvSnapshot {
+	s := &EnvSnapshot{
+		Gopack: GopackVersion,
+		Go:     runtime.Version(),
+		OS:     runtime.GOOS,
+		Arch:   runtime.GOARCH,
+		Scms:   make(map[string]string),
+		Env:    make(map[string]string),
+	}
+
+	// the go the deps are built with rather than the one gopack was
+	if out, err := execute(goCommand("version")); err == nil {
+		s.Go = strings.TrimSpace(string(out))
+	}
+
+	for _, scm := range scms {
+		args, ok := scmVersionArgs[scm]
+		if !ok {
+			continue
+		}
+		if out, err := execute(scmCommand(scm, args...)); err == nil {
+			s.Scms[scm] = strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)[0]
+		}
+	}
+
+	for _, kv := range fetchEnv() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 && isRelevantEnv(parts[0]) {
+			s.Env[parts[0]] = maskEnvValue(parts[0], parts[1])
+		}
+	}
+	return s
+}
+
+func isRelevantEnv(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, prefix := range relevantEnvPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *EnvSnapshot) Bytes() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "gopack = %q\n", s.Gopack)
+	fmt.Fprintf(&buf, "go = %q\n", s.Go)
+	fmt.Fprintf(&buf, "os = %q\n", s.OS)
+	fmt.Fprintf(&buf, "arch = %q\n", s.Arch)
+	writeSortedTable(&buf, "scm", s.Scms)
+	writeSortedTable(&buf, "env", s.Env)
+	return buf.Bytes()
+}
+
+func writeSortedTable(buf *bytes.Buffer, name string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(buf, "\n[%s]\n", name)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s = %q\n", k, values[k])
+	}
+}
+
+func (s *EnvSnapshot) Write() error {
+	path := filepath.Join(pwd, GopackEnvSnapshot)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	return ioutil.WriteFile(path, s.Bytes(), 0644)
+}
+
+// The scms of every dep in the graph.
+func (d *Dependencies) Scms() []string {
+	scms := []string{}
+	for e := d.ImportGraph.Leafs.Front(); e != nil; e = e.Next() {
+		node := d.ImportGraph.Search(e.Value.(string))
+		if node != nil && node.Dependency != nil && !containsString(scms, node.Dependency.Scm) {
+			scms = append(scms, node.Dependency.Scm)
+		}
+	}
+	sort.Strings(scms)
+	return scms
+}
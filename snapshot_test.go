@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestEnvSnapshot(t *testing.T) {
+	setupTestPwd()
+	defer stubCommands(commandStubs{
+		"*": func(cmd *exec.Cmd) ([]byte, error) {
+			switch cmd.Args[0] {
+			case "go":
+				return []byte("go version go1.4.2 linux/amd64\n"), nil
+			case "git":
+				return []byte("git version 2.1.0\n"), nil
+			}
+			return nil, fmt.Errorf("%s not found", cmd.Args[0])
+		},
+	})()
+
+	os.Setenv("GIT_TOKEN", "s3cr3t")
+	defer os.Unsetenv("GIT_TOKEN")
+
+	if err := TakeEnvSnapshot([]string{GitTag, HgTag}).Write(); err != nil {
+		t.Fatal(err)
+	}
+	dat, _ := ioutil.ReadFile(path.Join(pwd, GopackEnvSnapshot))
+	snapshot := string(dat)
+
+	for _, expected := range []string{
+		fmt.Sprintf("gopack = %q\n", GopackVersion),
+		"go = \"go version go1.4.2 linux/amd64\"\n",
+		fmt.Sprintf("os = %q\narch = %q\n", runtime.GOOS, runtime.GOARCH),
+		"[scm]\ngit = \"git version 2.1.0\"\n",
+		"GIT_TOKEN = \"" + Masked + "\"\n",
+	} {
+		if !strings.Contains(snapshot, expected) {
+			t.Errorf("Expected %q in the snapshot\n%s\n", expected, snapshot)
+		}
+	}
+
+	if strings.Contains(snapshot, "s3cr3t") || strings.Contains(snapshot, "hg =") {
+		t.Errorf("Expected secrets and missing scms to be left out of\n%s\n", snapshot)
+	}
+}
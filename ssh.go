@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const GopackKnownHosts = ".gopack/known_hosts"
+
+// the known_hosts ssh fetches are checked against, set up from the [ssh]
+// section of gopack.config
+var knownHostsPath = ""
+
+// Gather the host keys of the [ssh] section in one known_hosts file and
+// have git check every ssh host against it, without prompting.
+func (c *Config) SetupKnownHosts() error {
+	if c.KnownHostsFile == "" && len(c.HostKeys) == 0 {
+		return nil
+	}
+
+	var hosts []byte
+	if c.KnownHostsFile != "" {
+		var err error
+		if hosts, err = ioutil.ReadFile(c.KnownHostsFile); err != nil {
+			return fmt.Errorf("Error reading known_hosts %s: %s", c.KnownHostsFile, err)
+		}
+		if len(hosts) > 0 && hosts[len(hosts)-1] != '\n' {
+			hosts = append(hosts, '\n')
+		}
+	}
+	for _, key := range c.HostKeys {
+		hosts = append(hosts, []byte(key+"\n")...)
+	}
+
+	p := filepath.Join(pwd, GopackKnownHosts)
+	os.MkdirAll(filepath.Dir(p), 0755)
+	if err := ioutil.WriteFile(p, hosts, 0644); err != nil {
+		return err
+	}
+	knownHostsPath = p
+	return nil
+}
+
+func sshCommand() string {
+	return fmt.Sprintf("ssh -o StrictHostKeyChecking=yes -o UserKnownHostsFile='%s'", knownHostsPath)
+}
+
+var scpLikeSource = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):`)
+
+// The host of an ssh source the way known_hosts names it, with the port
+// when it isn't 22.
+func sshHost(source string) (string, bool) {
+	if strings.Contains(source, "://") {
+		u, err := url.Parse(source)
+		if err != nil || !strings.Contains(u.Scheme, "ssh") {
+			return "", false
+		}
+		host, port := u.Host, ""
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host, port = host[:i], host[i+1:]
+		}
+		if port != "" && port != "22" {
+			return fmt.Sprintf("[%s]:%s", host, port), true
+		}
+		return host, true
+	}
+	if m := scpLikeSource.FindStringSubmatch(source); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// Fail before cloning from an ssh host the [ssh] section has no key for,
+// rather than leaving ssh to reject it.
+func (d *Dep) checkKnownHosts() error {
+	if knownHostsPath == "" {
+		return nil
+	}
+	known, err := ioutil.ReadFile(knownHostsPath)
+	if err != nil {
+		return err
+	}
+	for _, source := range d.Sources() {
+		if host, ok := sshHost(source); ok && !knownHost(string(known), host) {
+			return fmt.Errorf("%s - no host key for %s, add it to the [ssh] section of gopack.config, ssh-keyscan %s prints it", d.Import, host, host)
+		}
+	}
+	return nil
+}
+
+// Whether a line of known_hosts names host, in plain text, as a
+// pattern or hashed.
+func knownHost(known, host string) bool {
+	for _, line := range strings.Split(known, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+			// @cert-authority and @revoked markers
+			fields = fields[1:]
+		}
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, pattern := range strings.Split(fields[0], ",") {
+			if matchHostPattern(pattern, host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchHostPattern(pattern, host string) bool {
+	if strings.HasPrefix(pattern, "|1|") {
+		parts := strings.Split(pattern[3:], "|")
+		if len(parts) != 2 {
+			return false
+		}
+		salt, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(host))
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil)) == parts[1]
+	}
+	matched, _ := path.Match(pattern, host)
+	return matched
+}
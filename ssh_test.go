@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestKnownHostsAreAppliedToSshFetches(t *testing.T) {
+	config := setupTestConfig(`
+[ssh]
+  known_hosts = "ci_known_hosts"
+  host_keys = ["git.internal ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIInternalKey"]
+`)
+	createSourceFixture(pwd, "ci_known_hosts", "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGithubKey")
+	config = loadTestConfig(pwd)
+
+	if err := config.SetupKnownHosts(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { knownHostsPath = "" }()
+
+	dat, _ := ioutil.ReadFile(knownHostsPath)
+	expected := "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGithubKey\n" +
+		"git.internal ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIInternalKey\n"
+	if string(dat) != expected {
+		t.Errorf("Expected the known hosts\n%s\nfound\n%s\n", expected, dat)
+	}
+
+	cmd := scmCommand("git", "clone", "git@github.com:gopack-test/private.git")
+	sshCommand := "GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=yes -o UserKnownHostsFile='" + knownHostsPath + "'"
+	if cmd.Env[len(cmd.Env)-1] != sshCommand {
+		t.Errorf("Expected the fetch environment to check host keys strictly, found %s\n", cmd.Env[len(cmd.Env)-1])
+	}
+}
+
+func TestUnknownSshHostFailsBeforeCloning(t *testing.T) {
+	config := setupTestConfig(`
+[ssh]
+  host_keys = ["github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGithubKey"]
+`)
+	if err := config.SetupKnownHosts(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { knownHostsPath = "" }()
+
+	cloned := []string{}
+	defer stubGit(&cloned)()
+
+	known := gitDep("github.com/gopack-test/known-host", "", "")
+	known.Source = "git@github.com:gopack-test/known-host.git"
+	if err := known.Get(); err != nil {
+		t.Errorf("Expected a host with a key to be cloned, got %s\n", err)
+	}
+
+	unknown := gitDep("git.internal/gopack-test/unknown-host", "", "")
+	unknown.Source = "ssh://git@git.internal:2222/gopack-test/unknown-host.git"
+	err := unknown.Get()
+	if err == nil || !strings.Contains(err.Error(), "no host key for [git.internal]:2222") {
+		t.Errorf("Expected the unknown host to be reported, got %v\n", err)
+	}
+	if len(cloned) != 1 {
+		t.Errorf("Expected only the known host to be cloned, cloned %v\n", cloned)
+	}
+}
+
+func TestHashedKnownHosts(t *testing.T) {
+	// ssh-keygen -H for github.com
+	known := "|1|JfKTdBh7rNbXkVAQCRp4OQoPfmI=|n6jR7+nhXy5/SSgp9zgUERcu6ZU= ssh-rsa AAAAB3NzaC1yc2E\n"
+	if !knownHost(known, "github.com") {
+		t.Errorf("Expected the hashed entry to match github.com\n")
+	}
+	if knownHost(known, "gitlab.com") {
+		t.Errorf("Expected the hashed entry not to match gitlab.com\n")
+	}
+}
+
+func TestScmURLIsClonedVerbatim(t *testing.T) {
+	config := setupTestConfig(`
+[deps.private]
+  import = "github.com/gopack-test/scm-url"
+  scm = "git"
+  scm_url = "git@github.com:gopack-test/scm-url.git"
+  branch = "master"
+
+[rewrites.ssh]
+  prefix = "git@github.com:"
+  to = "https://git.internal/mirror/"
+`)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deps.applyRewrites(); err != nil {
+		t.Fatal(err)
+	}
+
+	dep := deps.DepList[0]
+	if dep.Import != "github.com/gopack-test/scm-url" || dep.Src() != dependencyPath("github.com/gopack-test/scm-url") {
+		t.Errorf("Expected the dep to be vendored at its import path, was %s\n", dep.Src())
+	}
+
+	cloned := []string{}
+	defer stubGit(&cloned)()
+	if err := dep.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cloned) != 1 || cloned[0] != "git@github.com:gopack-test/scm-url.git" {
+		t.Errorf("Expected the scm_url to be cloned as is, cloned %v\n", cloned)
+	}
+}
+
+func TestScmURLAndSourceConflict(t *testing.T) {
+	config := setupTestConfig(`
+[deps.private]
+  import = "github.com/gopack-test/scm-url-conflict"
+  scm = "git"
+  source = "https://github.com/gopack-test/scm-url-conflict.git"
+  scm_url = "git@github.com:gopack-test/scm-url-conflict.git"
+`)
+
+	_, err := config.LoadDependencyModel(NewGraph())
+	if err == nil || !strings.Contains(err.Error(), "only one of source/scm_url") {
+		t.Errorf("Expected source and scm_url together to fail, got %v\n", err)
+	}
+}
+
+func TestFetchesNeverPromptForCredentials(t *testing.T) {
+	if !containsString(scmCommand("git", "clone").Env, "GIT_TERMINAL_PROMPT=0") {
+		t.Errorf("Expected git not to prompt for credentials\n")
+	}
+
+	err := &ScmError{errors.New("exit status 128"), []byte("fatal: could not read Username for 'https://github.com': terminal prompts disabled\n")}
+	if !err.AuthFailed() || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("Expected the failed authentication to be explained, got %s\n", err)
+	}
+}
@@ -1,20 +1,43 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
+)
+
+var (
+	// keep printing the stats as files change, set with stats --watch
+	statsWatch         = false
+	statsWatchInterval = time.Second
+	// text, json or csv, set with stats --format or --json
+	statsFormat = "text"
+	// how many files are parsed at once
+	analyzeJobs = runtime.GOMAXPROCS(0)
 )
 
 type ProjectStats struct {
 	ImportStatsByPath map[string]*ImportStats
+	// the analyzed files, to find the ones changed since
+	files map[string]fileStamp
+}
+
+type fileStamp struct {
+	ModTime time.Time
+	Size    int64
 }
 
 type ImportStats struct {
@@ -23,6 +46,12 @@ type ImportStats struct {
 	ReferencePositions []token.Position
 }
 
+type byImportPath []*ImportStats
+
+func (s byImportPath) Len() int           { return len(s) }
+func (s byImportPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byImportPath) Less(i, j int) bool { return s[i].Path < s[j].Path }
+
 type SummaryItem struct {
 	Origin int
 	Sum    int
@@ -44,6 +73,34 @@ func (i SummaryItem) Legend() string {
 	return fmt.Sprintf("%s\t%s\t%d", origin, i.Path, i.Sum)
 }
 
+func (i SummaryItem) OriginName() string {
+	switch i.Origin {
+	case 1:
+		return "remote"
+	case 0:
+		return "local"
+	}
+	return "stdlib"
+}
+
+// The stats as they're marshaled by stats --json, the fields only ever
+// get added to so scripts asserting on them keep working.
+type StatsReport struct {
+	Packages int           `json:"packages"`
+	Files    int           `json:"files"`
+	Imports  int           `json:"imports"`
+	Remote   int           `json:"remote"`
+	Local    int           `json:"local"`
+	Stdlib   int           `json:"stdlib"`
+	Items    []StatsImport `json:"items"`
+}
+
+type StatsImport struct {
+	Path       string `json:"path"`
+	Origin     string `json:"origin"`
+	References int    `json:"references"`
+}
+
 type Summary struct {
 	Items []SummaryItem
 }
@@ -63,14 +120,62 @@ func (s *Summary) Less(i, j int) bool {
 func NewProjectStats() *ProjectStats {
 	return &ProjectStats{
 		make(map[string]*ImportStats),
+		make(map[string]fileStamp),
+	}
+}
+
+// Commands that only read the graph, or hand over to a plugin, don't
+// look at the imports of the project, so it isn't analyzed. Go commands
+// only get this far to install the deps first.
+var statsFreeActions = map[string]bool{"dependencytree": true, "why": true, "show": true}
+
+func needsStats(action string) bool {
+	switch {
+	case action == "graph":
+		return graphOrphans
+	case !actions[action]:
+		return passesThrough(action) && goInstallsFirst()
 	}
+	return !statsFreeActions[action]
 }
 
 func AnalyzeSourceTree(dir string) (*ProjectStats, error) {
 	ps := NewProjectStats()
-	err := filepath.Walk(
+	if _, err := ps.Refresh(dir); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// Reanalyze the files below dir that were added, changed or removed
+// since the last refresh, returning how many there were. Whatever the
+// .gopackignore of dir lists is left out.
+func (ps *ProjectStats) Refresh(dir string) (int, error) {
+	changed := 0
+	seen := make(map[string]bool)
+	queue := []string{}
+	stamps := make(map[string]fileStamp)
+	ignores, err := readStatsIgnore(dir)
+	if err != nil {
+		return changed, err
+	}
+	err = filepath.Walk(
 		dir,
 		func(path string, info os.FileInfo, err error) error {
+			// a file that went away during the walk is just a removed file
+			if err != nil {
+				if path == dir {
+					return err
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, path)
+			if rel != "." && statsIgnored(ignores, rel, info.IsDir()) {
+				if info.IsDir() && !negatesBelow(ignores) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 			fileDir := filepath.Dir(path)
 			baseName := filepath.Base(path)
 			if strings.HasSuffix(baseName, ".go") {
@@ -82,17 +187,144 @@ func AnalyzeSourceTree(dir string) (*ProjectStats, error) {
 					strings.Contains(fileDir, GopackDir) {
 					return nil
 				}
-				e := ps.analyzeSourceFile(path)
-				if e != nil {
-					return e
+				seen[path] = true
+				stamp := fileStamp{info.ModTime(), info.Size()}
+				previous, found := ps.files[path]
+				if found && previous == stamp {
+					return nil
+				}
+				changed++
+				if found {
+					ps.RemoveFile(path)
 				}
+				queue = append(queue, path)
+				stamps[path] = stamp
+			} else if info.IsDir() && baseName == GopackDir && !strings.Contains(dir, GopackDir) {
+				// nothing below it is analyzed, so don't walk the vendor tree
+				return filepath.SkipDir
 			}
 			return nil
 		})
 	if err != nil {
-		return nil, err
+		return changed, err
+	}
+
+	if err := ps.analyzeFiles(queue); err != nil {
+		return changed, err
+	}
+	for _, path := range queue {
+		ps.files[path] = stamps[path]
+	}
+
+	for path := range ps.files {
+		if !seen[path] {
+			changed++
+			ps.RemoveFile(path)
+		}
+	}
+	return changed, nil
+}
+
+// Parse the files analyzeJobs at a time, each worker into stats of its
+// own, merged once they're all done.
+func (ps *ProjectStats) analyzeFiles(paths []string) error {
+	jobs := analyzeJobs
+	if jobs > len(paths) {
+		jobs = len(paths)
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	queue := make(chan string)
+	partials := make([]*ProjectStats, jobs)
+	errs := make([]error, jobs)
+	var wg sync.WaitGroup
+	for i := range partials {
+		partials[i] = NewProjectStats()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for path := range queue {
+				if err := partials[i].analyzeSourceFile(path); err != nil && errs[i] == nil {
+					errs[i] = err
+				}
+			}
+		}(i)
+	}
+	for _, path := range paths {
+		queue <- path
+	}
+	close(queue)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	for _, partial := range partials {
+		ps.merge(partial)
+	}
+	return nil
+}
+
+// Add the imports a worker found. References are kept in file and line
+// order, whichever worker parsed the file.
+func (ps *ProjectStats) merge(partial *ProjectStats) {
+	for importPath, s := range partial.ImportStatsByPath {
+		existing, found := ps.ImportStatsByPath[importPath]
+		if !found {
+			ps.ImportStatsByPath[importPath] = s
+			continue
+		}
+		existing.ReferencePositions = append(existing.ReferencePositions, s.ReferencePositions...)
+		sort.Sort(byPosition(existing.ReferencePositions))
+	}
+}
+
+type byPosition []token.Position
+
+func (p byPosition) Len() int      { return len(p) }
+func (p byPosition) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byPosition) Less(i, j int) bool {
+	if p[i].Filename != p[j].Filename {
+		return p[i].Filename < p[j].Filename
+	}
+	return p[i].Offset < p[j].Offset
+}
+
+// Take the imports of a file out of the stats.
+func (ps *ProjectStats) RemoveFile(path string) {
+	delete(ps.files, path)
+	for importPath, s := range ps.ImportStatsByPath {
+		refs := []token.Position{}
+		for _, ref := range s.ReferencePositions {
+			if ref.Filename != path {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) == 0 {
+			delete(ps.ImportStatsByPath, importPath)
+		} else {
+			s.ReferencePositions = refs
+		}
+	}
+}
+
+// Print the summary again whenever a file changes.
+func (ps *ProjectStats) Watch(dir string) {
+	ps.PrintSummary()
+	for {
+		sleep(statsWatchInterval)
+		changed, err := ps.Refresh(dir)
+		if err != nil {
+			fmtcolor(Red, "%s\n", err)
+		}
+		if changed > 0 {
+			ps.PrintSummary()
+		}
 	}
-	return ps, nil
 }
 
 func (ps *ProjectStats) analyzeSourceFile(path string) error {
@@ -131,6 +363,13 @@ func (ps *ProjectStats) IsImportUsed(importPath string) bool {
 }
 
 func (ps *ProjectStats) PrintSummary() {
+	if statsFormat != "text" {
+		if err := ps.WriteSummary(os.Stdout, statsFormat); err != nil {
+			fmtcolor(Red, "%s\n", err)
+		}
+		return
+	}
+
 	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
 	summary := ps.GetSummary()
 
@@ -161,6 +400,53 @@ func (ps *ProjectStats) GetSummary() *Summary {
 	return summary
 }
 
+func (ps *ProjectStats) Report() *StatsReport {
+	report := &StatsReport{Files: len(ps.files), Items: []StatsImport{}}
+
+	packages := make(map[string]bool)
+	for path := range ps.files {
+		packages[filepath.Dir(path)] = true
+	}
+	report.Packages = len(packages)
+
+	for _, item := range ps.GetSummary().Items {
+		report.Imports++
+		switch item.Origin {
+		case 1:
+			report.Remote++
+		case 0:
+			report.Local++
+		case -1:
+			report.Stdlib++
+		}
+		report.Items = append(report.Items, StatsImport{item.Path, item.OriginName(), item.Sum})
+	}
+	return report
+}
+
+// Write the stats as json or csv, one row per import for csv.
+func (ps *ProjectStats) WriteSummary(w io.Writer, format string) error {
+	report := ps.Report()
+	switch format {
+	case "json":
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"origin", "import", "references"})
+		for _, item := range report.Items {
+			writer.Write([]string{item.Origin, item.Path, strconv.Itoa(item.References)})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("Unknown stats format `%s`, use one of text, json or csv", format)
+}
+
 func NewImportStats(importPath string, pos token.Position) *ImportStats {
 	parts := strings.Split(importPath, "/")
 	remote := false
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"testing"
+	"time"
 )
 
 func createSourceFixture(dir, name, fixture string) {
@@ -197,3 +200,261 @@ func checkSumaryItem(t *testing.T, item SummaryItem, path, legend string) {
 		t.Errorf("Expected legend to be %s, but was %s\n", legend, actual)
 	}
 }
+
+// Rewrite a fixture with a later mtime so the change is noticed even
+// within the timestamp resolution of the filesystem.
+func touchSourceFixture(dir, name, fixture string) {
+	createSourceFixture(dir, name, fixture)
+	later := time.Now().Add(time.Minute)
+	check(os.Chtimes(path.Join(dir, name), later, later))
+}
+
+func referenceCount(stats *ProjectStats, importPath string) int {
+	if i := stats.ImportStatsByPath[importPath]; i != nil {
+		return len(i.ReferencePositions)
+	}
+	return 0
+}
+
+func TestRefreshOnlyReanalyzesChangedFiles(t *testing.T) {
+	setupTestPwd()
+	createSourceFixture(pwd, "foo.go", `package main
+import "github.com/pelletier/go-toml"
+`)
+	createSourceFixture(pwd, "bar.go", `package main
+import "github.com/pelletier/go-toml"
+`)
+
+	stats, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, _ := stats.Refresh(pwd); changed != 0 {
+		t.Errorf("Expected no changes without touching files, found %d\n", changed)
+	}
+
+	touchSourceFixture(pwd, "bar.go", `package main
+import "github.com/gopack-test/other"
+`)
+	if changed, _ := stats.Refresh(pwd); changed != 1 {
+		t.Errorf("Expected 1 changed file, found %d\n", changed)
+	}
+	if referenceCount(stats, "github.com/pelletier/go-toml") != 1 || referenceCount(stats, "github.com/gopack-test/other") != 1 {
+		t.Errorf("Expected the changed file's imports to be replaced, found %v\n", stats.ImportStatsByPath)
+	}
+
+	touchSourceFixture(pwd, "baz.go", `package main
+import "github.com/gopack-test/other"
+`)
+	stats.Refresh(pwd)
+	if referenceCount(stats, "github.com/gopack-test/other") != 2 {
+		t.Errorf("Expected the added file to be counted, found %d references\n", referenceCount(stats, "github.com/gopack-test/other"))
+	}
+
+	check(os.Remove(path.Join(pwd, "foo.go")))
+	if changed, _ := stats.Refresh(pwd); changed != 1 {
+		t.Errorf("Expected the removed file to be a change, found %d\n", changed)
+	}
+	if stats.IsImportUsed("github.com/pelletier/go-toml") {
+		t.Errorf("Expected the imports of the removed file to be gone\n")
+	}
+}
+
+func TestRefreshReportsAMissingDir(t *testing.T) {
+	setupTestPwd()
+	if _, err := NewProjectStats().Refresh(path.Join(pwd, "missing.go")); err == nil {
+		t.Errorf("Expected refreshing a missing dir to fail\n")
+	}
+}
+
+func TestStatsReport(t *testing.T) {
+	setupTestPwd()
+
+	createSourceFixture(pwd, "foo.go", `package main
+import "github.com/pelletier/go-toml"
+`)
+	createSourceFixture(path.Join(pwd, "bar"), "bar.go", `package bar
+import "fmt"
+import "./foo"
+import "github.com/pelletier/go-toml"
+`)
+
+	stats, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := stats.WriteSummary(&out, "json"); err != nil {
+		t.Fatal(err)
+	}
+
+	report := &StatsReport{}
+	if err := json.Unmarshal(out.Bytes(), report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Packages != 2 || report.Files != 2 || report.Imports != 3 {
+		t.Errorf("Expected 2 packages, 2 files and 3 imports but was %+v\n", report)
+	}
+	if report.Remote != 1 || report.Local != 1 || report.Stdlib != 1 {
+		t.Errorf("Expected 1 remote, local and stdlib import but was %+v\n", report)
+	}
+	if report.Items[0] != (StatsImport{"github.com/pelletier/go-toml", "remote", 2}) {
+		t.Errorf("Expected go-toml first but was %+v\n", report.Items[0])
+	}
+}
+
+func TestStatsCSV(t *testing.T) {
+	setupTestPwd()
+
+	createSourceFixture(pwd, "foo.go", `package main
+import "fmt"
+import "github.com/pelletier/go-toml"
+`)
+
+	stats, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := stats.WriteSummary(&out, "csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "origin,import,references\nremote,github.com/pelletier/go-toml,1\nstdlib,fmt,1\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\nbut printed\n%s\n", expected, out.String())
+	}
+
+	if err := stats.WriteSummary(&out, "xml"); err == nil {
+		t.Errorf("Expected an unknown format to fail\n")
+	}
+}
+
+func TestAnalyzeSourceTreeSkipsIgnored(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gopack-ignore-")
+	defer os.RemoveAll(dir)
+
+	createSourceFixture(dir, StatsIgnore, "# generated and fixtures\n/gen/\ntestdata/**\n!testdata/keep/**\n*_mock.go\n")
+	for _, file := range []string{"main.go", "gen/api.go", "pkg/testdata/old.go", "testdata/keep/new.go", "pkg/db_mock.go"} {
+		createSourceFixture(path.Join(dir, path.Dir(file)), path.Base(file), "package main\nimport \"example.org/"+file+"\"\n")
+	}
+
+	stats, err := AnalyzeSourceTree(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for file, analyzed := range map[string]bool{
+		"main.go":              true,
+		"gen/api.go":           false,
+		"pkg/testdata/old.go":  true,
+		"testdata/keep/new.go": true,
+		"pkg/db_mock.go":       false,
+	} {
+		if stats.IsImportUsed("example.org/"+file) != analyzed {
+			t.Errorf("Expected %s to be analyzed: %v\n", file, analyzed)
+		}
+	}
+}
+
+func TestStatsIgnorePatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		{"testdata", "testdata", true, true},
+		{"testdata", "pkg/testdata/fixture.go", false, true},
+		{"/testdata", "pkg/testdata/fixture.go", false, false},
+		{"gen/", "gen", false, false},
+		{"gen/", "gen/api.go", false, true},
+		{"**/*.pb.go", "api/v1/service.pb.go", false, true},
+		{"api/**/*.pb.go", "api/service.pb.go", false, true},
+		{"api/**/*.pb.go", "web/service.pb.go", false, false},
+		{"*.go\n!main.go", "main.go", false, false},
+		{"*.go\n!main.go", "util.go", false, true},
+	}
+
+	dir, _ := ioutil.TempDir("", "gopack-ignore-")
+	defer os.RemoveAll(dir)
+	for _, test := range tests {
+		createSourceFixture(dir, StatsIgnore, test.pattern)
+		patterns, err := readStatsIgnore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statsIgnored(patterns, test.rel, test.isDir) != test.ignored {
+			t.Errorf("Expected %q to ignore %s: %v\n", test.pattern, test.rel, test.ignored)
+		}
+	}
+}
+
+func TestAnalyzeSourceTreeMergesWorkers(t *testing.T) {
+	dir := createBenchmarkTree(40)
+	defer os.RemoveAll(dir)
+
+	orig := analyzeJobs
+	defer func() { analyzeJobs = orig }()
+
+	analyzeJobs = 1
+	serial, err := AnalyzeSourceTree(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyzeJobs = 8
+	parallel, err := AnalyzeSourceTree(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(serial.ImportStatsByPath) != len(parallel.ImportStatsByPath) {
+		t.Fatalf("Expected %d imports, got %d\n", len(serial.ImportStatsByPath), len(parallel.ImportStatsByPath))
+	}
+	for importPath, s := range serial.ImportStatsByPath {
+		if refs := parallel.ImportStatsByPath[importPath].ReferenceList(); refs != s.ReferenceList() {
+			t.Errorf("Expected %s to be referenced in\n%s\ngot\n%s\n", importPath, s.ReferenceList(), refs)
+		}
+	}
+}
+
+// A tree of files importing from a few shared packages.
+func createBenchmarkTree(files int) string {
+	dir, _ := ioutil.TempDir("", "gopack-stats-")
+	for i := 0; i < files; i++ {
+		source := fmt.Sprintf(`package pkg%d
+
+import (
+	"fmt"
+	"github.com/gopack-test/shared%d"
+	"github.com/gopack-test/own%d"
+)
+
+func F%d() { fmt.Println(shared.X, own.Y) }
+`, i%10, i%5, i, i)
+		createSourceFixture(path.Join(dir, fmt.Sprintf("pkg%d", i%10)), fmt.Sprintf("file%d.go", i), source)
+	}
+	return dir
+}
+
+func BenchmarkAnalyzeSourceTree(b *testing.B) {
+	dir := createBenchmarkTree(2000)
+	defer os.RemoveAll(dir)
+
+	orig := analyzeJobs
+	defer func() { analyzeJobs = orig }()
+
+	for _, jobs := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			analyzeJobs = jobs
+			for i := 0; i < b.N; i++ {
+				if _, err := AnalyzeSourceTree(dir); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
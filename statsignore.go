@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const StatsIgnore = ".gopackignore"
+
+// A line of .gopackignore. Like in a .gitignore, a pattern without a
+// slash matches at any depth, a leading slash anchors it to the project
+// root, a trailing one only matches directories and ** matches any
+// number of directories.
+type ignorePattern struct {
+	Segments []string
+	Negate   bool
+	DirOnly  bool
+}
+
+// The patterns of dir's .gopackignore, none when it has no such file.
+func readStatsIgnore(dir string) ([]ignorePattern, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, StatsIgnore))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	patterns := []ignorePattern{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := ignorePattern{}
+		if strings.HasPrefix(line, "!") {
+			p.Negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.DirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		p.Segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// Whether the path, relative to the project root, is ignored. The last
+// pattern matching the path or a dir above it wins, so a negation can
+// bring back what an earlier pattern ignored.
+func statsIgnored(patterns []ignorePattern, rel string, isDir bool) bool {
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	ignored := false
+	for _, p := range patterns {
+		for n := len(segments); n > 0; n-- {
+			// only the path itself may be a file
+			if p.DirOnly && n == len(segments) && !isDir {
+				continue
+			}
+			if matchSegments(p.Segments, segments[:n]) {
+				ignored = !p.Negate
+				break
+			}
+		}
+	}
+	return ignored
+}
+
+// Whether a dir could hold something a negation brings back, in which
+// case it's walked even though it's ignored.
+func negatesBelow(patterns []ignorePattern) bool {
+	for _, p := range patterns {
+		if p.Negate {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+func (d *Dep) setSubmodules(t *toml.TomlTree) {
+	if submodules, ok := t.Get("submodules").(bool); ok {
+		d.Submodules = submodules
+	}
+}
+
+func (d *Dep) validateSubmodules() error {
+	if d.Submodules && d.Scm != GitTag {
+		return fmt.Errorf("%s - submodules are only supported with git", d.Import)
+	}
+	return nil
+}
+
+// The dep itself was fetched, its submodules weren't.
+type SubmoduleError struct {
+	Import string
+	Err    error
+}
+
+func (e *SubmoduleError) Error() string {
+	return fmt.Sprintf("Error updating the submodules of %s: %s", e.Import, e.Err)
+}
+
+// Bring the submodules of the checkout in dir to the revisions it
+// records, --offline only from what was fetched before.
+func (g Git) updateSubmodules(d *Dep, dir string) error {
+	if !d.Submodules {
+		return nil
+	}
+	args := []string{"submodule", "update", "--init", "--recursive"}
+	if offlineMode {
+		args = append(args, "--no-fetch")
+	}
+	if err := runScmIn(dir, "git", args...); err != nil {
+		return &SubmoduleError{d.Import, err}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// Record the scm commands, failing the submodule update when asked to.
+func stubSubmodules(commands *[]string, failUpdate bool) func() {
+	return recordCommands(commands, commandStubs{
+		"submodule": func(cmd *exec.Cmd) ([]byte, error) {
+			if failUpdate {
+				return []byte("fatal: repository not found\n"), errors.New("exit status 128")
+			}
+			return nil, nil
+		},
+		"*": succeed,
+	})
+}
+
+func submodulesDep(importPath string) *Dep {
+	dep := gitDep(importPath, "branch", "master")
+	dep.Submodules = true
+	dep.fetch = true
+	return dep
+}
+
+func TestSubmodulesAreUpdatedAfterCloneAndCheckout(t *testing.T) {
+	setupTestPwd()
+	commands := []string{}
+	defer stubSubmodules(&commands, false)()
+
+	dep := submodulesDep("github.com/gopack-test/submodules")
+	if err := dep.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Git{}).Checkout(dep); err != nil {
+		t.Fatal(err)
+	}
+
+	updates := 0
+	for _, command := range commands {
+		if command == "git submodule update --init --recursive" {
+			updates++
+		}
+	}
+	if updates != 2 {
+		t.Errorf("Expected the submodules to be updated after the clone and the checkout, ran %v\n", commands)
+	}
+}
+
+func TestSubmodulesAreOffByDefault(t *testing.T) {
+	setupTestPwd()
+	commands := []string{}
+	defer stubSubmodules(&commands, false)()
+
+	dep := submodulesDep("github.com/gopack-test/no-submodules")
+	dep.Submodules = false
+	if err := dep.Get(); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range commands {
+		if strings.HasPrefix(command, "git submodule") {
+			t.Errorf("Expected no submodule update, ran %s\n", command)
+		}
+	}
+}
+
+func TestSubmoduleFailuresAreReportedApart(t *testing.T) {
+	setupTestPwd()
+	commands := []string{}
+	defer stubSubmodules(&commands, true)()
+
+	dep := submodulesDep("github.com/gopack-test/broken-submodules")
+	err := dep.Get()
+	if _, ok := err.(*SubmoduleError); !ok {
+		t.Errorf("Expected a submodule error, got %v\n", err)
+	}
+}
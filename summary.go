@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	DepAdded     = "added"
+	DepUpdated   = "updated"
+	DepUnchanged = "unchanged"
+	DepFailed    = "failed"
+)
+
+// what installdeps did to every dep, printed once it's done
+var installSummary *InstallSummary
+
+// What was vendored for a dep before it was fetched.
+type priorCheckout struct {
+	vendored bool
+	revision string
+}
+
+type InstallSummary struct {
+	before   map[string]priorCheckout
+	Outcomes map[string]string
+}
+
+func NewInstallSummary() *InstallSummary {
+	return &InstallSummary{before: make(map[string]priorCheckout), Outcomes: make(map[string]string)}
+}
+
+// Remember the revision of the deps before they are fetched, deps fetched
+// ahead of their turn included. A nil summary remembers nothing.
+func (s *InstallSummary) Before(deps []*Dep) {
+	if s == nil {
+		return
+	}
+	for _, dep := range deps {
+		if _, seen := s.before[dep.Import]; seen {
+			continue
+		}
+		prior := priorCheckout{}
+		if _, err := os.Stat(dep.Src()); err == nil {
+			prior.vendored = true
+			prior.revision, _ = dep.CurrentRevision()
+		}
+		s.before[dep.Import] = prior
+	}
+}
+
+// Compare the dep with what it was before, printing where it moved from
+// and to when it did.
+func (s *InstallSummary) Record(dep *Dep, failed bool) {
+	if s == nil {
+		return
+	}
+	prior := s.before[dep.Import]
+	switch {
+	case failed:
+		s.Outcomes[dep.Import] = DepFailed
+	case !prior.vendored:
+		s.Outcomes[dep.Import] = DepAdded
+	case prior.revision != "" && dep.Revision != "" && prior.revision != dep.Revision:
+		fmtcolor(Gray, "        Moved: `%s` %s -> %s\n", dep.Import, shortRevision(prior.revision), shortRevision(dep.Revision))
+		s.Outcomes[dep.Import] = DepUpdated
+	default:
+		s.Outcomes[dep.Import] = DepUnchanged
+	}
+}
+
+func (s *InstallSummary) Count(outcome string) int {
+	n := 0
+	for _, o := range s.Outcomes {
+		if o == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// One line, "4 updated, 12 unchanged, 1 failed", in yellow when a dep
+// failed.
+func (s *InstallSummary) Print(w io.Writer) {
+	if s == nil {
+		return
+	}
+	counts := []string{}
+	for _, outcome := range []string{DepAdded, DepUpdated, DepUnchanged, DepFailed} {
+		if n := s.Count(outcome); n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, outcome))
+		}
+	}
+	if len(counts) == 0 {
+		return
+	}
+
+	color := Green
+	if s.Count(DepFailed) > 0 {
+		color = Yellow
+	}
+	fcolor(w, color, "      Summary: %s\n", strings.Join(counts, ", "))
+}
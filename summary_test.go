@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// Fake git where every vendored checkout is at the locked sha.
+func stubRevParse() func() {
+	return stubCommands(commandStubs{"rev-parse": revParseLocked})
+}
+
+func revParseLocked(cmd *exec.Cmd) ([]byte, error) {
+	return []byte(lockedSha + "\n"), nil
+}
+
+func TestInstallSummaryCountsOutcomes(t *testing.T) {
+	setupTestPwd()
+	defer stubRevParse()()
+
+	moved := vendoredGitDep("github.com/gopack-test/summary-moved", "branch", "master")
+	stable := vendoredGitDep("github.com/gopack-test/summary-stable", "branch", "master")
+	broken := vendoredGitDep("github.com/gopack-test/summary-broken", "branch", "master")
+	added := gitDep("github.com/gopack-test/summary-added", "branch", "master")
+
+	summary := NewInstallSummary()
+	summary.Before([]*Dep{moved, stable, broken, added})
+
+	moved.Revision = movedSha
+	added.Revision = movedSha
+	out := captureStdout(func() {
+		summary.Record(moved, false)
+		summary.Record(stable, false)
+		summary.Record(broken, true)
+		summary.Record(added, false)
+	})
+
+	if out != "        Moved: `github.com/gopack-test/summary-moved` 182cae2 -> 9d57c89\n" {
+		t.Errorf("Expected only the moved dep to print its revisions, printed\n%s\n", out)
+	}
+
+	var buf bytes.Buffer
+	summary.Print(&buf)
+	if buf.String() != "      Summary: 1 added, 1 updated, 1 unchanged, 1 failed\n" {
+		t.Errorf("Expected a count of every outcome, printed %s\n", buf.String())
+	}
+}
+
+func TestInstallSummaryWithoutFailures(t *testing.T) {
+	setupTestPwd()
+	summary := NewInstallSummary()
+	summary.Before([]*Dep{gitDep("github.com/gopack-test/summary-new", "branch", "master")})
+	summary.Record(gitDep("github.com/gopack-test/summary-new", "branch", "master"), false)
+
+	var buf bytes.Buffer
+	summary.Print(&buf)
+	if !strings.HasSuffix(buf.String(), "Summary: 1 added\n") {
+		t.Errorf("Expected only the added dep in the summary, printed %s\n", buf.String())
+	}
+
+	var none *InstallSummary
+	none.Record(gitDep("github.com/gopack-test/summary-new", "branch", "master"), false)
+	none.Print(&buf)
+}
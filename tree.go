@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// print the dependencies as a graphviz digraph, set with dependencytree --dot
+var treeDot = false
+
+// A node of the import path tree, gp dependencytree --json.
+type TreeNodeJSON struct {
+	Key          string          `json:"key"`
+	Import       string          `json:"import,omitempty"`
+	CheckoutType string          `json:"checkout_type,omitempty"`
+	CheckoutSpec string          `json:"checkout_spec,omitempty"`
+	Leaf         bool            `json:"leaf"`
+	Children     []*TreeNodeJSON `json:"children,omitempty"`
+}
+
+// The tree PrintDependencyTree prints, nested the same way.
+func (d *Dependencies) DependencyTreeJSON() []*TreeNodeJSON {
+	roots := []*TreeNodeJSON{}
+	// the last node seen at every depth, parent of the next one deeper
+	parents := []*TreeNodeJSON{}
+
+	d.ImportGraph.PreOrderVisit(
+		func(n *Node, depth int) {
+			node := &TreeNodeJSON{Key: n.Key, Leaf: n.Leaf}
+			if dep := n.Dependency; dep != nil {
+				node.Import = dep.Import
+				node.CheckoutType = dep.CheckoutType()
+				node.CheckoutSpec = dep.CheckoutSpec
+			}
+
+			parents = append(parents[:depth], node)
+			if depth == 0 {
+				roots = append(roots, node)
+			} else {
+				parents[depth-1].Children = append(parents[depth-1].Children, node)
+			}
+		})
+
+	sortTreeNodes(roots)
+	return roots
+}
+
+// Nodes are visited in map order, sorting them keeps the output stable.
+func sortTreeNodes(nodes []*TreeNodeJSON) {
+	sort.Sort(byTreeKey(nodes))
+	for _, node := range nodes {
+		sortTreeNodes(node.Children)
+	}
+}
+
+func (d *Dependencies) WriteDependencyTreeJSON(w io.Writer) error {
+	out, err := json.MarshalIndent(d.DependencyTreeJSON(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
+
+type byTreeKey []*TreeNodeJSON
+
+func (s byTreeKey) Len() int           { return len(s) }
+func (s byTreeKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byTreeKey) Less(i, j int) bool { return s[i].Key < s[j].Key }
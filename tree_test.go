@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDependencyTreeJSON(t *testing.T) {
+	graph := NewGraph()
+	graph.Insert(gitDep("github.com/gopack-test/tree-b", "", ""))
+	graph.Insert(gitDep("github.com/gopack-test/tree-a", "tag", "v1.0.0"))
+	deps := &Dependencies{ImportGraph: graph}
+
+	var out bytes.Buffer
+	if err := deps.WriteDependencyTreeJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[
+  {
+    "key": "gopack-test",
+    "leaf": false,
+    "children": [
+      {
+        "key": "tree-a",
+        "import": "github.com/gopack-test/tree-a",
+        "checkout_type": "tag",
+        "checkout_spec": "v1.0.0",
+        "leaf": true
+      },
+      {
+        "key": "tree-b",
+        "import": "github.com/gopack-test/tree-b",
+        "leaf": true
+      }
+    ]
+  }
+]
+`
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\nbut printed\n%s\n", expected, out.String())
+	}
+}
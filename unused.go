@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+var (
+	// fail when a dep is unused, set with unused --strict
+	unusedStrict = false
+	// gp unused lists them itself, without warning about them first
+	listUnused = false
+)
+
+// The deps declared in the config the project doesn't use: its code
+// imports neither them nor a package below them, and no dep it uses
+// depends on them, directly or through other deps. Only the deps of
+// vendored deps are known, so it's only complete once they're installed.
+func (d *Dependencies) Unused(p *ProjectStats) []*Dep {
+	used := d.usedImports(p)
+	unused := []*Dep{}
+	for _, dep := range d.DepList {
+		if !used[dep.Import] {
+			unused = append(unused, dep)
+		}
+	}
+	sort.Sort(byImport(unused))
+	return unused
+}
+
+// Validation errors other than unused deps. Those are only warned about
+// once the deps of deps are known, so a dep only other deps use isn't
+// taken for unused.
+func withoutUnused(errors []*ProjectError) []*ProjectError {
+	failed := []*ProjectError{}
+	for _, e := range errors {
+		if e.Kind != UnusedDep {
+			failed = append(failed, e)
+		}
+	}
+	return failed
+}
+
+func warnUnused(unused []*Dep) {
+	for _, dep := range unused {
+		fmtcolor(Yellow, "      Warning: `%s` not used\n", dep.Import)
+	}
+}
+
+func PrintUnused(w io.Writer, unused []*Dep) {
+	for _, dep := range unused {
+		if dep.Dev {
+			fmt.Fprintf(w, "%s (dev)\n", dep.Import)
+		} else {
+			fmt.Fprintln(w, dep.Import)
+		}
+	}
+	fmt.Fprintf(w, "%d deps in gopack.config are unused\n", len(unused))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUnusedSkipsDepsUsedThroughOtherDeps(t *testing.T) {
+	config := setupTestConfig(`
+[deps.used]
+  import = "github.com/gopack-test/unused-used"
+  scm = "git"
+  source = "https://github.com/gopack-test/unused-used.git"
+  branch = "master"
+
+[deps.transitive]
+  import = "github.com/gopack-test/unused-transitive"
+  scm = "git"
+  source = "https://github.com/gopack-test/unused-transitive.git"
+  branch = "master"
+
+[deps.stale]
+  import = "github.com/gopack-test/unused-stale"
+  scm = "git"
+  source = "https://github.com/gopack-test/unused-stale.git"
+  branch = "master"
+
+[dev-deps.fixtures]
+  import = "github.com/gopack-test/unused-fixtures"
+  scm = "git"
+  source = "https://github.com/gopack-test/unused-fixtures.git"
+  branch = "master"
+`)
+	createSourceFixture(pwd, "main.go", "package main\n\nimport _ \"github.com/gopack-test/unused-used/pkg\"\n")
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps.ImportGraph.AddEdge("github.com/gopack-test/unused-used", "github.com/gopack-test/unused-transitive")
+	p, err := AnalyzeSourceTree(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	PrintUnused(&buf, deps.Unused(p))
+	expected := "github.com/gopack-test/unused-fixtures (dev)\ngithub.com/gopack-test/unused-stale\n2 deps in gopack.config are unused\n"
+	if buf.String() != expected {
+		t.Errorf("Expected\n%s\nbut printed\n%s\n", expected, buf.String())
+	}
+
+	errors := deps.Validate(p)
+	if len(errors) != 2 || len(withoutUnused(errors)) != 0 || !strings.Contains(errors[1].Message, "unused-stale in gopack.config is unused") {
+		t.Errorf("Expected validation to report the 2 unused deps, got %v\n", errors)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+)
+
+// The deps of the project's config gp update fetches again, the one
+// vendoring importPath or all of them.
+func (d *Dependencies) selectForUpdate(args []string) ([]*Dep, error) {
+	if len(args) == 0 {
+		return d.DepList, nil
+	}
+
+	node, found := d.IncludesDependency(args[0])
+	if found && node.Dependency != nil {
+		for _, dep := range d.DepList {
+			if dep.Import == node.Dependency.Import {
+				return []*Dep{dep}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("`%s` is not a dependency in gopack.config", args[0])
+}
+
+// Fetch and check out the selected deps again, recording only them in
+// the lock. Their own deps and the rest of the vendor tree are left
+// alone.
+func updateDependencies(deps *Dependencies, repo string, args []string) ([]*Dep, error) {
+	selected, err := deps.selectForUpdate(args)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dep := range deps.DepList {
+		dep.fetch = false
+	}
+
+	updated := NewGraph()
+	for _, dep := range selected {
+		dep.Fetch(true)
+		if err := dep.resolveTagRange(nil); err != nil {
+			return nil, err
+		}
+		if err := updateDependency(deps, dep); err != nil {
+			return nil, err
+		}
+		if deps.StripVcs {
+			if err := dep.StripVcs(); err != nil {
+				return nil, err
+			}
+		}
+		updated.Insert(dep)
+	}
+
+	lock, err := ReadLock()
+	if err != nil {
+		return nil, err
+	}
+	if err := lock.Update(updated, repo); err != nil {
+		return nil, err
+	}
+	return selected, lock.Write()
+}
+
+func updateDeps(config *Config, deps *Dependencies, args []string) error {
+	updated, err := updateDependencies(deps, config.Repository, args)
+	if err != nil {
+		return err
+	}
+	fmtcolor(Green, "      Updated: %d dependencies in %s\n", len(updated), GopackLock)
+	return nil
+}
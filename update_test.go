@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUpdateOnlyFetchesTheGivenDep(t *testing.T) {
+	config := setupTestConfig(`
+[deps.first]
+  import = "github.com/gopack-test/update-first"
+  scm = "git"
+  source = "https://github.com/gopack-test/update-first.git"
+  branch = "master"
+
+[deps.second]
+  import = "github.com/gopack-test/update-second"
+  scm = "git"
+  source = "https://github.com/gopack-test/update-second.git"
+  branch = "master"
+`)
+	os.Remove(lockPath())
+
+	ran := []string{}
+	defer stubGitWithConfigs(&ran, nil)()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := updateDependencies(deps, config.Repository, []string{"github.com/gopack-test/update-second/sub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updated) != 1 || updated[0].Import != "github.com/gopack-test/update-second" {
+		t.Errorf("Expected only update-second to be updated, got %v\n", updated)
+	}
+	if len(ran) != 2 || ran[0] != "https://github.com/gopack-test/update-second.git" || ran[1] != "checkout master" {
+		t.Errorf("Expected update-second to be cloned and checked out, ran %v\n", ran)
+	}
+
+	lock, _ := ReadLock()
+	if len(lock.Deps) != 1 || lock.Deps["github.com/gopack-test/update-second"] == nil {
+		t.Errorf("Expected only update-second to be locked, got %v\n", lock.Imports())
+	}
+}
+
+func TestUpdateUnknownDep(t *testing.T) {
+	config := setupTestConfig(`
+[deps.first]
+  import = "github.com/gopack-test/update-first"
+`)
+	deps, err := config.LoadDependencyModel(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = updateDependencies(deps, config.Repository, []string{"github.com/gopack-test/unknown"})
+	expected := "`github.com/gopack-test/unknown` is not a dependency in gopack.config"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v\n", expected, err)
+	}
+}
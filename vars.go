@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+var varPattern = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// The keys of a dep that can carry credentials. A token they take from the
+// environment isn't in the committed config, and doesn't change its
+// checksum.
+var credentialKeys = map[string]bool{"source": true, "scm_url": true, "mirrors": true}
+
+// Read the [vars] section of a config.
+func loadVars(t *toml.TomlTree) (map[string]string, error) {
+	vars := make(map[string]string)
+	tree, ok := t.Get("vars").(*toml.TomlTree)
+	if !ok {
+		return vars, nil
+	}
+	for _, k := range tree.Keys() {
+		value, ok := tree.Get(k).(string)
+		if !ok {
+			return nil, fmt.Errorf("vars.%s - expected a string", k)
+		}
+		vars[k] = value
+	}
+	return vars, nil
+}
+
+// Replace every ${var} in the string values of the deps of a section,
+// recording the sources that took a var from the environment.
+func (c *Config) interpolateDeps(section string, deps *toml.TomlTree, vars map[string]string, env bool) error {
+	if deps == nil {
+		return nil
+	}
+	for _, k := range deps.Keys() {
+		dep, ok := deps.Get(k).(*toml.TomlTree)
+		if !ok {
+			continue
+		}
+		for _, key := range dep.Keys() {
+			switch value := dep.Get(key).(type) {
+			case string:
+				s, err := interpolate(value, vars, env)
+				if err != nil {
+					return fmt.Errorf("%s.%s.%s - %s", section, k, key, err)
+				}
+				if credentialKeys[key] {
+					c.recordEnvSource(dep, s, value, vars)
+				}
+				dep.Set(key, s)
+			case []interface{}:
+				for i, v := range value {
+					if s, ok := v.(string); ok {
+						interpolated, err := interpolate(s, vars, env)
+						if err != nil {
+							return fmt.Errorf("%s.%s.%s - %s", section, k, key, err)
+						}
+						if credentialKeys[key] {
+							c.recordEnvSource(dep, interpolated, s, vars)
+						}
+						value[i] = interpolated
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Map a source of dep that took a var from the environment to what it is
+// as written, with only the vars of [vars] replaced.
+func (c *Config) recordEnvSource(dep *toml.TomlTree, interpolated, written string, vars map[string]string) {
+	fromEnv := false
+	written = varPattern.ReplaceAllStringFunc(written, func(ref string) string {
+		if value, ok := vars[varPattern.FindStringSubmatch(ref)[1]]; ok {
+			return value
+		}
+		fromEnv = true
+		return ref
+	})
+	if !fromEnv {
+		return
+	}
+	if c.envSources == nil {
+		c.envSources = make(map[*toml.TomlTree]map[string]string)
+	}
+	if c.envSources[dep] == nil {
+		c.envSources[dep] = make(map[string]string)
+	}
+	c.envSources[dep][interpolated] = written
+}
+
+// A var of [vars] wins over an environment variable of the same name.
+// Only the project's config reads the environment, the config of a dep
+// could otherwise send a token anywhere in a source.
+func interpolate(s string, vars map[string]string, env bool) (string, error) {
+	var err error
+	interpolated := varPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := varPattern.FindStringSubmatch(ref)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		if !env {
+			err = fmt.Errorf("unknown var ${%s}, it's not in [vars] and only the project's gopack.config reads the environment", name)
+			return ""
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			err = fmt.Errorf("unknown var ${%s}, it's neither in [vars] nor set in the environment", name)
+		}
+		return value
+	})
+	return interpolated, err
+}
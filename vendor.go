@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// The vendor dir of the top-level vendor key, relative to the project
+// dir like VendorDir. The key is relative to the config, in configDir.
+// Empty when the config doesn't set it.
+func vendorDirOf(t *toml.TomlTree, configDir, dir string) (string, error) {
+	vendor, ok := t.Get("vendor").(string)
+	if !ok || vendor == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(vendor) {
+		if configDir == dir {
+			return filepath.Clean(vendor), nil
+		}
+		vendor = filepath.Join(configDir, vendor)
+	}
+	rel, err := filepath.Rel(dir, vendor)
+	if err != nil {
+		return "", fmt.Errorf("vendor - %s", err)
+	}
+	return rel, nil
+}
+
+// The vendor dir the config of the project in dir asks for. It's read before the
+// config is loaded since every command needs to know where the vendor
+// tree is, loading the config fails later on if it's invalid.
+func configuredVendorDir(dir string) string {
+	path := rootConfig(dir)
+	t, err := toml.LoadFile(path)
+	if err != nil {
+		return ""
+	}
+	vendor, _ := vendorDirOf(t, filepath.Dir(path), dir)
+	return vendor
+}
+
+// The config wins over GOPATH, which wins over .gopack/vendor.
+func setupVendorDir() error {
+	if vendor := configuredVendorDir(pwd); vendor != "" {
+		VendorDir = vendor
+	} else if goPath := os.Getenv("GOPATH"); goPath != "" {
+		s := filepath.SplitList(goPath)
+		if dir, err := filepath.Rel(pwd, s[0]); err == nil {
+			VendorDir = dir
+			return nil
+		}
+	}
+
+	return os.Setenv("GOPATH", filepath.Join(pwd, VendorDir))
+}
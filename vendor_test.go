@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func stubGoPath(goPath string) func() {
+	orig := os.Getenv("GOPATH")
+	os.Setenv("GOPATH", goPath)
+	return func() { os.Setenv("GOPATH", orig) }
+}
+
+func TestConfiguredVendorDirWinsOverGoPath(t *testing.T) {
+	defer stubVendorDir(VendorDir)()
+	setupTestPwd()
+	defer stubGoPath(filepath.Join(pwd, "gopath"))()
+	// beside the project, and only used by this run
+	shared := filepath.Base(pwd) + "-shared"
+	createFixtureConfig(pwd, `
+repo = "github.com/gopack-test/vendor-app"
+vendor = "../`+shared+`/vendor"
+`)
+
+	setupEnv()
+	config := loadTestConfig(pwd)
+
+	if VendorDir != filepath.Join("..", shared, "vendor") || config.VendorDir != VendorDir {
+		t.Errorf("Expected the vendor dir of the config, was %s\n", VendorDir)
+	}
+	if os.Getenv("GOPATH") != filepath.Join(pwd, VendorDir) {
+		t.Errorf("Expected GOPATH to point at the configured vendor dir, was %s\n", os.Getenv("GOPATH"))
+	}
+
+	check(config.InitRepo(NewGraph()))
+	link := filepath.Join(filepath.Dir(pwd), shared, "vendor", "src", "github.com/gopack-test/vendor-app")
+	if target, err := os.Readlink(link); err != nil || target != pwd {
+		t.Errorf("Expected the project to be linked into the shared vendor dir, got %s %v\n", target, err)
+	}
+}
+
+func TestGoPathWinsOverDefaultVendorDir(t *testing.T) {
+	defer stubVendorDir(VendorDir)()
+	setupTestPwd()
+	defer stubGoPath(filepath.Join(pwd, "gopath"))()
+	createFixtureConfig(pwd, `repo = "github.com/gopack-test/vendor-gopath"`)
+
+	setupEnv()
+	if VendorDir != "gopath" {
+		t.Errorf("Expected the vendor dir of GOPATH, was %s\n", VendorDir)
+	}
+}
+
+func TestAbsoluteVendorDirIsRelativeToProject(t *testing.T) {
+	defer stubVendorDir(VendorDir)()
+	setupTestPwd()
+	defer stubGoPath("")()
+	createFixtureConfig(pwd, `vendor = "`+filepath.Join(pwd, "deps")+`"`)
+
+	setupEnv()
+	if VendorDir != "deps" {
+		t.Errorf("Expected the absolute vendor dir relative to the project, was %s\n", VendorDir)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// only errors are printed
+	Quiet = iota
+	Normal
+	// the scm commands are printed before they run too
+	Verbose
+)
+
+var (
+	// how much gopack prints, set with --quiet or --verbose
+	verbosity     = Normal
+	quietOutput   = false
+	verboseOutput = false
+)
+
+func setVerbosity() error {
+	switch {
+	case quietOutput && verboseOutput:
+		return fmt.Errorf("Only one of --quiet and --verbose may be given")
+	case quietOutput:
+		verbosity = Quiet
+	case verboseOutput:
+		verbosity = Verbose
+	}
+	return nil
+}
+
+// Whether output in color c is printed at the current verbosity, only
+// errors are when quiet.
+func printed(c uint8) bool {
+	return verbosity > Quiet || c == Red
+}
+
+// Run cmd, printing it first when verbose. Credentials in its arguments
+// are masked.
+func execute(cmd *exec.Cmd) ([]byte, error) {
+	if verbosity >= Verbose {
+		command := maskURLCredentials(strings.Join(cmd.Args, " "))
+		if cmd.Dir != "" {
+			fmtcolor(Gray, "      Running: %s (in %s)\n", command, cmd.Dir)
+		} else {
+			fmtcolor(Gray, "      Running: %s\n", command)
+		}
+	}
+	return runCommand(cmd)
+}